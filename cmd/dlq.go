@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/rabbit"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var dlqFlags struct {
+	connection string
+	routingKey string
+	headers    []string
+	messageID  string
+	listLimit  int
+	limit      int
+	rate       float64
+	exchange   string
+	toKey      string
+	force      bool
+}
+
+// dlqCmd represents the dlq command
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Inspect, replay and purge the rabbitMQ dead letter queues from the config file",
+	Long: `Inspect, replay and purge the rabbitMQ dead letter queues from the config file.
+The messages are read without ack, the messages not replayed or purged are kept in the queue.
+The messages can be filtered by the routing key (--routing-key), headers (--header) and message id (--message-id).`,
+}
+
+var dlqListCmd = &cobra.Command{
+	Use:   "list <dead-letter>",
+	Short: "List the messages with their deaths",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withDeadLetterQueue(cmd, args[0], func(q *rabbit.DeadLetterQueue, filter rabbit.DeadLetterFilter) error {
+			msgs, err := q.Browse(filter, dlqFlags.listLimit)
+			if err != nil {
+				return err
+			}
+			printDeadMessages(cmd.OutOrStdout(), msgs)
+			return nil
+		})
+	},
+}
+
+var dlqShowCmd = &cobra.Command{
+	Use:   "show <dead-letter> <position>",
+	Short: "Show one message with the headers, the x-death history and the body",
+	Long: `Show one message with the headers, the x-death history and the body.
+The position is the one printed by the list command, the filters are not used.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return errors.Errorf("invalid position \"%s\", expecting a number greater than zero", args[1])
+		}
+		return withDeadLetterQueue(cmd, args[0], func(q *rabbit.DeadLetterQueue, _ rabbit.DeadLetterFilter) error {
+			msgs, err := q.Browse(rabbit.DeadLetterFilter{}, n)
+			if err != nil {
+				return err
+			}
+			if len(msgs) < n {
+				return errors.Errorf("the queue %s has %d messages", q.Queue(), len(msgs))
+			}
+			printDeadMessage(cmd.OutOrStdout(), msgs[n-1])
+			return nil
+		})
+	},
+}
+
+var dlqReplayCmd = &cobra.Command{
+	Use:   "replay <dead-letter>",
+	Short: "Publish the messages again to the exchange and routing key from the x-death header",
+	Long: `Publish the messages again to the exchange and routing key from the x-death header.
+Every message is removed from the dead letter queue only after the broker confirms the publish.
+Use --exchange and --to-routing-key to publish to another destination.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withDeadLetterQueue(cmd, args[0], func(q *rabbit.DeadLetterQueue, filter rabbit.DeadLetterFilter) error {
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+			n, err := q.Replay(ctx, filter, rabbit.ReplayOptions{
+				Limit:      dlqFlags.limit,
+				Rate:       dlqFlags.rate,
+				Exchange:   dlqFlags.exchange,
+				RoutingKey: dlqFlags.toKey,
+			}, func(m rabbit.DeadMessage) {
+				exchange, key := m.Origin()
+				if len(dlqFlags.exchange) > 0 || len(dlqFlags.toKey) > 0 {
+					exchange, key = dlqFlags.exchange, dlqFlags.toKey
+				}
+				cmd.Printf("message %d replayed to exchange \"%s\" with routing key \"%s\"\n", m.Position, exchange, key)
+			})
+			cmd.Printf("%d messages replayed from %s\n", n, q.Queue())
+			return err
+		})
+	},
+}
+
+var dlqPurgeCmd = &cobra.Command{
+	Use:   "purge <dead-letter>",
+	Short: "Remove the messages from the dead letter queue",
+	Long: `Remove the messages matching the filters from the dead letter queue.
+Purging the whole queue (without filters and limit) requires the --force flag.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withDeadLetterQueue(cmd, args[0], func(q *rabbit.DeadLetterQueue, filter rabbit.DeadLetterFilter) error {
+			filtered := len(filter.RoutingKey) > 0 || len(filter.Headers) > 0 || len(filter.MessageID) > 0
+			if !filtered && dlqFlags.limit == 0 && !dlqFlags.force {
+				return errors.Errorf("use --force to remove all the messages from %s", q.Queue())
+			}
+			n, err := q.Purge(filter, dlqFlags.limit)
+			cmd.Printf("%d messages removed from %s\n", n, q.Queue())
+			return err
+		})
+	},
+}
+
+func setupDLQFlags() {
+	flags := dlqCmd.PersistentFlags()
+	flags.StringVar(&dlqFlags.connection, "connection", "default", "the connection from the config used to read the queue")
+	flags.StringVarP(&dlqFlags.routingKey, "routing-key", "k", "", "only the messages with this original routing key")
+	flags.StringArrayVarP(&dlqFlags.headers, "header", "H", nil, "only the messages with this header as key=value, can be used multiple times")
+	flags.StringVar(&dlqFlags.messageID, "message-id", "", "only the message with this message-id")
+	dlqListCmd.Flags().IntVarP(&dlqFlags.listLimit, "limit", "n", 50, "the max messages listed, 0 means all")
+	dlqReplayCmd.Flags().IntVarP(&dlqFlags.limit, "limit", "n", 0, "the max messages replayed, 0 means all")
+	dlqReplayCmd.Flags().Float64Var(&dlqFlags.rate, "rate", 10, "the max messages per second, 0 means no limit")
+	dlqReplayCmd.Flags().StringVarP(&dlqFlags.exchange, "exchange", "e", "", "publish to this exchange instead of the one from x-death")
+	dlqReplayCmd.Flags().StringVar(&dlqFlags.toKey, "to-routing-key", "", "publish with this routing key instead of the one from x-death")
+	dlqPurgeCmd.Flags().IntVarP(&dlqFlags.limit, "limit", "n", 0, "the max messages removed, 0 means all")
+	dlqPurgeCmd.Flags().BoolVar(&dlqFlags.force, "force", false, "allow removing all the messages")
+	dlqCmd.AddCommand(dlqListCmd, dlqShowCmd, dlqReplayCmd, dlqPurgeCmd)
+}
+
+// withDeadLetterQueue open the dead letter queue from the config and build the filter from the flags.
+func withDeadLetterQueue(cmd *cobra.Command, name string, fn func(*rabbit.DeadLetterQueue, rabbit.DeadLetterFilter) error) error {
+	err := initConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed initializing the config")
+	}
+	headers, err := parseHeaders(dlqFlags.headers)
+	if err != nil {
+		return err
+	}
+	filter := rabbit.DeadLetterFilter{RoutingKey: dlqFlags.routingKey, MessageID: dlqFlags.messageID}
+	if len(headers) > 0 {
+		filter.Headers = headers
+	}
+	config := rabbit.Config{}
+	err = viper.UnmarshalKey("rabbitmq", &config)
+	if err != nil {
+		return errors.Wrap(err, "problem unmarshaling your config into config struct")
+	}
+	config.Version = version
+	cmd.SilenceUsage = true
+	q, err := rabbit.OpenDeadLetterQueue(config, dlqFlags.connection, name, hub.New())
+	if err != nil {
+		return errors.Wrap(err, "error opening the dead letter queue")
+	}
+	defer q.Close()
+	return fn(q, filter)
+}
+
+func printDeadMessages(out io.Writer, msgs []rabbit.DeadMessage) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tMESSAGE-ID\tEXCHANGE\tROUTING-KEY\tDEATHS\tREASON\tQUEUE\tTIME")
+	for _, m := range msgs {
+		exchange, key := m.Origin()
+		last := rabbit.Death{}
+		if len(m.Deaths) > 0 {
+			last = m.Deaths[0]
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			m.Position, m.MessageID, exchange, key, m.TotalDeaths, last.Reason, last.Queue, formatTime(last.Time))
+	}
+	w.Flush()
+}
+
+func printDeadMessage(out io.Writer, m rabbit.DeadMessage) {
+	exchange, key := m.Origin()
+	fmt.Fprintf(out, "Position:    %d\nMessage-Id:  %s\nExchange:    %s\nRouting-Key: %s\nDeaths:      %d\n",
+		m.Position, m.MessageID, exchange, key, m.TotalDeaths)
+	fmt.Fprintln(out, "\nHeaders:")
+	names := make([]string, 0, len(m.Headers))
+	for k := range m.Headers {
+		if k != "x-death" {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(out, "  %s: %v\n", k, m.Headers[k])
+	}
+	fmt.Fprintln(out, "\nx-death:")
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  QUEUE\tREASON\tCOUNT\tEXCHANGE\tROUTING-KEYS\tTIME")
+	for _, d := range m.Deaths {
+		fmt.Fprintf(w, "  %s\t%s\t%d\t%s\t%v\t%s\n", d.Queue, d.Reason, d.Count, d.Exchange, d.RoutingKeys, formatTime(d.Time))
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\nBody:\n%s\n", m.Body)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -11,11 +15,18 @@ import (
 	"time"
 
 	"github.com/a8m/envsubst"
+	"github.com/fsnotify/fsnotify"
 	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/kafka"
+	"github.com/leandro-lugaresi/message-cannon/nats"
 	"github.com/leandro-lugaresi/message-cannon/rabbit"
 	"github.com/leandro-lugaresi/message-cannon/subscriber"
+	"github.com/leandro-lugaresi/message-cannon/subscriber/prometheus"
 	"github.com/leandro-lugaresi/message-cannon/supervisor"
 	"github.com/pkg/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -40,25 +51,81 @@ var launchCmd = &cobra.Command{
 		)
 		go logger.Do()
 		defer logger.Stop()
+		handlers := map[string]map[string]http.Handler{}
+		if addr := viper.GetString("metrics-addr"); len(addr) > 0 {
+			var metrics http.Handler
+			metrics, err = newMetricsHandler(h)
+			if err != nil {
+				return err
+			}
+			addHandler(handlers, addr, "/metrics", metrics)
+		}
 		defer h.Close()
 
-		factories, err := getFactories(h)
+		factories, err := getFactories(viper.GetViper(), h)
 		if err != nil {
 			return err
 		}
 
 		sup := supervisor.NewManager(viper.GetDuration("interval-checks"), h)
+		sup.SetRestartPolicy(supervisor.RestartPolicy{
+			Backoff:     viper.GetDuration("restart-backoff"),
+			MaxBackoff:  viper.GetDuration("restart-max-backoff"),
+			MaxRestarts: viper.GetInt("restart-max"),
+			Window:      viper.GetDuration("restart-window"),
+		})
 		err = sup.Start(factories)
 		if err != nil {
 			return err
 		}
+		if addr := viper.GetString("health-addr"); len(addr) > 0 {
+			timeout := viper.GetDuration("health-timeout")
+			addHandler(handlers, addr, "/healthz", supervisor.HealthHandler(sup, timeout))
+			addHandler(handlers, addr, "/readyz", supervisor.ReadyHandler(sup, timeout))
+		}
+		if addr := viper.GetString("admin-addr"); len(addr) > 0 {
+			admin := supervisor.AdminHandler(sup, viper.GetDuration("admin-timeout"))
+			addHandler(handlers, addr, "/consumers", admin)
+			addHandler(handlers, addr, "/consumers/", admin)
+		}
+		stop, err := startHTTPServers(handlers, h)
+		if err != nil {
+			sup.Stop()
+			return err
+		}
+		defer stop()
+		reloads := make(chan struct{}, 1)
+		if viper.GetBool("watch") {
+			var stopWatch func()
+			stopWatch, err = watchConfig(reloads, h)
+			if err != nil {
+				sup.Stop()
+				return err
+			}
+			defer stopWatch()
+		}
 		osSignals := make(chan os.Signal, 1)
-		signal.Notify(osSignals, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
-		// Block until a signal is received.
-		s := <-osSignals
-		cmd.Printf("signal %s received. shutting down...", s)
-		sup.Stop()
-		return nil
+		signal.Notify(osSignals, os.Interrupt, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+		for {
+			select {
+			case <-reloads:
+				reloadConfig(h, sup)
+			case name := <-sup.Failed():
+				if !viper.GetBool("exit-on-failure") {
+					continue
+				}
+				sup.Shutdown(viper.GetDuration("shutdown-timeout"))
+				return errors.Errorf("the consumer %s was restarted too many times", name)
+			case s := <-osSignals:
+				if s == syscall.SIGHUP {
+					reloadConfig(h, sup)
+					continue
+				}
+				cmd.Printf("signal %s received. shutting down...", s)
+				sup.Shutdown(viper.GetDuration("shutdown-timeout"))
+				return nil
+			}
+		}
 	},
 }
 
@@ -76,6 +143,78 @@ func setupLaunchFlags() {
 		log.Fatal(err)
 	}
 
+	launchCmd.Flags().String("metrics-addr", "", "this flag set the address used to expose the prometheus metrics (ie: :9090)")
+	err = viper.BindPFlag("metrics-addr", launchCmd.Flags().Lookup("metrics-addr"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().String("health-addr", "", "this flag set the address used to expose the /healthz and /readyz endpoints (ie: :8080)")
+	err = viper.BindPFlag("health-addr", launchCmd.Flags().Lookup("health-addr"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().Duration("health-timeout", time.Second, "this flag set how long the health checks wait for the supervisor")
+	err = viper.BindPFlag("health-timeout", launchCmd.Flags().Lookup("health-timeout"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().String("admin-addr", "", "this flag set the address used to expose the admin api to pause, resume, scale and restart consumers (ie: 127.0.0.1:8081)")
+	err = viper.BindPFlag("admin-addr", launchCmd.Flags().Lookup("admin-addr"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().Duration("admin-timeout", 30*time.Second, "this flag set how long the admin api wait for the supervisor operations")
+	err = viper.BindPFlag("admin-timeout", launchCmd.Flags().Lookup("admin-timeout"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().Duration("shutdown-timeout", 30*time.Second, "this flag set how long the shutdown wait for the messages in flight before canceling and requeuing them, 0 means no limit")
+	err = viper.BindPFlag("shutdown-timeout", launchCmd.Flags().Lookup("shutdown-timeout"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().Duration("restart-backoff", 0, "this flag set the wait before restarting one dead consumer again, doubled on every restart, 0 means restart on every interval-checks")
+	err = viper.BindPFlag("restart-backoff", launchCmd.Flags().Lookup("restart-backoff"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().Duration("restart-max-backoff", time.Minute, "this flag set the max wait between the restarts of one consumer")
+	err = viper.BindPFlag("restart-max-backoff", launchCmd.Flags().Lookup("restart-max-backoff"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().Int("restart-max", 0, "this flag set how many restarts inside the restart-window put one consumer in the failed state, 0 means no limit")
+	err = viper.BindPFlag("restart-max", launchCmd.Flags().Lookup("restart-max"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().Duration("restart-window", 5*time.Minute, "this flag set the window used to count the restarts, the consumers running longer than the window have the backoff reset")
+	err = viper.BindPFlag("restart-window", launchCmd.Flags().Lookup("restart-window"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().Bool("exit-on-failure", false, "this flag make the process exit with an error when one consumer fails, so the orchestrator can restart it")
+	err = viper.BindPFlag("exit-on-failure", launchCmd.Flags().Lookup("exit-on-failure"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	launchCmd.Flags().BoolP("watch", "w", false, "this flag enable the config reload when the config file changes (SIGHUP always reload)")
+	err = viper.BindPFlag("watch", launchCmd.Flags().Lookup("watch"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	launchCmd.Flags().IntP("log-buffer", "b", 300, "this flag set the buffer size of the log and metrics systems")
 	err = viper.BindPFlag("log-buffer", launchCmd.Flags().Lookup("log-buffer"))
 	if err != nil {
@@ -83,22 +222,204 @@ func setupLaunchFlags() {
 	}
 }
 
+// newMetricsHandler start the metrics subscriber and return the handler exposing them.
+func newMetricsHandler(h *hub.Hub) (http.Handler, error) {
+	reg := prom.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	metrics, err := prometheus.NewMetrics(h.NonBlockingSubscribe(viper.GetInt("log-buffer"), prometheus.Topics...), reg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to register the metrics")
+	}
+	go metrics.Do()
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{}), nil
+}
+
+// addHandler group the handlers by address, so they can share the same listener.
+func addHandler(handlers map[string]map[string]http.Handler, addr, pattern string, handler http.Handler) {
+	if _, ok := handlers[addr]; !ok {
+		handlers[addr] = map[string]http.Handler{}
+	}
+	handlers[addr][pattern] = handler
+}
+
+// startHTTPServers start one http server for every address and return a func to stop all of them.
+func startHTTPServers(handlers map[string]map[string]http.Handler, h *hub.Hub) (func(), error) {
+	servers := []*http.Server{}
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for _, server := range servers {
+			_ = server.Shutdown(ctx)
+		}
+	}
+	for addr, patterns := range handlers {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			stop()
+			return nil, errors.Wrapf(err, "failed to listen the address \"%s\"", addr)
+		}
+		mux := http.NewServeMux()
+		for pattern, handler := range patterns {
+			mux.Handle(pattern, handler)
+		}
+		server := &http.Server{Handler: mux}
+		servers = append(servers, server)
+		go func(addr string) {
+			if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+				h.Publish(hub.Message{
+					Name:   "launch.http.error",
+					Body:   []byte("the http server stopped"),
+					Fields: hub.Fields{"error": err, "addr": addr},
+				})
+			}
+		}(addr)
+	}
+	return stop, nil
+}
+
+// reloadConfig read the config file again and apply it on the supervisor.
+// The new config is read into its own viper and checked like the validate command,
+// invalid configs are rejected and the running config and consumers are kept.
+func reloadConfig(h *hub.Hub, sup *supervisor.Manager) {
+	h.Publish(hub.Message{
+		Name:   "launch.reload.info",
+		Body:   []byte("reloading the config file"),
+		Fields: hub.Fields{"file": cfgFile},
+	})
+	v := viper.New()
+	b, err := readConfigFile()
+	if err == nil {
+		err = parseConfig(v, b)
+	}
+	if err == nil {
+		if problems := validateConfig(v); len(problems) > 0 {
+			err = errors.Errorf("found %d problems: %s", len(problems), strings.Join(problems, "; "))
+		}
+	}
+	if err != nil {
+		h.Publish(hub.Message{
+			Name:   "launch.reload.error",
+			Body:   []byte("invalid config, keeping the running one"),
+			Fields: hub.Fields{"error": err},
+		})
+		return
+	}
+	factories, err := getFactories(v, h)
+	if err != nil {
+		closeFactories(factories)
+		h.Publish(hub.Message{
+			Name:   "launch.reload.error",
+			Body:   []byte("invalid config, keeping the running one"),
+			Fields: hub.Fields{"error": err},
+		})
+		return
+	}
+	// the flags are bound to the global viper, so the valid config is read into it instead of replacing it.
+	err = parseConfig(viper.GetViper(), b)
+	if err != nil {
+		closeFactories(factories)
+		h.Publish(hub.Message{
+			Name:   "launch.reload.error",
+			Body:   []byte("invalid config, keeping the running one"),
+			Fields: hub.Fields{"error": err},
+		})
+		return
+	}
+	err = sup.Reload(factories)
+	if err != nil {
+		h.Publish(hub.Message{
+			Name:   "launch.reload.error",
+			Body:   []byte("the config was partially applied"),
+			Fields: hub.Fields{"error": err},
+		})
+	}
+}
+
+// watchConfig send a reload every time the config file is written or replaced.
+// The directory is watched because most editors replace the file instead of writing it.
+func watchConfig(reloads chan struct{}, h *hub.Hub) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to watch the config file")
+	}
+	file := filepath.Clean(cfgFile)
+	err = watcher.Add(filepath.Dir(file))
+	if err != nil {
+		watcher.Close()
+		return nil, errors.Wrap(err, "failed to watch the config file")
+	}
+	// editors write the file more than once, we wait the writes to settle.
+	debounce := time.AfterFunc(time.Hour, func() {
+		select {
+		case reloads <- struct{}{}:
+		default:
+			// one reload is already waiting
+		}
+	})
+	debounce.Stop()
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != file || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				debounce.Reset(200 * time.Millisecond)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				h.Publish(hub.Message{
+					Name:   "launch.watch.error",
+					Body:   []byte("error watching the config file"),
+					Fields: hub.Fields{"error": err},
+				})
+			}
+		}
+	}()
+	return func() {
+		debounce.Stop()
+		watcher.Close()
+	}, nil
+}
+
+func closeFactories(factories []supervisor.Factory) {
+	for _, f := range factories {
+		if closer, ok := f.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() error {
-	b, err := envsubst.ReadFileRestricted(cfgFile, true, false)
+	b, err := readConfigFile()
 	if err != nil {
-		return errors.Wrap(err, "failed to read the file")
+		return err
 	}
-	viper.SetConfigType(strings.TrimPrefix(filepath.Ext(cfgFile), "."))
-	err = viper.ReadConfig(bytes.NewBuffer(b))
+	return parseConfig(viper.GetViper(), b)
+}
+
+// readConfigFile read the config file replacing the ENV variables.
+func readConfigFile() ([]byte, error) {
+	b, err := envsubst.ReadFileRestricted(cfgFile, true, false)
+	return b, errors.Wrap(err, "failed to read the file")
+}
+
+func parseConfig(v *viper.Viper, b []byte) error {
+	v.SetConfigType(strings.TrimPrefix(filepath.Ext(cfgFile), "."))
+	err := v.ReadConfig(bytes.NewBuffer(b))
 	return errors.Wrap(err, "failed to unmarshal the initial map of configs")
 }
 
-func getFactories(h *hub.Hub) ([]supervisor.Factory, error) {
+func getFactories(v *viper.Viper, h *hub.Hub) ([]supervisor.Factory, error) {
 	var factories []supervisor.Factory
-	if viper.InConfig("rabbitmq") {
+	if v.InConfig("rabbitmq") {
 		config := rabbit.Config{}
-		err := viper.UnmarshalKey("rabbitmq", &config)
+		err := v.UnmarshalKey("rabbitmq", &config)
 		if err != nil {
 			return factories, errors.Wrap(err, "problem unmarshaling your config into config struct")
 		}
@@ -110,5 +431,33 @@ func getFactories(h *hub.Hub) ([]supervisor.Factory, error) {
 		}
 		factories = append(factories, rFactory)
 	}
+	if v.InConfig("nats") {
+		config := nats.Config{}
+		err := v.UnmarshalKey("nats", &config)
+		if err != nil {
+			return factories, errors.Wrap(err, "problem unmarshaling your config into config struct")
+		}
+		config.Version = version
+		var nFactory *nats.Factory
+		nFactory, err = nats.NewFactory(config, h)
+		if err != nil {
+			return factories, errors.Wrap(err, "error creating the NATS factory")
+		}
+		factories = append(factories, nFactory)
+	}
+	if v.InConfig("kafka") {
+		config := kafka.Config{}
+		err := v.UnmarshalKey("kafka", &config)
+		if err != nil {
+			return factories, errors.Wrap(err, "problem unmarshaling your config into config struct")
+		}
+		config.Version = version
+		var kFactory *kafka.Factory
+		kFactory, err = kafka.NewFactory(config, h)
+		if err != nil {
+			return factories, errors.Wrap(err, "error creating the kafka factory")
+		}
+		factories = append(factories, kFactory)
+	}
 	return factories, nil
 }
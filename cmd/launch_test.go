@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_reloadConfig_invalid(t *testing.T) {
+	loadConfig(t, `
+exit-on-failure: true
+rabbitmq:
+  consumers:
+    test:
+      queue:
+        name: test
+`)
+	h := hub.New()
+	defer h.Close()
+	sub := h.Subscribe(10, "launch.reload.*")
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{name: "unknown key", config: "exit-on-failure: false\nredis:\n  url: localhost\n"},
+		{name: "invalid yaml", config: "rabbitmq: [\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(cfgFile, []byte(tt.config), 0600))
+			reloadConfig(h, supervisor.NewManager(time.Second, h))
+			require.Equal(t, "launch.reload.info", (<-sub.Receiver).Name)
+			msg := <-sub.Receiver
+			require.Equal(t, "launch.reload.error", msg.Name)
+			require.Equal(t, "invalid config, keeping the running one", string(msg.Body))
+			// the running config is kept
+			require.True(t, viper.GetBool("exit-on-failure"))
+			require.True(t, viper.InConfig("rabbitmq"))
+			require.False(t, viper.InConfig("redis"))
+		})
+	}
+}
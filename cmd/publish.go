@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/rabbit"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/streadway/amqp"
+)
+
+var publishFlags struct {
+	connection    string
+	exchange      string
+	routingKey    string
+	file          string
+	contentType   string
+	correlationID string
+	messageID     string
+	headers       []string
+	persistent    bool
+	repeat        int
+	rate          float64
+	confirm       bool
+	mandatory     bool
+}
+
+// publishCmd represents the publish command
+var publishCmd = &cobra.Command{
+	Use:   "publish [body]",
+	Short: "Publish will send messages to rabbitMQ using the connections and exchanges from the config file",
+	Long: `Publish will send messages to rabbitMQ using the connections and exchanges from the config file.
+The body is read from the argument, from a file (--file) or from the stdin (--file -).`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := initConfig()
+		if err != nil {
+			return errors.Wrap(err, "failed initializing the config")
+		}
+		body, err := publishBody(args, os.Stdin)
+		if err != nil {
+			return err
+		}
+		headers, err := publishHeaders(publishFlags.headers)
+		if err != nil {
+			return err
+		}
+		if publishFlags.repeat < 1 {
+			return errors.New("the repeat must be greater than zero")
+		}
+		config := rabbit.Config{}
+		err = viper.UnmarshalKey("rabbitmq", &config)
+		if err != nil {
+			return errors.Wrap(err, "problem unmarshaling your config into config struct")
+		}
+		config.Version = version
+		cmd.SilenceUsage = true
+		p, err := rabbit.NewPublisher(config, rabbit.PublisherOptions{
+			Connection: publishFlags.connection,
+			Exchange:   publishFlags.exchange,
+			Confirm:    publishFlags.confirm,
+			Mandatory:  publishFlags.mandatory,
+		}, hub.New())
+		if err != nil {
+			return errors.Wrap(err, "error creating the rabbitMQ publisher")
+		}
+		defer p.Close()
+		msg := amqp.Publishing{
+			Headers:       headers,
+			ContentType:   publishFlags.contentType,
+			CorrelationId: publishFlags.correlationID,
+			MessageId:     publishFlags.messageID,
+			Body:          body,
+		}
+		if publishFlags.persistent {
+			msg.DeliveryMode = amqp.Persistent
+		}
+		var tick <-chan time.Time
+		if publishFlags.rate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / publishFlags.rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		failed := 0
+		for i := 1; i <= publishFlags.repeat; i++ {
+			if tick != nil && i > 1 {
+				<-tick
+			}
+			msg.Timestamp = time.Now()
+			err = p.Publish(publishFlags.routingKey, msg)
+			switch {
+			case err != nil:
+				failed++
+				cmd.Printf("message %d/%d failed: %s\n", i, publishFlags.repeat, err)
+			case publishFlags.confirm:
+				cmd.Printf("message %d/%d accepted by the broker\n", i, publishFlags.repeat)
+			default:
+				cmd.Printf("message %d/%d sent\n", i, publishFlags.repeat)
+			}
+		}
+		if failed > 0 {
+			return errors.Errorf("%d of %d messages failed", failed, publishFlags.repeat)
+		}
+		return nil
+	},
+}
+
+func setupPublishFlags() {
+	flags := publishCmd.Flags()
+	flags.StringVar(&publishFlags.connection, "connection", "default", "the connection from the config used to publish")
+	flags.StringVarP(&publishFlags.exchange, "exchange", "e", "", "the exchange receiving the messages, declared when present in the config")
+	flags.StringVarP(&publishFlags.routingKey, "routing-key", "k", "", "the routing key of the messages")
+	flags.StringVarP(&publishFlags.file, "file", "f", "", "read the body from a file, use - to read from the stdin")
+	flags.StringVar(&publishFlags.contentType, "content-type", "", "the content-type property (ie: application/json)")
+	flags.StringVar(&publishFlags.correlationID, "correlation-id", "", "the correlation-id property")
+	flags.StringVar(&publishFlags.messageID, "message-id", "", "the message-id property")
+	flags.StringArrayVarP(&publishFlags.headers, "header", "H", nil, "one header as key=value, can be used multiple times")
+	flags.BoolVar(&publishFlags.persistent, "persistent", false, "publish the messages as persistent")
+	flags.IntVarP(&publishFlags.repeat, "repeat", "n", 1, "the number of times the message is published")
+	flags.Float64Var(&publishFlags.rate, "rate", 0, "the max messages per second, 0 means no limit")
+	flags.BoolVar(&publishFlags.confirm, "confirm", true, "wait the broker confirmation of every message")
+	flags.BoolVar(&publishFlags.mandatory, "mandatory", false, "fail the messages not routed to any queue")
+}
+
+// publishBody read the body from the argument or the file.
+func publishBody(args []string, stdin io.Reader) ([]byte, error) {
+	switch {
+	case len(args) > 0 && len(publishFlags.file) > 0:
+		return nil, errors.New("use the body argument or the --file flag, not both")
+	case len(args) > 0:
+		return []byte(args[0]), nil
+	case publishFlags.file == "-":
+		b, err := ioutil.ReadAll(stdin)
+		return b, errors.Wrap(err, "failed to read the body from the stdin")
+	case len(publishFlags.file) > 0:
+		b, err := ioutil.ReadFile(publishFlags.file)
+		return b, errors.Wrap(err, "failed to read the body")
+	}
+	return nil, errors.New("the body is required: use the argument, --file or --file - for the stdin")
+}
+
+func publishHeaders(headers []string) (amqp.Table, error) {
+	parsed, err := parseHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	table := amqp.Table{}
+	for k, v := range parsed {
+		table[k] = v
+	}
+	return table, nil
+}
+
+// parseHeaders parse the --header key=value flags used by the publish, dlq and run commands.
+func parseHeaders(headers []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(headers))
+	for _, h := range headers {
+		kv := strings.SplitN(h, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return nil, errors.Errorf("invalid header \"%s\" expecting key=value", h)
+		}
+		parsed[kv[0]] = kv[1]
+	}
+	return parsed, nil
+}
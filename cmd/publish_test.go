@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_publishBody(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "body.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"from":"file"}`), 0600))
+	tests := []struct {
+		name    string
+		args    []string
+		file    string
+		want    string
+		wantErr string
+	}{
+		{name: "argument", args: []string{`{"from":"arg"}`}, want: `{"from":"arg"}`},
+		{name: "file", file: file, want: `{"from":"file"}`},
+		{name: "stdin", file: "-", want: `{"from":"stdin"}`},
+		{name: "argument and file", args: []string{"body"}, file: file, wantErr: "use the body argument or the --file flag, not both"},
+		{name: "missing file", file: filepath.Join(t.TempDir(), "missing.json"), wantErr: "failed to read the body"},
+		{name: "no body", wantErr: "the body is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publishFlags.file = tt.file
+			defer func() { publishFlags.file = "" }()
+			body, err := publishBody(tt.args, strings.NewReader(`{"from":"stdin"}`))
+			if len(tt.wantErr) > 0 {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(body))
+		})
+	}
+}
+
+func Test_parseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", want: map[string]string{}},
+		{name: "key and value", headers: []string{"Message-Id=1", "tenant=acme"}, want: map[string]string{"Message-Id": "1", "tenant": "acme"}},
+		{name: "value with equals", headers: []string{"filter=a=b"}, want: map[string]string{"filter": "a=b"}},
+		{name: "empty value", headers: []string{"empty="}, want: map[string]string{"empty": ""}},
+		{name: "missing value", headers: []string{"Message-Id"}, wantErr: true},
+		{name: "missing key", headers: []string{"=1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHeaders(tt.headers)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_publishHeaders(t *testing.T) {
+	table, err := publishHeaders([]string{"Message-Id=1", "tenant=acme"})
+	require.NoError(t, err)
+	require.Equal(t, amqp.Table{"Message-Id": "1", "tenant": "acme"}, table)
+	_, err = publishHeaders([]string{"invalid"})
+	require.EqualError(t, err, `invalid header "invalid" expecting key=value`)
+}
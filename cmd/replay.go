@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/creasty/defaults"
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/kafka"
+	"github.com/leandro-lugaresi/message-cannon/nats"
+	"github.com/leandro-lugaresi/message-cannon/rabbit"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var replayFlags struct {
+	files    []string
+	consumer string
+	all      bool
+}
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Process the messages recorded by the tap again with the consumer runner, without a broker",
+	Long: `Process the messages recorded by the tap again with the runner of one consumer from the config file.
+The messages are processed locally, without a broker, and the exit codes different from the recorded ones are printed.
+The rotated files can be replayed in order using --file multiple times, ie: --file tap.log.1 --file tap.log.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := initConfig()
+		if err != nil {
+			return errors.Wrap(err, "failed initializing the config")
+		}
+		target, err := findConsumer(replayFlags.consumer)
+		if err != nil {
+			return err
+		}
+		cfg := target.runner
+		cmd.SilenceUsage = true
+		r, err := runner.New(cfg, hub.New())
+		if err != nil {
+			return errors.Wrap(err, "error creating the runner")
+		}
+		if closer, ok := r.(io.Closer); ok {
+			defer closer.Close()
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+		out := cmd.OutOrStdout()
+		total, diffs := 0, 0
+		for _, file := range replayFlags.files {
+			n := 0
+			err = runner.ReadRecords(file, func(rec runner.Record) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				n++
+				total++
+				status, _, perr := processMessage(ctx, r, cfg, rec.Message())
+				if status != rec.Status {
+					diffs++
+					fmt.Fprintf(out, "- %s:%d recorded %d (%s)\n+ %s:%d replayed %d (%s)\n",
+						file, n, rec.Status, describeError(rec.Error), file, n, status, describeError(errorString(perr)))
+				} else if replayFlags.all {
+					fmt.Fprintf(out, "  %s:%d exit %d\n", file, n, status)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(out, "%d messages replayed, %d with a different exit code\n", total, diffs)
+		if diffs > 0 {
+			return errors.Errorf("%d exit codes are different from the recorded ones", diffs)
+		}
+		return nil
+	},
+}
+
+func setupReplayFlags() {
+	flags := replayCmd.Flags()
+	flags.StringArrayVarP(&replayFlags.files, "file", "f", nil, "the tap file with the recorded messages, can be used multiple times")
+	flags.StringVarP(&replayFlags.consumer, "consumer", "c", "", "the consumer from the config file whose runner process the messages")
+	flags.BoolVar(&replayFlags.all, "all", false, "print the exit code of every message, not only the different ones")
+	_ = replayCmd.MarkFlagRequired("file")
+	_ = replayCmd.MarkFlagRequired("consumer")
+}
+
+// consumerTarget is the runner of one consumer from the config file
+// and the description of what the consumer does with every exit code.
+type consumerTarget struct {
+	broker string
+	runner runner.Config
+	action func(status int) string
+}
+
+// findConsumer find one consumer from any broker in the config file, with the default values.
+// The tap and the circuit breaker are disabled, the messages processed locally must not be recorded or skipped.
+func findConsumer(name string) (consumerTarget, error) {
+	found := []consumerTarget{}
+	rabbitConfig := rabbit.Config{}
+	if err := viper.UnmarshalKey("rabbitmq", &rabbitConfig); err != nil {
+		return consumerTarget{}, errors.Wrap(err, "problem unmarshaling your config into config struct")
+	}
+	if c, ok := rabbitConfig.Consumers[name]; ok {
+		if err := defaults.Set(&c); err != nil {
+			return consumerTarget{}, errors.Wrap(err, "failed to set default values for configs")
+		}
+		c.Runner.SetDefaults(version, c.MaxWorkers)
+		found = append(found, consumerTarget{"rabbitmq", c.Runner, c.Action})
+	}
+	natsConfig := nats.Config{}
+	if err := viper.UnmarshalKey("nats", &natsConfig); err != nil {
+		return consumerTarget{}, errors.Wrap(err, "problem unmarshaling your config into config struct")
+	}
+	if c, ok := natsConfig.Consumers[name]; ok {
+		if err := defaults.Set(&c); err != nil {
+			return consumerTarget{}, errors.Wrap(err, "failed to set default values for configs")
+		}
+		c.Runner.SetDefaults(version, c.MaxWorkers)
+		found = append(found, consumerTarget{"nats", c.Runner, c.Action})
+	}
+	kafkaConfig := kafka.Config{}
+	if err := viper.UnmarshalKey("kafka", &kafkaConfig); err != nil {
+		return consumerTarget{}, errors.Wrap(err, "problem unmarshaling your config into config struct")
+	}
+	if c, ok := kafkaConfig.Consumers[name]; ok {
+		if err := defaults.Set(&c); err != nil {
+			return consumerTarget{}, errors.Wrap(err, "failed to set default values for configs")
+		}
+		c.Runner.SetDefaults(version, c.MaxWorkers)
+		found = append(found, consumerTarget{"kafka", c.Runner, c.Action})
+	}
+	if len(found) == 0 {
+		return consumerTarget{}, errors.Errorf("consumer \"%s\" did not exist", name)
+	}
+	if len(found) > 1 {
+		return consumerTarget{}, errors.Errorf("consumer \"%s\" exists in more than one broker", name)
+	}
+	t := found[0]
+	t.runner.Tap = runner.TapConfig{}
+	t.runner.Circuit = runner.CircuitConfig{}
+	return t, nil
+}
+
+// processMessage call the runner locally using the runner timeout, like the consumers.
+func processMessage(ctx context.Context, r runner.Runnable, cfg runner.Config, msg runner.Message) (int, []byte, error) {
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+	return runner.ProcessOutput(ctx, r, msg)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func describeError(s string) string {
+	if len(s) == 0 {
+		return "no error"
+	}
+	return s
+}
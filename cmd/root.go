@@ -28,6 +28,15 @@ func Execute() {
 
 	setupLaunchFlags()
 	RootCmd.AddCommand(launchCmd)
+	RootCmd.AddCommand(validateCmd)
+	setupPublishFlags()
+	RootCmd.AddCommand(publishCmd)
+	setupDLQFlags()
+	RootCmd.AddCommand(dlqCmd)
+	setupReplayFlags()
+	RootCmd.AddCommand(replayCmd)
+	setupRunFlags()
+	RootCmd.AddCommand(runCmd)
 
 	if err := RootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var runFlags struct {
+	file    string
+	headers []string
+}
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run <consumer>",
+	Short: "Process one message with the runner of one consumer from the config file, without a broker",
+	Long: `Process one message with the runner of one consumer from the config file, without a broker.
+The body is read from a file (--file) or from the stdin and the headers from --header key=value.
+The exit code, what the consumer would do with the message, the duration and the output are printed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := initConfig()
+		if err != nil {
+			return errors.Wrap(err, "failed initializing the config")
+		}
+		target, err := findConsumer(args[0])
+		if err != nil {
+			return err
+		}
+		msg, err := runMessage(os.Stdin)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		r, err := runner.New(target.runner, hub.New())
+		if err != nil {
+			return errors.Wrap(err, "error creating the runner")
+		}
+		if closer, ok := r.(io.Closer); ok {
+			defer closer.Close()
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+		start := time.Now()
+		status, output, perr := processMessage(ctx, r, target.runner, msg)
+		printRunResult(cmd.OutOrStdout(), target, status, time.Since(start), output, perr)
+		return nil
+	},
+}
+
+func setupRunFlags() {
+	flags := runCmd.Flags()
+	flags.StringVarP(&runFlags.file, "file", "f", "-", "the file with the message body, - for the stdin")
+	flags.StringArrayVarP(&runFlags.headers, "header", "H", nil, "one header as key=value, can be used multiple times")
+}
+
+// runMessage read the body and the headers from the flags.
+func runMessage(stdin io.Reader) (runner.Message, error) {
+	msg := runner.Message{Headers: runner.Headers{}}
+	headers, err := parseHeaders(runFlags.headers)
+	if err != nil {
+		return msg, err
+	}
+	for k, v := range headers {
+		msg.Headers[k] = v
+	}
+	if runFlags.file == "-" {
+		msg.Body, err = ioutil.ReadAll(stdin)
+		return msg, errors.Wrap(err, "failed to read the body from the stdin")
+	}
+	msg.Body, err = ioutil.ReadFile(runFlags.file)
+	return msg, errors.Wrap(err, "failed to read the body")
+}
+
+func printRunResult(out io.Writer, target consumerTarget, status int, duration time.Duration, output []byte, err error) {
+	fmt.Fprintf(out, "Exit code: %d\nAction:    %s (%s)\nDuration:  %s\n", status, target.action(status), target.broker, duration)
+	if err != nil {
+		fmt.Fprintf(out, "Error:     %s\n", err)
+		if e, ok := err.(*runner.Error); ok {
+			output = e.Output
+		}
+	}
+	if len(output) > 0 {
+		fmt.Fprintf(out, "\nOutput:\n%s\n", output)
+	}
+}
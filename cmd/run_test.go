@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_runMessage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "body.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"from":"file"}`), 0600))
+	tests := []struct {
+		name    string
+		file    string
+		headers []string
+		want    runner.Message
+		wantErr string
+	}{
+		{
+			name: "stdin",
+			file: "-",
+			want: runner.Message{Body: []byte(`{"from":"stdin"}`), Headers: runner.Headers{}},
+		},
+		{
+			name:    "file with headers",
+			file:    file,
+			headers: []string{"Message-Id=1", "tenant=acme"},
+			want:    runner.Message{Body: []byte(`{"from":"file"}`), Headers: runner.Headers{"Message-Id": "1", "tenant": "acme"}},
+		},
+		{
+			name:    "missing file",
+			file:    filepath.Join(t.TempDir(), "missing.json"),
+			wantErr: "failed to read the body",
+		},
+		{
+			name:    "invalid header",
+			file:    "-",
+			headers: []string{"Message-Id"},
+			wantErr: `invalid header "Message-Id" expecting key=value`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runFlags.file, runFlags.headers = tt.file, tt.headers
+			defer func() { runFlags.file, runFlags.headers = "-", nil }()
+			msg, err := runMessage(strings.NewReader(`{"from":"stdin"}`))
+			if len(tt.wantErr) > 0 {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, msg)
+		})
+	}
+}
+
+func Test_printRunResult(t *testing.T) {
+	target := consumerTarget{
+		broker: "rabbitmq",
+		action: func(status int) string {
+			if status == runner.ExitACK {
+				return "ack"
+			}
+			return "reject"
+		},
+	}
+	tests := []struct {
+		name   string
+		status int
+		output []byte
+		err    error
+		want   string
+	}{
+		{
+			name:   "success with output",
+			status: runner.ExitACK,
+			output: []byte("done"),
+			want:   "Exit code: 0\nAction:    ack (rabbitmq)\nDuration:  2s\n\nOutput:\ndone\n",
+		},
+		{
+			name:   "success without output",
+			status: runner.ExitACK,
+			want:   "Exit code: 0\nAction:    ack (rabbitmq)\nDuration:  2s\n",
+		},
+		{
+			name:   "runner error with the output",
+			status: runner.ExitFailed,
+			err:    &runner.Error{Err: errors.New("exit status 1"), Output: []byte("stack trace")},
+			want:   "Exit code: 1\nAction:    reject (rabbitmq)\nDuration:  2s\nError:     exit status 1\n\nOutput:\nstack trace\n",
+		},
+		{
+			name:   "other error",
+			status: runner.ExitFailed,
+			err:    errors.New("context canceled"),
+			want:   "Exit code: 1\nAction:    reject (rabbitmq)\nDuration:  2s\nError:     context canceled\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			printRunResult(out, target, tt.status, 2*time.Second, tt.output, tt.err)
+			require.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func Test_findConsumer(t *testing.T) {
+	loadConfig(t, `
+rabbitmq:
+  consumers:
+    upload:
+      workers: 4
+      runner:
+        type: http
+        options:
+          url: http://localhost:8080
+        tap:
+          path: /tmp/tap.log
+        circuit:
+          consecutive-failures: 5
+    duplicated:
+      runner:
+        type: command
+nats:
+  consumers:
+    orders:
+      subject: orders
+      workers: 3
+      runner:
+        type: fastcgi
+    duplicated:
+      runner:
+        type: command
+kafka:
+  consumers:
+    events:
+      topics: [events]
+      runner:
+        type: http
+        options:
+          url: http://localhost:8080
+`)
+	tests := []struct {
+		name       string
+		consumer   string
+		wantBroker string
+		wantErr    string
+		check      func(t *testing.T, c runner.Config)
+	}{
+		{
+			name:       "rabbitmq without tap and circuit",
+			consumer:   "upload",
+			wantBroker: "rabbitmq",
+			check: func(t *testing.T, c runner.Config) {
+				require.Equal(t, "message-cannon/"+version, c.Options.Headers["User-Agent"])
+				require.Equal(t, runner.TapConfig{}, c.Tap)
+				require.Equal(t, runner.CircuitConfig{}, c.Circuit)
+			},
+		},
+		{
+			name:       "nats with one connection per worker",
+			consumer:   "orders",
+			wantBroker: "nats",
+			check: func(t *testing.T, c runner.Config) {
+				require.Equal(t, 3, c.Options.Connections)
+			},
+		},
+		{
+			name:       "kafka with the user agent",
+			consumer:   "events",
+			wantBroker: "kafka",
+			check: func(t *testing.T, c runner.Config) {
+				require.Equal(t, "message-cannon/"+version, c.Options.Headers["User-Agent"])
+			},
+		},
+		{name: "missing", consumer: "missing", wantErr: `consumer "missing" did not exist`},
+		{name: "duplicated", consumer: "duplicated", wantErr: `consumer "duplicated" exists in more than one broker`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := findConsumer(tt.consumer)
+			if len(tt.wantErr) > 0 {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantBroker, target.broker)
+			require.NotEmpty(t, target.action(runner.ExitACK))
+			tt.check(t, target.runner)
+		})
+	}
+}
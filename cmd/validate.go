@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/leandro-lugaresi/message-cannon/kafka"
+	"github.com/leandro-lugaresi/message-cannon/nats"
+	"github.com/leandro-lugaresi/message-cannon/rabbit"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate will check the config file without starting any consumer",
+	Long: `Validate will check the config file without opening any connection.
+All the problems found are printed with their path and the command exit with an error.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		err := initConfig()
+		if err != nil {
+			return errors.Wrap(err, "failed initializing the config")
+		}
+		problems := validateConfig(viper.GetViper())
+		for _, p := range problems {
+			cmd.Println(p)
+		}
+		if len(problems) > 0 {
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			return errors.Errorf("found %d problems in the config file %s", len(problems), cfgFile)
+		}
+		cmd.Printf("the config file %s is valid\n", cfgFile)
+		return nil
+	},
+}
+
+// sections have the validation of every factory config.
+var sections = map[string]func(v *viper.Viper) []string{
+	"rabbitmq": func(v *viper.Viper) []string {
+		problems := unmarshalStrict(v, "rabbitmq", &rabbit.Config{})
+		config := rabbit.Config{}
+		if err := v.UnmarshalKey("rabbitmq", &config); err != nil {
+			return problems
+		}
+		return append(problems, prefixErrors("rabbitmq", rabbit.Validate(config))...)
+	},
+	"nats": func(v *viper.Viper) []string {
+		problems := unmarshalStrict(v, "nats", &nats.Config{})
+		config := nats.Config{}
+		if err := v.UnmarshalKey("nats", &config); err != nil {
+			return problems
+		}
+		return append(problems, prefixErrors("nats", nats.Validate(config))...)
+	},
+	"kafka": func(v *viper.Viper) []string {
+		problems := unmarshalStrict(v, "kafka", &kafka.Config{})
+		config := kafka.Config{}
+		if err := v.UnmarshalKey("kafka", &config); err != nil {
+			return problems
+		}
+		return append(problems, prefixErrors("kafka", kafka.Validate(config))...)
+	},
+}
+
+// validateConfig check the config read into the viper instance, used by the validate command and the reloads.
+func validateConfig(v *viper.Viper) []string {
+	problems := []string{}
+	keys := []string{}
+	for key := range v.AllSettings() {
+		// the settings have the flags too
+		if v.InConfig(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		validate, ok := sections[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown key", key))
+			continue
+		}
+		problems = append(problems, validate(v)...)
+	}
+	if len(keys) == 0 {
+		problems = append(problems, "the config file didn't have any consumer configuration")
+	}
+	return problems
+}
+
+// unmarshalStrict decode the config returning the unknown keys and the invalid types as problems.
+// The decoded config is discarded because mapstructure didn't fill the maps with errors.
+func unmarshalStrict(v *viper.Viper, key string, config interface{}) []string {
+	err := v.UnmarshalKey(key, config, func(c *mapstructure.DecoderConfig) {
+		c.ErrorUnused = true
+	})
+	if err == nil {
+		return nil
+	}
+	merr, ok := err.(*mapstructure.Error)
+	if !ok {
+		return []string{fmt.Sprintf("%s: %s", key, err)}
+	}
+	problems := []string{}
+	for _, e := range merr.Errors {
+		problems = append(problems, mapstructurePath(key, e))
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+var quotedPath = regexp.MustCompile(`'([^']*)'\s*`)
+
+// mapstructurePath rewrite the mapstructure errors, ie: "'consumers[test].queue' has invalid keys: foo"
+// is transformed into "rabbitmq.consumers.test.queue: has invalid keys: foo".
+func mapstructurePath(key, msg string) string {
+	m := quotedPath.FindStringSubmatchIndex(msg)
+	if m == nil {
+		return fmt.Sprintf("%s: %s", key, msg)
+	}
+	path := msg[m[2]:m[3]]
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if len(path) > 0 {
+		key = key + "." + path
+	}
+	return fmt.Sprintf("%s: %s", key, strings.Join(strings.Fields(msg[:m[0]]+" "+msg[m[1]:]), " "))
+}
+
+func prefixErrors(key string, errs []error) []string {
+	problems := []string{}
+	for _, err := range errs {
+		problems = append(problems, fmt.Sprintf("%s.%s", key, err))
+	}
+	return problems
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+// loadConfig write the config into a temp file and read it like the commands.
+func loadConfig(t *testing.T, content string) {
+	t.Helper()
+	cfgFile = filepath.Join(t.TempDir(), "cannon.yml")
+	require.NoError(t, os.WriteFile(cfgFile, []byte(content), 0600))
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	require.NoError(t, initConfig())
+}
+
+func Test_mapstructurePath(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{
+			msg:  "'consumers[test].queue' has invalid keys: foo",
+			want: "rabbitmq.consumers.test.queue: has invalid keys: foo",
+		},
+		{
+			msg:  "'consumers[test].workers' expected type 'int', got unconvertible type 'string'",
+			want: "rabbitmq.consumers.test.workers: expected type 'int', got unconvertible type 'string'",
+		},
+		{
+			msg:  "'' has invalid keys: foo",
+			want: "rabbitmq: has invalid keys: foo",
+		},
+		{
+			msg:  "something went wrong",
+			want: "rabbitmq: something went wrong",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			require.Equal(t, tt.want, mapstructurePath("rabbitmq", tt.msg))
+		})
+	}
+}
+
+func Test_prefixErrors(t *testing.T) {
+	require.Empty(t, prefixErrors("nats", nil))
+	require.Equal(t,
+		[]string{"nats.consumers.test.subject: the subject is required", "nats.connections.default.url: invalid url"},
+		prefixErrors("nats", []error{
+			errors.New("consumers.test.subject: the subject is required"),
+			errors.New("connections.default.url: invalid url"),
+		}))
+}
+
+func Test_validateConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "valid config",
+			config: `
+rabbitmq:
+  connections:
+    default:
+      dsn: amqp://localhost:5672
+  consumers:
+    test:
+      connection: default
+      queue:
+        name: test
+      runner:
+        type: http
+        options:
+          url: http://localhost:8080
+`,
+			want: []string{},
+		},
+		{
+			name:   "empty config",
+			config: "{}",
+			want:   []string{"the config file didn't have any consumer configuration"},
+		},
+		{
+			name: "unknown keys",
+			config: `
+redis:
+  url: localhost
+rabbitmq:
+  connections:
+    default:
+      dsn: amqp://localhost:5672
+  consumers:
+    test:
+      connection: default
+      queue:
+        name: test
+        foo: bar
+      runner:
+        type: http
+        options:
+          url: http://localhost:8080
+`,
+			want: []string{
+				"rabbitmq.consumers.test.queue: has invalid keys: foo",
+				"redis: unknown key",
+			},
+		},
+		{
+			name: "invalid references",
+			config: `
+rabbitmq:
+  consumers:
+    test:
+      connection: missing
+      queue:
+        name: test
+      runner:
+        type: http
+        options:
+          url: http://localhost:8080
+`,
+			want: []string{`rabbitmq.consumers.test.connection: connection "missing" did not exist`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadConfig(t, tt.config)
+			require.Equal(t, tt.want, validateConfig(viper.GetViper()))
+		})
+	}
+}
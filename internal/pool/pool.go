@@ -0,0 +1,68 @@
+// Package pool limit the messages processed at the same time by the consumers.
+package pool
+
+import "sync"
+
+// Pool limit the workers in flight, the size can be changed while the consumer is running.
+type Pool struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	size     int
+	inFlight int
+}
+
+// New create one pool with size workers.
+func New(size int) *Pool {
+	p := &Pool{size: size}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Acquire block until one worker is free and take it.
+func (p *Pool) Acquire() {
+	p.mu.Lock()
+	for p.inFlight >= p.size {
+		p.cond.Wait()
+	}
+	p.inFlight++
+	p.mu.Unlock()
+}
+
+// Release free the worker taken by Acquire.
+func (p *Pool) Release() {
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+	p.cond.Broadcast()
+}
+
+// Wait block until all the workers in flight finish.
+func (p *Pool) Wait() {
+	p.mu.Lock()
+	for p.inFlight > 0 {
+		p.cond.Wait()
+	}
+	p.mu.Unlock()
+}
+
+// Resize change the number of workers. The workers in flight above the new size are not interrupted.
+func (p *Pool) Resize(size int) {
+	p.mu.Lock()
+	p.size = size
+	p.mu.Unlock()
+	p.cond.Broadcast()
+}
+
+// Size return the max number of workers.
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// InFlight return the number of workers processing messages.
+func (p *Pool) InFlight() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inFlight
+}
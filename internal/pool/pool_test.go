@@ -0,0 +1,48 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_Resize(t *testing.T) {
+	p := New(1)
+	p.Acquire()
+	acquired := make(chan struct{})
+	go func() {
+		p.Acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("the pool should be full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	p.Resize(2)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("the resize should release the waiting worker")
+	}
+	assert.Equal(t, 2, p.Size())
+	assert.Equal(t, 2, p.InFlight())
+
+	p.Resize(1)
+	p.Release()
+	assert.Equal(t, 1, p.InFlight())
+	waited := make(chan struct{})
+	go func() {
+		p.Wait()
+		close(waited)
+	}()
+	p.Release()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("wait should return after all the workers finish")
+	}
+	require.Equal(t, 0, p.InFlight())
+}
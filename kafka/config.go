@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+)
+
+// Config describes all available options for kafka consumers.
+type Config struct {
+	// Connections describe the brokers used by consumers.
+	Connections map[string]Connection `mapstructure:"connections" default:"{}"`
+	// Consumers describes configuration list for consumers.
+	Consumers map[string]ConsumerConfig `mapstructure:"consumers" default:"{}"`
+	//Versioning internal config - used to mount the client id
+	Version string
+}
+
+// Connection describe a config for one kafka cluster.
+type Connection struct {
+	Brokers      []string      `mapstructure:"brokers"`
+	KafkaVersion string        `mapstructure:"version" default:"2.1.0"`
+	Timeout      time.Duration `mapstructure:"timeout" default:"2s"`
+}
+
+// ConsumerConfig describes consumer's configuration.
+type ConsumerConfig struct {
+	Connection string   `mapstructure:"connection"`
+	Group      string   `mapstructure:"group"`
+	Topics     []string `mapstructure:"topics"`
+	// MaxWorkers is the number of messages processed concurrently across all the partitions.
+	// Messages from the same partition are always processed in order.
+	MaxWorkers int `mapstructure:"workers" default:"1"`
+	// InitialOffset is used when the group didn't have a committed offset: oldest or newest.
+	InitialOffset string `mapstructure:"initial_offset" default:"newest"`
+	// RetryTopic receive the messages returning ExitRetry.
+	RetryTopic string `mapstructure:"retry_topic"`
+	// DeadLetterTopic receive the messages rejected or with all the in-place retries exhausted.
+	DeadLetterTopic string        `mapstructure:"dead_letter_topic"`
+	Retry           RetryConfig   `mapstructure:"retry"`
+	Runner          runner.Config `mapstructure:"runner"`
+	// Restart override the --restart-* flags for this consumer, the fields not set use the flags.
+	Restart supervisor.RestartPolicy `mapstructure:"restart"`
+}
+
+// RetryConfig describes how the messages are retried in place.
+type RetryConfig struct {
+	// MaxRetries before sending the message to the DeadLetterTopic.
+	// Without a DeadLetterTopic the message is retried until the runner returns ExitACK.
+	MaxRetries int           `mapstructure:"max_retries" default:"3"`
+	Backoff    time.Duration `mapstructure:"backoff" default:"1s"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff" default:"30s"`
+}
+
+func setConfigDefaults(config *Config) error {
+	if err := defaults.Set(config); err != nil {
+		return err
+	}
+
+	for k := range config.Connections {
+		cfg := config.Connections[k]
+		if err := defaults.Set(&cfg); err != nil {
+			return err
+		}
+		config.Connections[k] = cfg
+	}
+
+	for k := range config.Consumers {
+		cfg := config.Consumers[k]
+		if err := defaults.Set(&cfg); err != nil {
+			return err
+		}
+		if len(cfg.Group) == 0 {
+			cfg.Group = k
+		}
+		cfg.Runner.SetDefaults(config.Version, cfg.MaxWorkers)
+		config.Consumers[k] = cfg
+	}
+	return nil
+}
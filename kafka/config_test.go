@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_withDefaults(t *testing.T) {
+	config := Config{
+		Connections: map[string]Connection{
+			"default": {Brokers: []string{"localhost:9092"}},
+		},
+		Consumers: map[string]ConsumerConfig{
+			"consumer1": {
+				Connection: "default",
+				Topics:     []string{"pictures"},
+				Runner: runner.Config{
+					Type: "http",
+					Options: runner.Options{
+						URL: "http://localhost:8080",
+					},
+				},
+			},
+		},
+		Version: "0.0.5",
+	}
+
+	err := setConfigDefaults(&config)
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Second, config.Connections["default"].Timeout)
+	require.Equal(t, "2.1.0", config.Connections["default"].KafkaVersion)
+	require.Equal(t, "consumer1", config.Consumers["consumer1"].Group)
+	require.Equal(t, 1, config.Consumers["consumer1"].MaxWorkers)
+	require.Equal(t, "newest", config.Consumers["consumer1"].InitialOffset)
+	require.Equal(t, 3, config.Consumers["consumer1"].Retry.MaxRetries)
+	require.Equal(t, time.Second, config.Consumers["consumer1"].Retry.Backoff)
+	require.Equal(t, 30*time.Second, config.Consumers["consumer1"].Retry.MaxBackoff)
+	require.Equal(t, "message-cannon/0.0.5", config.Consumers["consumer1"].Runner.Options.Headers["User-Agent"])
+}
@@ -0,0 +1,411 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/IBM/sarama"
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/internal/pool"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+)
+
+const retryCountHeader = "x-retry-count"
+
+type consumer struct {
+	lastSuccess int64 // unix nano, keep it first for the 64-bit alignment
+	paused      int32
+	runner      runner.Runnable
+	hash        string
+	name        string
+	cfg         ConsumerConfig
+	workerPool  *pool.Pool
+	factoryName string
+	group       sarama.ConsumerGroup
+	producer    sarama.SyncProducer
+	t           tomb.Tomb
+	hub         *hub.Hub
+}
+
+// Run start a goroutine to join the consumer group and pass the messages to one runner.
+func (c *consumer) Run() {
+	c.t.Go(func() error {
+		defer c.close()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		c.t.Go(func() error {
+			for {
+				select {
+				case <-c.t.Dying():
+					cancel()
+					return nil
+				case err, ok := <-c.group.Errors():
+					if !ok {
+						return nil
+					}
+					c.hub.Publish(hub.Message{
+						Name:   "kafka.consumer.error",
+						Body:   []byte("error consuming messages"),
+						Fields: hub.Fields{"error": err},
+					})
+				}
+			}
+		})
+		for {
+			// Consume returns when the group is rebalanced, we need to join again.
+			err := c.group.Consume(ctx, c.cfg.Topics, c)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				c.hub.Publish(hub.Message{
+					Name:   "kafka.consumer.error",
+					Body:   []byte("Failed to consume"),
+					Fields: hub.Fields{"error": err},
+				})
+				return err
+			}
+		}
+	})
+}
+
+// Kill will try to stop the internal work.
+func (c *consumer) Kill() {
+	c.t.Kill(nil)
+	<-c.t.Dead()
+}
+
+// Alive returns true if the tomb is not in a dying or dead state.
+func (c *consumer) Alive() bool {
+	return c.t.Alive()
+}
+
+// RestartPolicy return the restart policy from the consumer config.
+func (c *consumer) RestartPolicy() supervisor.RestartPolicy {
+	return c.cfg.Restart
+}
+
+// LastSuccess return the time of the last message acked.
+func (c *consumer) LastSuccess() time.Time {
+	last := atomic.LoadInt64(&c.lastSuccess)
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+// Name return the consumer name
+func (c *consumer) Name() string {
+	return c.name
+}
+
+// FactoryName is the name of the factory responsible for this consumer.
+func (c *consumer) FactoryName() string {
+	return c.factoryName
+}
+
+// Pause stop fetching the messages of all the partitions, the messages already fetched are processed.
+func (c *consumer) Pause() error {
+	if !c.t.Alive() {
+		return errors.New("the consumer is not running")
+	}
+	atomic.StoreInt32(&c.paused, 1)
+	c.group.PauseAll()
+	return nil
+}
+
+// Resume start fetching the messages again after a pause.
+func (c *consumer) Resume() error {
+	if !c.t.Alive() {
+		return errors.New("the consumer is not running")
+	}
+	atomic.StoreInt32(&c.paused, 0)
+	c.group.ResumeAll()
+	return nil
+}
+
+// Paused returns true if the consumer was paused.
+func (c *consumer) Paused() bool {
+	return atomic.LoadInt32(&c.paused) == 1
+}
+
+// Scale change the number of messages processed concurrently across all the partitions.
+func (c *consumer) Scale(workers int) error {
+	if workers < 1 {
+		return errors.New("the number of workers must be greater than zero")
+	}
+	c.workerPool.Resize(workers)
+	return nil
+}
+
+// Workers return the number of concurrent workers.
+func (c *consumer) Workers() int {
+	return c.workerPool.Size()
+}
+
+// InFlight return the number of messages being processed.
+func (c *consumer) InFlight() int {
+	return c.workerPool.InFlight()
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim.
+func (c *consumer) Setup(session sarama.ConsumerGroupSession) error {
+	c.hub.Publish(hub.Message{
+		Name: "kafka.consumer.info",
+		Body: []byte("joined the consumer group"),
+		Fields: hub.Fields{
+			"group":      c.cfg.Group,
+			"generation": session.GenerationID(),
+			"member":     session.MemberID(),
+		},
+	})
+	return nil
+}
+
+// Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited.
+func (c *consumer) Cleanup(_ sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim process the messages of one partition in order.
+// The concurrency across all the partitions is limited by the worker pool.
+func (c *consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx := session.Context()
+	// the partitions claimed after a rebalance are not paused by the PauseAll.
+	if c.Paused() {
+		c.group.Pause(map[string][]int32{claim.Topic(): {claim.Partition()}})
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			c.workerPool.Acquire()
+			c.handleMessage(session, msg)
+			c.workerPool.Release()
+		}
+	}
+}
+
+// handleMessage process the message until it's settled.
+// The offset is only marked when the message is acked, forwarded to the retry/dead letter topics or discarded.
+func (c *consumer) handleMessage(session sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage) {
+	ctx := session.Context()
+	retries := getRetryCount(msg)
+	for attempt := 0; ; attempt++ {
+		status := c.processMessage(ctx, msg, retries+attempt)
+		if ctx.Err() != nil {
+			// The session ended (rebalance or shutdown), the message will be delivered again.
+			return
+		}
+		switch status {
+		case runner.ExitACK:
+			session.MarkMessage(msg, "")
+			atomic.StoreInt64(&c.lastSuccess, time.Now().UnixNano())
+			return
+		case runner.ExitNACK:
+			if c.forward(ctx, c.cfg.DeadLetterTopic, msg, status, retries+attempt) {
+				session.MarkMessage(msg, "")
+			}
+			return
+		case runner.ExitRetry:
+			if len(c.cfg.RetryTopic) > 0 {
+				if c.forward(ctx, c.cfg.RetryTopic, msg, status, retries+attempt+1) {
+					session.MarkMessage(msg, "")
+				}
+				return
+			}
+		}
+		if len(c.cfg.DeadLetterTopic) > 0 && attempt >= c.cfg.Retry.MaxRetries {
+			if c.forward(ctx, c.cfg.DeadLetterTopic, msg, status, retries+attempt) {
+				session.MarkMessage(msg, "")
+			}
+			return
+		}
+		if !sleep(ctx, c.backoff(attempt)) {
+			return
+		}
+	}
+}
+
+// Action describe what the consumer does with one message after the runner returns the status.
+// It follows the handleMessage method and is used by the run command.
+func (c ConsumerConfig) Action(status int) string {
+	switch status {
+	case runner.ExitACK:
+		return "mark the offset"
+	case runner.ExitNACK:
+		return c.forwardAction(c.DeadLetterTopic)
+	case runner.ExitRetry:
+		if len(c.RetryTopic) > 0 {
+			return c.forwardAction(c.RetryTopic)
+		}
+	}
+	if len(c.DeadLetterTopic) > 0 {
+		return fmt.Sprintf("retry in place, sent to %s after %d retries", c.DeadLetterTopic, c.Retry.MaxRetries)
+	}
+	return "retry in place until the runner returns the ack exit code"
+}
+
+func (c ConsumerConfig) forwardAction(topic string) string {
+	if len(topic) == 0 {
+		return "discard the message and mark the offset"
+	}
+	return fmt.Sprintf("send to %s and mark the offset", topic)
+}
+
+func (c *consumer) processMessage(ctx context.Context, msg *sarama.ConsumerMessage, retries int) int {
+	if c.cfg.Runner.Timeout >= time.Second {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Runner.Timeout)
+		defer cancel()
+	}
+	c.hub.Publish(hub.Message{
+		Name:   "kafka.process.start",
+		Fields: hub.Fields{"in-flight": c.workerPool.InFlight()},
+	})
+	start := time.Now()
+	status, err := c.runner.Process(ctx, runner.Message{Body: msg.Value, Headers: getHeaders(msg, retries)})
+	duration := time.Since(start)
+	fields := hub.Fields{
+		"duration":    duration,
+		"status-code": status,
+		// not counting this message, its worker is released by the claim loop
+		"in-flight": c.workerPool.InFlight() - 1,
+		"topic":     msg.Topic,
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+	}
+	topic := "kafka.process.sucess"
+	if err != nil {
+		topic = "kafka.process.error"
+		switch e := err.(type) {
+		case *runner.Error:
+			fields["error"] = e.Err
+			fields["exit-code"] = e.StatusCode
+			fields["output"] = e.Output
+		default:
+			fields["error"] = e
+		}
+	}
+	c.hub.Publish(hub.Message{
+		Name:   topic,
+		Fields: fields,
+	})
+	return status
+}
+
+// forward publish the message to another topic, retrying until succeed or the session ends.
+// An empty topic means the message is discarded.
+func (c *consumer) forward(ctx context.Context, topic string, msg *sarama.ConsumerMessage, status, retries int) bool {
+	if len(topic) == 0 {
+		c.hub.Publish(hub.Message{
+			Name:   "kafka.consumer.warning",
+			Body:   []byte("message discarded"),
+			Fields: hub.Fields{"topic": msg.Topic, "partition": msg.Partition, "offset": msg.Offset, "status": status},
+		})
+		return true
+	}
+	pm := &sarama.ProducerMessage{
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: forwardHeaders(msg, status, retries),
+	}
+	if msg.Key != nil {
+		pm.Key = sarama.ByteEncoder(msg.Key)
+	}
+	for attempt := 0; ; attempt++ {
+		_, _, err := c.producer.SendMessage(pm)
+		if err == nil {
+			return true
+		}
+		c.hub.Publish(hub.Message{
+			Name:   "kafka.consumer.error",
+			Body:   []byte("failed to forward the message"),
+			Fields: hub.Fields{"topic": topic, "error": err},
+		})
+		if !sleep(ctx, c.backoff(attempt)) {
+			return false
+		}
+	}
+}
+
+// backoff return the exponential delay for one attempt limited by the max_backoff.
+func (c *consumer) backoff(attempt int) time.Duration {
+	d := c.cfg.Retry.Backoff
+	for i := 0; i < attempt && d < c.cfg.Retry.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > c.cfg.Retry.MaxBackoff {
+		d = c.cfg.Retry.MaxBackoff
+	}
+	return d
+}
+
+func (c *consumer) close() {
+	if err := c.group.Close(); err != nil {
+		c.hub.Publish(hub.Message{
+			Name:   "kafka.consumer.error",
+			Body:   []byte("Error closing the consumer group"),
+			Fields: hub.Fields{"error": err},
+		})
+	}
+	if closer, ok := c.runner.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			c.hub.Publish(hub.Message{
+				Name:   "kafka.consumer.error",
+				Body:   []byte("Error closing the consumer runner"),
+				Fields: hub.Fields{"error": err},
+			})
+		}
+	}
+}
+
+// forwardHeaders keep the original headers and add the retry information.
+// The x-original-* headers are kept when the message was already forwarded before.
+func forwardHeaders(msg *sarama.ConsumerMessage, status, retries int) []sarama.RecordHeader {
+	headers := []sarama.RecordHeader{}
+	original := false
+	for _, h := range msg.Headers {
+		switch string(h.Key) {
+		case retryCountHeader, "x-exit-code":
+			continue
+		case "x-original-topic":
+			original = true
+		}
+		headers = append(headers, *h)
+	}
+	if !original {
+		headers = append(headers,
+			sarama.RecordHeader{Key: []byte("x-original-topic"), Value: []byte(msg.Topic)},
+			sarama.RecordHeader{Key: []byte("x-original-partition"), Value: []byte(strconv.FormatInt(int64(msg.Partition), 10))},
+			sarama.RecordHeader{Key: []byte("x-original-offset"), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		)
+	}
+	return append(headers,
+		sarama.RecordHeader{Key: []byte(retryCountHeader), Value: []byte(strconv.Itoa(retries))},
+		sarama.RecordHeader{Key: []byte("x-exit-code"), Value: []byte(strconv.Itoa(status))},
+	)
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,220 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/internal/pool"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_consumer_handleMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        ConsumerConfig
+		statuses   []int
+		forwards   int
+		marked     bool
+		processed  int
+		wantTopic  string
+		wantRetry  string
+		cancelling bool
+	}{
+		{
+			name:      "ack mark the offset",
+			statuses:  []int{runner.ExitACK},
+			marked:    true,
+			processed: 1,
+		},
+		{
+			name:      "nack without dead letter discard the message",
+			statuses:  []int{runner.ExitNACK},
+			marked:    true,
+			processed: 1,
+		},
+		{
+			name:      "nack with dead letter forward the message",
+			cfg:       ConsumerConfig{DeadLetterTopic: "pictures-dlq"},
+			statuses:  []int{runner.ExitNACK},
+			forwards:  1,
+			marked:    true,
+			processed: 1,
+			wantTopic: "pictures-dlq",
+			wantRetry: "0",
+		},
+		{
+			name:      "failed retry in place before the dead letter",
+			cfg:       ConsumerConfig{DeadLetterTopic: "pictures-dlq"},
+			statuses:  []int{runner.ExitFailed, runner.ExitFailed, runner.ExitFailed},
+			forwards:  1,
+			marked:    true,
+			processed: 3,
+			wantTopic: "pictures-dlq",
+			wantRetry: "2",
+		},
+		{
+			name:      "retry with a retry topic forward the message",
+			cfg:       ConsumerConfig{RetryTopic: "pictures-retry"},
+			statuses:  []int{runner.ExitRetry},
+			forwards:  1,
+			marked:    true,
+			processed: 1,
+			wantTopic: "pictures-retry",
+			wantRetry: "1",
+		},
+		{
+			name:      "requeue retry in place until ack",
+			statuses:  []int{runner.ExitNACKRequeue, runner.ExitTimeout, runner.ExitACK},
+			marked:    true,
+			processed: 3,
+		},
+		{
+			name:      "retries exhausted forward to the dead letter",
+			cfg:       ConsumerConfig{DeadLetterTopic: "pictures-dlq"},
+			statuses:  []int{runner.ExitRetry, runner.ExitRetry, runner.ExitRetry},
+			forwards:  1,
+			marked:    true,
+			processed: 3,
+			wantTopic: "pictures-dlq",
+			wantRetry: "2",
+		},
+		{
+			name:       "session ended didn't mark the offset",
+			statuses:   []int{runner.ExitNACKRequeue},
+			processed:  1,
+			cancelling: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := mocks.NewSyncProducer(t, nil)
+			defer producer.Close()
+			var sent *sarama.ProducerMessage
+			for i := 0; i < tt.forwards; i++ {
+				producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+					sent = msg
+					return nil
+				})
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			session := &mockSession{ctx: ctx}
+			mock := &mockRunner{statuses: tt.statuses}
+			if tt.cancelling {
+				mock.onProcess = cancel
+			}
+			cfg := tt.cfg
+			cfg.Retry = RetryConfig{MaxRetries: 2, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+			c := &consumer{
+				runner:     mock,
+				cfg:        cfg,
+				producer:   producer,
+				workerPool: pool.New(1),
+				hub:        hub.New(),
+			}
+			c.handleMessage(session, &sarama.ConsumerMessage{
+				Topic:     "pictures",
+				Partition: 1,
+				Offset:    10,
+				Value:     []byte(`{"fooo": "bazzz"}`),
+			})
+			require.Equal(t, tt.processed, mock.processed())
+			require.Equal(t, tt.marked, session.isMarked())
+			if tt.forwards > 0 {
+				require.NotNil(t, sent)
+				require.Equal(t, tt.wantTopic, sent.Topic)
+				for _, h := range sent.Headers {
+					if string(h.Key) == retryCountHeader {
+						require.Equal(t, tt.wantRetry, string(h.Value))
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_consumer_backoff(t *testing.T) {
+	c := &consumer{cfg: ConsumerConfig{Retry: RetryConfig{Backoff: time.Second, MaxBackoff: 5 * time.Second}}}
+	require.Equal(t, time.Second, c.backoff(0))
+	require.Equal(t, 2*time.Second, c.backoff(1))
+	require.Equal(t, 4*time.Second, c.backoff(2))
+	require.Equal(t, 5*time.Second, c.backoff(3))
+	require.Equal(t, 5*time.Second, c.backoff(30))
+}
+
+type mockRunner struct {
+	mu        sync.Mutex
+	count     int
+	statuses  []int
+	onProcess func()
+}
+
+func (m *mockRunner) Process(_ context.Context, _ runner.Message) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := m.statuses[m.count%len(m.statuses)]
+	m.count++
+	if m.onProcess != nil {
+		m.onProcess()
+	}
+	return status, nil
+}
+
+func (m *mockRunner) processed() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.count
+}
+
+type mockSession struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	marked bool
+}
+
+func (s *mockSession) Claims() map[string][]int32 { return nil }
+func (s *mockSession) MemberID() string           { return "member" }
+func (s *mockSession) GenerationID() int32        { return 1 }
+func (s *mockSession) MarkOffset(_ string, _ int32, _ int64, _ string) {
+}
+func (s *mockSession) Commit() {}
+func (s *mockSession) ResetOffset(_ string, _ int32, _ int64, _ string) {
+}
+func (s *mockSession) MarkMessage(_ *sarama.ConsumerMessage, _ string) {
+	s.mu.Lock()
+	s.marked = true
+	s.mu.Unlock()
+}
+func (s *mockSession) Context() context.Context { return s.ctx }
+
+func (s *mockSession) isMarked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.marked
+}
+
+// make sure the mocks didn't break the interfaces
+var (
+	_ runner.Runnable             = &mockRunner{}
+	_ sarama.ConsumerGroupSession = &mockSession{}
+	_ sarama.ConsumerGroupHandler = &consumer{}
+)
+
+func TestConsumerConfig_Action(t *testing.T) {
+	cfg := ConsumerConfig{Retry: RetryConfig{MaxRetries: 3}}
+	require.Equal(t, "mark the offset", cfg.Action(runner.ExitACK))
+	require.Equal(t, "discard the message and mark the offset", cfg.Action(runner.ExitNACK))
+	require.Equal(t, "retry in place until the runner returns the ack exit code", cfg.Action(runner.ExitRetry))
+	cfg.DeadLetterTopic = "pictures.dead"
+	cfg.RetryTopic = "pictures.retry"
+	require.Equal(t, "send to pictures.dead and mark the offset", cfg.Action(runner.ExitNACK))
+	require.Equal(t, "retry in place, sent to pictures.dead after 3 retries", cfg.Action(runner.ExitFailed))
+	require.Equal(t, "send to pictures.retry and mark the offset", cfg.Action(runner.ExitRetry))
+	require.Equal(t, "retry in place, sent to pictures.dead after 3 retries", cfg.Action(runner.ExitNACKRequeue))
+}
@@ -0,0 +1,223 @@
+package kafka
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/IBM/sarama"
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/internal/pool"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	"github.com/pkg/errors"
+)
+
+// Factory is the block responsible for create consumers and the producers used by retries and dead letters.
+type Factory struct {
+	config    Config
+	clients   map[string]sarama.Client
+	producers map[string]sarama.SyncProducer
+	hub       *hub.Hub
+	number    int64
+	// retired producers and clients are closed after the reload.
+	retired []io.Closer
+}
+
+// NewFactory will open the initial connections with the kafka clusters.
+func NewFactory(config Config, h *hub.Hub) (*Factory, error) {
+	err := setConfigDefaults(&config)
+	if err != nil {
+		h.Publish(hub.Message{
+			Name:   "kafka.config.warning",
+			Body:   []byte("Failed to set default values for configs"),
+			Fields: hub.Fields{"error": err},
+		})
+	}
+	clients := make(map[string]sarama.Client)
+	for name, cfgConn := range config.Connections {
+		h.Publish(hub.Message{
+			Name: "kafka.opening_connection.info",
+			Body: []byte("opening connection with kafka"),
+			Fields: hub.Fields{
+				"brokers":    strings.Join(cfgConn.Brokers, ", "),
+				"timeout":    cfgConn.Timeout,
+				"connection": name,
+			},
+		})
+		saramaCfg, err := newSaramaConfig(cfgConn, config.Version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid config for the connection \"%s\"", name)
+		}
+		client, err := sarama.NewClient(cfgConn.Brokers, saramaCfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error opening the connection \"%s\"", name)
+		}
+		clients[name] = client
+	}
+	f := &Factory{
+		config,
+		clients,
+		make(map[string]sarama.SyncProducer),
+		h,
+		1,
+		nil,
+	}
+	return f, nil
+}
+
+// CreateConsumers will iterate over config and create all the consumers
+func (f *Factory) CreateConsumers() ([]supervisor.Consumer, error) {
+	var consumers []supervisor.Consumer
+	for name, cfg := range f.config.Consumers {
+		consumer, err := f.newConsumer(name, cfg)
+		if err != nil {
+			return consumers, err
+		}
+		consumers = append(consumers, consumer)
+	}
+	return consumers, nil
+}
+
+// CreateConsumer create a new consumer for a specific name using the config provided.
+func (f *Factory) CreateConsumer(name string) (supervisor.Consumer, error) {
+	cfg, ok := f.config.Consumers[name]
+	if !ok {
+		return nil, errors.Errorf("consumer \"%s\" did not exist", name)
+	}
+	return f.newConsumer(name, cfg)
+}
+
+// Name return the factory name
+func (f *Factory) Name() string {
+	return "kafka"
+}
+
+// CheckConnection return an error when the client used by the consumer is closed or didn't know any broker.
+func (f *Factory) CheckConnection(consumer string) error {
+	cfg, ok := f.config.Consumers[consumer]
+	if !ok {
+		return errors.Errorf("consumer \"%s\" did not exist", consumer)
+	}
+	client, ok := f.clients[cfg.Connection]
+	if !ok || client.Closed() || len(client.Brokers()) == 0 {
+		return errors.Errorf("the connection \"%s\" is closed", cfg.Connection)
+	}
+	return nil
+}
+
+func (f *Factory) newConsumer(name string, cfg ConsumerConfig) (*consumer, error) {
+	cfgConn, ok := f.config.Connections[cfg.Connection]
+	if !ok {
+		available := []string{}
+		for name := range f.config.Connections {
+			available = append(available, name)
+		}
+		return nil, errors.Errorf(
+			"connection (%s) did not exist, connections names available: %s",
+			cfg.Connection,
+			strings.Join(available, ", "))
+	}
+	saramaCfg, err := newSaramaConfig(cfgConn, f.config.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid config for the connection \"%s\"", cfg.Connection)
+	}
+	saramaCfg.Consumer.Offsets.Initial = sarama.OffsetNewest
+	if cfg.InitialOffset == "oldest" {
+		saramaCfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
+	// consumer groups can't share clients, every consumer has its own group and client.
+	group, err := sarama.NewConsumerGroup(cfgConn.Brokers, cfg.Group, saramaCfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the consumer group for consumer %s", name)
+	}
+	var producer sarama.SyncProducer
+	if len(cfg.RetryTopic) > 0 || len(cfg.DeadLetterTopic) > 0 {
+		producer, err = f.getProducer(cfg.Connection)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	runner, err := runner.New(cfg.Runner, f.hub.With(hub.Fields{"consumer": name}))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed creating a runner")
+	}
+	f.hub.Publish(hub.Message{
+		Name: "kafka.declare.debug",
+		Body: []byte("consumer created"),
+		Fields: hub.Fields{
+			"max-workers": cfg.MaxWorkers,
+			"group":       cfg.Group,
+			"consumer":    name,
+		},
+	})
+	return &consumer{
+		name:        name,
+		hash:        strconv.FormatInt(atomic.AddInt64(&f.number, 1), 10),
+		cfg:         cfg,
+		factoryName: f.Name(),
+		group:       group,
+		producer:    producer,
+		t:           tomb.Tomb{},
+		runner:      runner,
+		hub:         f.hub.With(hub.Fields{"consumer": name}),
+		workerPool:  pool.New(cfg.MaxWorkers),
+	}, nil
+}
+
+// getProducer return the producer used to publish retries and dead letters.
+// The producers are created when the first consumer needs them and reopened when the client was closed.
+func (f *Factory) getProducer(connectionName string) (sarama.SyncProducer, error) {
+	client := f.clients[connectionName]
+	if client.Closed() {
+		cfgConn := f.config.Connections[connectionName]
+		f.hub.Publish(hub.Message{
+			Name:   "kafka.reopening_connection.info",
+			Body:   []byte("reopening one connection closed"),
+			Fields: hub.Fields{"connection": connectionName},
+		})
+		saramaCfg, err := newSaramaConfig(cfgConn, f.config.Version)
+		if err != nil {
+			return nil, err
+		}
+		client, err = sarama.NewClient(cfgConn.Brokers, saramaCfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reopening the connection \"%s\"", connectionName)
+		}
+		f.clients[connectionName] = client
+		delete(f.producers, connectionName)
+	}
+	if p, ok := f.producers[connectionName]; ok {
+		return p, nil
+	}
+	p, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the producer for connection \"%s\"", connectionName)
+	}
+	f.producers[connectionName] = p
+	return p, nil
+}
+
+func newSaramaConfig(c Connection, version string) (*sarama.Config, error) {
+	cfg := sarama.NewConfig()
+	kafkaVersion, err := sarama.ParseKafkaVersion(c.KafkaVersion)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Version = kafkaVersion
+	cfg.ClientID = "message-cannon"
+	if len(version) > 0 {
+		cfg.ClientID = "message-cannon-" + strings.Replace(version, "+", "-", -1)
+	}
+	cfg.Net.DialTimeout = c.Timeout
+	cfg.Consumer.Return.Errors = true
+	// only the marked offsets are committed, and they are marked after the message is handled
+	cfg.Consumer.Offsets.AutoCommit.Enable = true
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	return cfg, nil
+}
@@ -0,0 +1,41 @@
+package kafka
+
+import (
+	"strconv"
+
+	"github.com/IBM/sarama"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+)
+
+func getHeaders(msg *sarama.ConsumerMessage, retries int) runner.Headers {
+	headers := runner.Headers{
+		"Topic":          msg.Topic,
+		"Partition":      msg.Partition,
+		"Offset":         msg.Offset,
+		"Message-Key":    string(msg.Key),
+		"Message-Deaths": strconv.Itoa(retries),
+	}
+	if !msg.Timestamp.IsZero() {
+		headers["Timestamp"] = msg.Timestamp
+	}
+	for _, h := range msg.Headers {
+		if h == nil || string(h.Key) == retryCountHeader {
+			continue
+		}
+		headers[string(h.Key)] = string(h.Value)
+	}
+	return headers
+}
+
+// getRetryCount return how many times the message was retried before being forwarded to this topic.
+func getRetryCount(msg *sarama.ConsumerMessage) int {
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == retryCountHeader {
+			count, err := strconv.Atoi(string(h.Value))
+			if err == nil {
+				return count
+			}
+		}
+	}
+	return 0
+}
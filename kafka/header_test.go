@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getHeaders(t *testing.T) {
+	ts := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := &sarama.ConsumerMessage{
+		Topic:     "pictures",
+		Partition: 2,
+		Offset:    42,
+		Key:       []byte("user-1"),
+		Timestamp: ts,
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("Content-Type"), Value: []byte("application/json")},
+			{Key: []byte(retryCountHeader), Value: []byte("2")},
+		},
+	}
+	require.Equal(t, runner.Headers{
+		"Topic":          "pictures",
+		"Partition":      int32(2),
+		"Offset":         int64(42),
+		"Message-Key":    "user-1",
+		"Message-Deaths": "3",
+		"Timestamp":      ts,
+		"Content-Type":   "application/json",
+	}, getHeaders(msg, 3))
+}
+
+func Test_getRetryCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []*sarama.RecordHeader
+		want    int
+	}{
+		{"without headers", nil, 0},
+		{"with a valid count", []*sarama.RecordHeader{{Key: []byte(retryCountHeader), Value: []byte("4")}}, 4},
+		{"with an invalid count", []*sarama.RecordHeader{{Key: []byte(retryCountHeader), Value: []byte("foo")}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, getRetryCount(&sarama.ConsumerMessage{Headers: tt.headers}))
+		})
+	}
+}
+
+func Test_forwardHeaders(t *testing.T) {
+	msg := &sarama.ConsumerMessage{
+		Topic:     "pictures",
+		Partition: 1,
+		Offset:    10,
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("Content-Type"), Value: []byte("application/json")},
+			{Key: []byte(retryCountHeader), Value: []byte("1")},
+		},
+	}
+	headers := forwardHeaders(msg, runner.ExitRetry, 2)
+	require.Equal(t, []sarama.RecordHeader{
+		{Key: []byte("Content-Type"), Value: []byte("application/json")},
+		{Key: []byte("x-original-topic"), Value: []byte("pictures")},
+		{Key: []byte("x-original-partition"), Value: []byte("1")},
+		{Key: []byte("x-original-offset"), Value: []byte("10")},
+		{Key: []byte(retryCountHeader), Value: []byte("2")},
+		{Key: []byte("x-exit-code"), Value: []byte("5")},
+	}, headers)
+
+	// forwarding again keep the original information
+	retried := &sarama.ConsumerMessage{Topic: "pictures-retry", Partition: 0, Offset: 3}
+	for i := range headers {
+		retried.Headers = append(retried.Headers, &headers[i])
+	}
+	headers = forwardHeaders(retried, runner.ExitNACK, 3)
+	require.Equal(t, []sarama.RecordHeader{
+		{Key: []byte("Content-Type"), Value: []byte("application/json")},
+		{Key: []byte("x-original-topic"), Value: []byte("pictures")},
+		{Key: []byte("x-original-partition"), Value: []byte("1")},
+		{Key: []byte("x-original-offset"), Value: []byte("10")},
+		{Key: []byte(retryCountHeader), Value: []byte("3")},
+		{Key: []byte("x-exit-code"), Value: []byte("3")},
+	}, headers)
+}
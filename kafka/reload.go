@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"reflect"
+
+	"github.com/IBM/sarama"
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	"github.com/pkg/errors"
+)
+
+// Reload take the config and the clients from a new factory.
+// The clients and producers with the same connection config are kept, so the consumers not changed keep running.
+// The clients replaced are closed by Release, after the old consumers were killed.
+func (f *Factory) Reload(nf supervisor.Factory) ([]string, []string, error) {
+	n, ok := nf.(*Factory)
+	if !ok {
+		return nil, nil, errors.Errorf("can't reload the kafka factory using a %T", nf)
+	}
+	changedConns := map[string]bool{}
+	for name, client := range n.clients {
+		old, exist := f.clients[name]
+		if exist && reflect.DeepEqual(f.config.Connections[name], n.config.Connections[name]) {
+			// keep the running client and drop the one opened by the new factory
+			_ = client.Close()
+			n.clients[name] = old
+			if p, ok := f.producers[name]; ok {
+				n.producers[name] = p
+			}
+			continue
+		}
+		changedConns[name] = true
+	}
+	for name, client := range f.clients {
+		if n.clients[name] == client {
+			continue
+		}
+		if p, ok := f.producers[name]; ok {
+			f.retired = append(f.retired, p)
+		}
+		f.retired = append(f.retired, client)
+	}
+	changed := []string{}
+	removed := []string{}
+	for name, cfg := range f.config.Consumers {
+		ncfg, exist := n.config.Consumers[name]
+		switch {
+		case !exist:
+			removed = append(removed, name)
+		case changedConns[ncfg.Connection] || !reflect.DeepEqual(cfg, ncfg):
+			changed = append(changed, name)
+		}
+	}
+	for name := range n.config.Consumers {
+		if _, exist := f.config.Consumers[name]; !exist {
+			changed = append(changed, name)
+		}
+	}
+	f.config = n.config
+	f.clients = n.clients
+	f.producers = n.producers
+	return changed, removed, nil
+}
+
+// Release close the producers and clients replaced by the last Reload.
+func (f *Factory) Release() {
+	for _, c := range f.retired {
+		err := c.Close()
+		if err != nil && err != sarama.ErrClosedClient {
+			f.hub.Publish(hub.Message{
+				Name:   "kafka.connection.error",
+				Body:   []byte("Error closing one connection replaced by the reload"),
+				Fields: hub.Fields{"error": err},
+			})
+		}
+	}
+	f.retired = nil
+}
+
+// Close the producers and the clients opened by this factory.
+// The consumer groups are closed by the consumers.
+func (f *Factory) Close() error {
+	var err error
+	for _, c := range f.retired {
+		if cerr := c.Close(); cerr != nil && cerr != sarama.ErrClosedClient {
+			err = cerr
+		}
+	}
+	f.retired = nil
+	for name, p := range f.producers {
+		if perr := p.Close(); perr != nil {
+			err = errors.Wrapf(perr, "failed to close the producer of connection \"%s\"", name)
+		}
+	}
+	for name, client := range f.clients {
+		if client.Closed() {
+			continue
+		}
+		if cerr := client.Close(); cerr != nil {
+			err = errors.Wrapf(cerr, "failed to close the connection \"%s\"", name)
+		}
+	}
+	return err
+}
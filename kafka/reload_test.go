@@ -0,0 +1,100 @@
+package kafka
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFactory_Reload(t *testing.T) {
+	newFactory := func(consumers map[string]ConsumerConfig, brokers string) *Factory {
+		return &Factory{
+			config: Config{
+				Connections: map[string]Connection{"default": {Brokers: []string{brokers}}},
+				Consumers:   consumers,
+			},
+			clients:   map[string]sarama.Client{"default": &mockClient{}},
+			producers: map[string]sarama.SyncProducer{},
+			hub:       hub.New(),
+		}
+	}
+	consumer := func(url string) ConsumerConfig {
+		return ConsumerConfig{
+			Connection:      "default",
+			Topics:          []string{"pictures"},
+			DeadLetterTopic: "pictures-dlq",
+			Runner:          runner.Config{Type: "http", Options: runner.Options{URL: url}},
+		}
+	}
+	running := newFactory(map[string]ConsumerConfig{
+		"upload": consumer("http://localhost/upload"),
+		"resize": consumer("http://localhost/resize"),
+		"delete": consumer("http://localhost/delete"),
+	}, "localhost:9092")
+	client := running.clients["default"].(*mockClient)
+	producer := mocks.NewSyncProducer(t, nil)
+	running.producers["default"] = producer
+
+	nf := newFactory(map[string]ConsumerConfig{
+		"upload": consumer("http://localhost/upload"),
+		"resize": consumer("http://localhost/v2/resize"),
+		"crop":   consumer("http://localhost/crop"),
+	}, "localhost:9092")
+	opened := nf.clients["default"]
+	changed, removed, err := running.Reload(nf)
+	require.NoError(t, err)
+	sort.Strings(changed)
+	require.Equal(t, []string{"crop", "resize"}, changed)
+	require.Equal(t, []string{"delete"}, removed)
+	// the same connection keep the running client and producer
+	require.True(t, opened.Closed())
+	require.Same(t, client, running.clients["default"])
+	require.Equal(t, producer, running.producers["default"])
+	running.Release()
+	require.False(t, client.Closed())
+
+	// changing the connection recreate all the consumers using it
+	changed, removed, err = running.Reload(newFactory(running.config.Consumers, "kafka:9092"))
+	require.NoError(t, err)
+	sort.Strings(changed)
+	require.Equal(t, []string{"crop", "resize", "upload"}, changed)
+	require.Empty(t, removed)
+	require.NotSame(t, client, running.clients["default"])
+	require.Empty(t, running.producers)
+	require.False(t, client.Closed())
+	running.Release()
+	require.True(t, client.Closed())
+
+	_, _, err = running.Reload(&otherFactory{})
+	require.EqualError(t, err, "can't reload the kafka factory using a *kafka.otherFactory")
+}
+
+// mockClient only implement the methods used by the reload.
+type mockClient struct {
+	sarama.Client
+	closed bool
+}
+
+func (c *mockClient) Close() error {
+	if c.closed {
+		return sarama.ErrClosedClient
+	}
+	c.closed = true
+	return nil
+}
+
+func (c *mockClient) Closed() bool { return c.closed }
+
+type otherFactory struct{}
+
+func (f *otherFactory) CreateConsumers() ([]supervisor.Consumer, error)      { return nil, nil }
+func (f *otherFactory) CreateConsumer(_ string) (supervisor.Consumer, error) { return nil, nil }
+func (f *otherFactory) Name() string                                         { return "other" }
+
+var _ supervisor.Reloader = &Factory{}
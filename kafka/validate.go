@@ -0,0 +1,55 @@
+package kafka
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/IBM/sarama"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/pkg/errors"
+)
+
+// Validate apply the default values and check the config without opening any connection.
+// Every problem is returned with the path of the option, ie: "consumers.upload.connection: ...".
+func Validate(config Config) []error {
+	var errs []error
+	if err := setConfigDefaults(&config); err != nil {
+		return []error{errors.Wrap(err, "failed to set default values for configs")}
+	}
+	names := make([]string, 0, len(config.Connections))
+	for name := range config.Connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		conn := config.Connections[name]
+		if len(conn.Brokers) == 0 {
+			errs = append(errs, errors.Errorf("connections.%s.brokers: at least one broker is required", name))
+		}
+		if _, err := sarama.ParseKafkaVersion(conn.KafkaVersion); err != nil {
+			errs = append(errs, errors.Errorf("connections.%s.version: %s", name, err))
+		}
+	}
+	names = names[:0]
+	for name := range config.Consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cfg := config.Consumers[name]
+		path := fmt.Sprintf("consumers.%s", name)
+		if _, ok := config.Connections[cfg.Connection]; !ok {
+			errs = append(errs, errors.Errorf("%s.connection: connection \"%s\" did not exist", path, cfg.Connection))
+		}
+		if len(cfg.Topics) == 0 {
+			errs = append(errs, errors.Errorf("%s.topics: at least one topic is required", path))
+		}
+		if cfg.InitialOffset != "newest" && cfg.InitialOffset != "oldest" {
+			errs = append(errs, errors.Errorf("%s.initial_offset: invalid offset (\"%s\") expecting one of (newest, oldest)", path, cfg.InitialOffset))
+		}
+		for _, err := range runner.Validate(cfg.Runner) {
+			errs = append(errs, errors.Errorf("%s.runner.%s", path, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,93 @@
+package nats
+
+import (
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+)
+
+// Config describes all available options for NATS connection creation.
+type Config struct {
+	// Connections describe the connections used by consumers.
+	Connections map[string]Connection `mapstructure:"connections" default:"{}"`
+	// Streams have all the JetStream streams used by consumers.
+	// This streams are declared when the consumers using them are created.
+	Streams map[string]StreamConfig `mapstructure:"streams" default:"{}"`
+	// Consumers describes configuration list for consumers.
+	Consumers map[string]ConsumerConfig `mapstructure:"consumers" default:"{}"`
+	//Versioning internal config - used to mount the client name
+	Version string
+}
+
+// Connection describe a config for one connection.
+type Connection struct {
+	URL           string        `mapstructure:"url"`
+	Timeout       time.Duration `mapstructure:"timeout" default:"2s"`
+	ReconnectWait time.Duration `mapstructure:"reconnect_wait" default:"2s"`
+	MaxReconnects int           `mapstructure:"max_reconnects" default:"60"`
+}
+
+// StreamConfig describes JetStream stream's configuration.
+type StreamConfig struct {
+	Subjects  []string      `mapstructure:"subjects"`
+	Storage   string        `mapstructure:"storage" default:"file"`
+	Retention string        `mapstructure:"retention" default:"limits"`
+	MaxAge    time.Duration `mapstructure:"max_age"`
+	Replicas  int           `mapstructure:"replicas" default:"1"`
+}
+
+// ConsumerConfig describes consumer's configuration.
+type ConsumerConfig struct {
+	Connection string `mapstructure:"connection"`
+	// Mode is one of: push, pull (JetStream consumers) or core (plain NATS subscription without acks).
+	Mode          string        `mapstructure:"mode" default:"push"`
+	MaxWorkers    int           `mapstructure:"workers" default:"1"`
+	PrefetchCount int           `mapstructure:"prefetch_count" default:"10"`
+	Stream        string        `mapstructure:"stream"`
+	Subject       string        `mapstructure:"subject"`
+	Durable       string        `mapstructure:"durable"`
+	Queue         string        `mapstructure:"queue"`
+	AckWait       time.Duration `mapstructure:"ack_wait" default:"30s"`
+	MaxDeliver    int           `mapstructure:"max_deliver" default:"-1"`
+	RetryDelay    time.Duration `mapstructure:"retry_delay" default:"5s"`
+	Runner        runner.Config `mapstructure:"runner"`
+	// Restart override the --restart-* flags for this consumer, the fields not set use the flags.
+	Restart supervisor.RestartPolicy `mapstructure:"restart"`
+}
+
+func setConfigDefaults(config *Config) error {
+	if err := defaults.Set(config); err != nil {
+		return err
+	}
+
+	for k := range config.Connections {
+		cfg := config.Connections[k]
+		if err := defaults.Set(&cfg); err != nil {
+			return err
+		}
+		config.Connections[k] = cfg
+	}
+
+	for k := range config.Streams {
+		cfg := config.Streams[k]
+		if err := defaults.Set(&cfg); err != nil {
+			return err
+		}
+		config.Streams[k] = cfg
+	}
+
+	for k := range config.Consumers {
+		cfg := config.Consumers[k]
+		if err := defaults.Set(&cfg); err != nil {
+			return err
+		}
+		if len(cfg.Durable) == 0 {
+			cfg.Durable = k
+		}
+		cfg.Runner.SetDefaults(config.Version, cfg.MaxWorkers)
+		config.Consumers[k] = cfg
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package nats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_withDefaults(t *testing.T) {
+	config := Config{
+		Connections: map[string]Connection{
+			"default": {URL: "nats://localhost:4222"},
+		},
+		Streams: map[string]StreamConfig{
+			"pictures": {Subjects: []string{"pictures.>"}},
+		},
+		Consumers: map[string]ConsumerConfig{
+			"consumer1": {
+				Connection: "default",
+				Stream:     "pictures",
+				Runner: runner.Config{
+					Type: "http",
+					Options: runner.Options{
+						URL: "http://localhost:8080",
+					},
+				},
+			},
+		},
+		Version: "0.0.5",
+	}
+
+	err := setConfigDefaults(&config)
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Second, config.Connections["default"].Timeout)
+	require.Equal(t, 60, config.Connections["default"].MaxReconnects)
+	require.Equal(t, "file", config.Streams["pictures"].Storage)
+	require.Equal(t, "limits", config.Streams["pictures"].Retention)
+	require.Equal(t, 1, config.Streams["pictures"].Replicas)
+	require.Equal(t, "push", config.Consumers["consumer1"].Mode)
+	require.Equal(t, "consumer1", config.Consumers["consumer1"].Durable)
+	require.Equal(t, 1, config.Consumers["consumer1"].MaxWorkers)
+	require.Equal(t, 10, config.Consumers["consumer1"].PrefetchCount)
+	require.Equal(t, 30*time.Second, config.Consumers["consumer1"].AckWait)
+	require.Equal(t, -1, config.Consumers["consumer1"].MaxDeliver)
+	require.Equal(t, "message-cannon/0.0.5", config.Consumers["consumer1"].Runner.Options.Headers["User-Agent"])
+}
+
+func TestConsumerConfig_Action(t *testing.T) {
+	cfg := ConsumerConfig{Mode: "push", RetryDelay: 5 * time.Second}
+	require.Equal(t, "ack", cfg.Action(runner.ExitACK))
+	require.Equal(t, "nak, the message is delivered again", cfg.Action(runner.ExitFailed))
+	require.Equal(t, "term, the message is not delivered again", cfg.Action(runner.ExitNACK))
+	require.Equal(t, "nak with delay, the message is delivered again after 5s", cfg.Action(runner.ExitRetry))
+	require.Equal(t, "nak, the message is delivered again", cfg.Action(runner.ExitTimeout))
+	cfg.Mode = "core"
+	require.Equal(t, "none, the core subscriptions didn't have acknowledgements", cfg.Action(runner.ExitNACK))
+}
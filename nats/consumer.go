@@ -0,0 +1,327 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/internal/pool"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	natsio "github.com/nats-io/nats.go"
+)
+
+type consumer struct {
+	lastSuccess int64 // unix nano, keep it first for the 64-bit alignment
+	runner      runner.Runnable
+	hash        string
+	name        string
+	cfg         ConsumerConfig
+	workerPool  *pool.Pool
+	factoryName string
+	conn        *natsio.Conn
+	js          natsio.JetStreamContext
+	t           tomb.Tomb
+	hub         *hub.Hub
+}
+
+// Run start a goroutine to consume messages and pass to one runner.
+func (c *consumer) Run() {
+	c.t.Go(func() error {
+		msgs := make(chan *natsio.Msg, c.cfg.PrefetchCount)
+		sub, err := c.subscribe(msgs)
+		if err != nil {
+			c.hub.Publish(hub.Message{
+				Name:   "nats.consumer.error",
+				Body:   []byte("Failed to start consume"),
+				Fields: hub.Fields{"error": err},
+			})
+			return err
+		}
+		defer c.close(sub)
+		dying := c.t.Dying()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		for {
+			select {
+			case <-dying:
+				// When dying we wait for any remaining worker to finish
+				c.workerPool.Wait()
+				return nil
+			case <-ticker.C:
+				if c.conn.IsClosed() {
+					return errors.New("the NATS connection is closed")
+				}
+			case msg, ok := <-msgs:
+				if !ok {
+					c.hub.Publish(hub.Message{
+						Name:   "nats.consumer.error",
+						Body:   []byte("the subscription channel was closed. closing consumer"),
+						Fields: hub.Fields{},
+					})
+					return errors.New("the subscription channel was closed")
+				}
+				// When maxWorkers goroutines are in flight, Acquire blocks until one of the
+				// workers finishes.
+				c.workerPool.Acquire()
+				go func(msg *natsio.Msg) {
+					nctx := ctx
+					if c.cfg.Runner.Timeout >= time.Second {
+						var canc context.CancelFunc
+						nctx, canc = context.WithTimeout(ctx, c.cfg.Runner.Timeout)
+						defer canc()
+					}
+					c.processMessage(nctx, msg)
+					c.workerPool.Release()
+				}(msg)
+			}
+		}
+	})
+}
+
+// Kill will try to stop the internal work.
+func (c *consumer) Kill() {
+	c.t.Kill(nil)
+	<-c.t.Dead()
+}
+
+// Alive returns true if the tomb is not in a dying or dead state.
+func (c *consumer) Alive() bool {
+	return c.t.Alive()
+}
+
+// RestartPolicy return the restart policy from the consumer config.
+func (c *consumer) RestartPolicy() supervisor.RestartPolicy {
+	return c.cfg.Restart
+}
+
+// LastSuccess return the time of the last message acked.
+func (c *consumer) LastSuccess() time.Time {
+	last := atomic.LoadInt64(&c.lastSuccess)
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+// Name return the consumer name
+func (c *consumer) Name() string {
+	return c.name
+}
+
+// FactoryName is the name of the factory responsible for this consumer.
+func (c *consumer) FactoryName() string {
+	return c.factoryName
+}
+
+// Scale change the number of workers.
+func (c *consumer) Scale(workers int) error {
+	if workers < 1 {
+		return errors.New("the number of workers must be greater than zero")
+	}
+	c.workerPool.Resize(workers)
+	return nil
+}
+
+// Workers return the number of concurrent workers.
+func (c *consumer) Workers() int {
+	return c.workerPool.Size()
+}
+
+// InFlight return the number of messages being processed.
+func (c *consumer) InFlight() int {
+	return c.workerPool.InFlight()
+}
+
+func (c *consumer) subscribe(msgs chan *natsio.Msg) (*natsio.Subscription, error) {
+	switch c.cfg.Mode {
+	case "core":
+		if len(c.cfg.Queue) > 0 {
+			return c.conn.ChanQueueSubscribe(c.cfg.Subject, c.cfg.Queue, msgs)
+		}
+		return c.conn.ChanSubscribe(c.cfg.Subject, msgs)
+	case "pull":
+		sub, err := c.js.PullSubscribe(c.cfg.Subject, c.cfg.Durable,
+			natsio.Bind(c.cfg.Stream, c.cfg.Durable))
+		if err != nil {
+			return nil, err
+		}
+		c.t.Go(func() error {
+			return c.fetch(sub, msgs)
+		})
+		return sub, nil
+	}
+	opts := []natsio.SubOpt{natsio.Bind(c.cfg.Stream, c.cfg.Durable), natsio.ManualAck()}
+	if len(c.cfg.Queue) > 0 {
+		return c.js.ChanQueueSubscribe(c.cfg.Subject, c.cfg.Queue, msgs, opts...)
+	}
+	return c.js.ChanSubscribe(c.cfg.Subject, msgs, opts...)
+}
+
+// fetch pull batches of messages while the consumer is alive.
+func (c *consumer) fetch(sub *natsio.Subscription, msgs chan *natsio.Msg) error {
+	dying := c.t.Dying()
+	for {
+		select {
+		case <-dying:
+			return nil
+		default:
+		}
+		batch, err := sub.Fetch(c.workerPool.Size(), natsio.MaxWait(time.Second))
+		if err != nil && err != natsio.ErrTimeout && err != context.DeadlineExceeded {
+			c.hub.Publish(hub.Message{
+				Name:   "nats.consumer.error",
+				Body:   []byte("Failed to fetch messages"),
+				Fields: hub.Fields{"error": err},
+			})
+			return err
+		}
+		for _, msg := range batch {
+			select {
+			case msgs <- msg:
+			case <-dying:
+				return nil
+			}
+		}
+	}
+}
+
+func (c *consumer) close(sub *natsio.Subscription) {
+	// The durable consumers are bound and will not be deleted by Unsubscribe.
+	err := sub.Unsubscribe()
+	if err != nil && err != natsio.ErrConnectionClosed && err != natsio.ErrBadSubscription {
+		c.hub.Publish(hub.Message{
+			Name:   "nats.consumer.error",
+			Body:   []byte("Error closing the consumer subscription"),
+			Fields: hub.Fields{"error": err},
+		})
+	}
+	if closer, ok := c.runner.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			c.hub.Publish(hub.Message{
+				Name:   "nats.consumer.error",
+				Body:   []byte("Error closing the consumer runner"),
+				Fields: hub.Fields{"error": err},
+			})
+		}
+	}
+}
+
+func (c *consumer) processMessage(ctx context.Context, msg *natsio.Msg) {
+	var err error
+	c.hub.Publish(hub.Message{
+		Name:   "nats.process.start",
+		Fields: hub.Fields{"in-flight": c.workerPool.InFlight()},
+	})
+	start := time.Now()
+	stop := c.keepInProgress(msg)
+	status, err := c.runner.Process(ctx, runner.Message{Body: msg.Data, Headers: getHeaders(msg)})
+	close(stop)
+	duration := time.Since(start)
+	fields := hub.Fields{
+		"duration":    duration,
+		"status-code": status,
+		// this message still holds its worker until the ack
+		"in-flight": c.workerPool.InFlight() - 1,
+	}
+	topic := "nats.process.sucess"
+	if err != nil {
+		topic = "nats.process.error"
+		switch e := err.(type) {
+		case *runner.Error:
+			fields["error"] = e.Err
+			fields["exit-code"] = e.StatusCode
+			fields["output"] = e.Output
+		default:
+			fields["error"] = e
+		}
+	}
+	c.hub.Publish(hub.Message{
+		Name:   topic,
+		Fields: fields,
+	})
+	// core subscriptions didn't have acknowledgements
+	if c.cfg.Mode == "core" {
+		return
+	}
+	switch status {
+	case runner.ExitACK:
+		err = msg.Ack()
+		atomic.StoreInt64(&c.lastSuccess, time.Now().UnixNano())
+	case runner.ExitNACK:
+		err = msg.Term()
+	case runner.ExitRetry:
+		err = msg.NakWithDelay(c.cfg.RetryDelay)
+	case runner.ExitFailed, runner.ExitNACKRequeue, runner.ExitTimeout:
+		err = msg.Nak()
+	default:
+		c.hub.Publish(hub.Message{
+			Name:   "nats.consumer.error",
+			Body:   []byte("the runner returned an unexpected exitStatus. Message will be requeued."),
+			Fields: hub.Fields{"status": status},
+		})
+		err = msg.Nak()
+	}
+	if err != nil {
+		c.hub.Publish(hub.Message{
+			Name:   "nats.consumer.error",
+			Body:   []byte("error during the acknowledgement phase"),
+			Fields: hub.Fields{"error": err},
+		})
+	}
+}
+
+// Action describe what the consumer does with one message after the runner returns the status.
+// It follows the processMessage method and is used by the run command.
+func (c ConsumerConfig) Action(status int) string {
+	if c.Mode == "core" {
+		return "none, the core subscriptions didn't have acknowledgements"
+	}
+	switch status {
+	case runner.ExitACK:
+		return "ack"
+	case runner.ExitNACK:
+		return "term, the message is not delivered again"
+	case runner.ExitRetry:
+		return fmt.Sprintf("nak with delay, the message is delivered again after %s", c.RetryDelay)
+	case runner.ExitFailed, runner.ExitNACKRequeue, runner.ExitTimeout:
+		return "nak, the message is delivered again"
+	}
+	return "unexpected exit code, nak and the message is delivered again"
+}
+
+// keepInProgress tell the server that we are still working on the message
+// so it will not be redelivered while the runner didn't finish.
+func (c *consumer) keepInProgress(msg *natsio.Msg) chan struct{} {
+	stop := make(chan struct{})
+	if c.cfg.Mode == "core" || c.cfg.AckWait <= 0 {
+		return stop
+	}
+	go func() {
+		ticker := time.NewTicker(c.cfg.AckWait / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := msg.InProgress(); err != nil {
+					c.hub.Publish(hub.Message{
+						Name:   "nats.consumer.warning",
+						Body:   []byte("failed to send the in progress acknowledgement"),
+						Fields: hub.Fields{"error": err},
+					})
+				}
+			}
+		}
+	}()
+	return stop
+}
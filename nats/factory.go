@@ -0,0 +1,295 @@
+package nats
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/internal/pool"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	natsio "github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// Factory is the block responsible for create consumers and reopen the NATS connections.
+type Factory struct {
+	config Config
+	conns  map[string]*natsio.Conn
+	hub    *hub.Hub
+	number int64
+	// retired connections are closed after the reload.
+	retired []*natsio.Conn
+}
+
+// NewFactory will open the initial connections.
+func NewFactory(config Config, h *hub.Hub) (*Factory, error) {
+	err := setConfigDefaults(&config)
+	if err != nil {
+		h.Publish(hub.Message{
+			Name:   "nats.config.warning",
+			Body:   []byte("Failed to set default values for configs"),
+			Fields: hub.Fields{"error": err},
+		})
+	}
+	f := &Factory{
+		config,
+		make(map[string]*natsio.Conn),
+		h,
+		1,
+		nil,
+	}
+	for name, cfgConn := range config.Connections {
+		h.Publish(hub.Message{
+			Name: "nats.opening_connection.info",
+			Body: []byte("opening connection with NATS"),
+			Fields: hub.Fields{
+				"timeout":    cfgConn.Timeout,
+				"connection": name,
+			},
+		})
+		conn, err := f.openConnection(name, cfgConn)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error opening the connection \"%s\"", name)
+		}
+		f.conns[name] = conn
+	}
+	return f, nil
+}
+
+// CreateConsumers will iterate over config and create all the consumers
+func (f *Factory) CreateConsumers() ([]supervisor.Consumer, error) {
+	var consumers []supervisor.Consumer
+	for name, cfg := range f.config.Consumers {
+		consumer, err := f.newConsumer(name, cfg)
+		if err != nil {
+			return consumers, err
+		}
+		consumers = append(consumers, consumer)
+	}
+	return consumers, nil
+}
+
+// CreateConsumer create a new consumer for a specific name using the config provided.
+func (f *Factory) CreateConsumer(name string) (supervisor.Consumer, error) {
+	cfg, ok := f.config.Consumers[name]
+	if !ok {
+		return nil, errors.Errorf("consumer \"%s\" did not exist", name)
+	}
+	return f.newConsumer(name, cfg)
+}
+
+// Name return the factory name
+func (f *Factory) Name() string {
+	return "nats"
+}
+
+// CheckConnection return an error when the connection used by the consumer is not connected.
+func (f *Factory) CheckConnection(consumer string) error {
+	cfg, ok := f.config.Consumers[consumer]
+	if !ok {
+		return errors.Errorf("consumer \"%s\" did not exist", consumer)
+	}
+	conn, ok := f.conns[cfg.Connection]
+	if !ok || !conn.IsConnected() {
+		return errors.Errorf("the connection \"%s\" is not connected", cfg.Connection)
+	}
+	return nil
+}
+
+func (f *Factory) newConsumer(name string, cfg ConsumerConfig) (*consumer, error) {
+	conn, err := f.getConnection(cfg.Connection)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the NATS connection for consumer %s", name)
+	}
+	var js natsio.JetStreamContext
+	switch cfg.Mode {
+	case "core":
+	case "push", "pull":
+		if len(cfg.Stream) == 0 {
+			return nil, errors.Errorf("the %s consumer %s requires a stream", cfg.Mode, name)
+		}
+		js, err = conn.JetStream()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get the JetStream context")
+		}
+		if err = f.declareStream(js, cfg.Stream); err != nil {
+			return nil, err
+		}
+		if err = f.declareConsumer(js, cfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf(
+			"Invalid consumer mode (\"%s\") expecting one of (push, pull, core)", cfg.Mode)
+	}
+
+	runner, err := runner.New(cfg.Runner, f.hub.With(hub.Fields{"consumer": name}))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed creating a runner")
+	}
+	f.hub.Publish(hub.Message{
+		Name: "nats.declare.debug",
+		Body: []byte("consumer created"),
+		Fields: hub.Fields{
+			"max-workers": cfg.MaxWorkers,
+			"mode":        cfg.Mode,
+			"consumer":    name,
+		},
+	})
+	return &consumer{
+		name:        name,
+		hash:        strconv.FormatInt(atomic.AddInt64(&f.number, 1), 10),
+		cfg:         cfg,
+		factoryName: f.Name(),
+		conn:        conn,
+		js:          js,
+		t:           tomb.Tomb{},
+		runner:      runner,
+		hub:         f.hub.With(hub.Fields{"consumer": name}),
+		workerPool:  pool.New(cfg.MaxWorkers),
+	}, nil
+}
+
+func (f *Factory) declareStream(js natsio.JetStreamContext, name string) error {
+	stream, ok := f.config.Streams[name]
+	if !ok {
+		f.hub.Publish(hub.Message{
+			Name:   "nats.declare.warning",
+			Body:   []byte("stream config didn't exist, we will try to continue"),
+			Fields: hub.Fields{"stream": name},
+		})
+		return nil
+	}
+	f.hub.Publish(hub.Message{
+		Name: "nats.declare.info",
+		Body: []byte("declaring stream"),
+		Fields: hub.Fields{
+			"stream":   name,
+			"subjects": strings.Join(stream.Subjects, ", "),
+		},
+	})
+	sc := &natsio.StreamConfig{
+		Name:     name,
+		Subjects: stream.Subjects,
+		MaxAge:   stream.MaxAge,
+		Replicas: stream.Replicas,
+		Storage:  natsio.FileStorage,
+	}
+	if stream.Storage == "memory" {
+		sc.Storage = natsio.MemoryStorage
+	}
+	switch stream.Retention {
+	case "interest":
+		sc.Retention = natsio.InterestPolicy
+	case "workqueue":
+		sc.Retention = natsio.WorkQueuePolicy
+	default:
+		sc.Retention = natsio.LimitsPolicy
+	}
+	_, err := js.StreamInfo(name)
+	if err == natsio.ErrStreamNotFound {
+		_, err = js.AddStream(sc)
+	} else if err == nil {
+		_, err = js.UpdateStream(sc)
+	}
+	return errors.Wrapf(err, "failed to declare the stream \"%s\"", name)
+}
+
+// declareConsumer create (or update) the durable consumer on the server.
+// Consumers created by message-cannon survive restarts, they are bound by the subscriptions.
+func (f *Factory) declareConsumer(js natsio.JetStreamContext, cfg ConsumerConfig) error {
+	f.hub.Publish(hub.Message{
+		Name: "nats.declare.info",
+		Body: []byte("declaring consumer"),
+		Fields: hub.Fields{
+			"stream":  cfg.Stream,
+			"durable": cfg.Durable,
+			"mode":    cfg.Mode,
+		},
+	})
+	cc := &natsio.ConsumerConfig{
+		Durable:       cfg.Durable,
+		FilterSubject: cfg.Subject,
+		AckPolicy:     natsio.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+		MaxDeliver:    cfg.MaxDeliver,
+		MaxAckPending: cfg.PrefetchCount,
+	}
+	info, err := js.ConsumerInfo(cfg.Stream, cfg.Durable)
+	if cfg.Mode == "push" {
+		cc.DeliverGroup = cfg.Queue
+		cc.DeliverSubject = natsio.NewInbox()
+		if err == nil && len(info.Config.DeliverSubject) > 0 {
+			cc.DeliverSubject = info.Config.DeliverSubject
+		}
+	}
+	if err == natsio.ErrConsumerNotFound {
+		_, err = js.AddConsumer(cfg.Stream, cc)
+	} else if err == nil {
+		_, err = js.UpdateConsumer(cfg.Stream, cc)
+	}
+	return errors.Wrapf(err, "failed to declare the consumer \"%s\" on stream \"%s\"", cfg.Durable, cfg.Stream)
+}
+
+func (f *Factory) getConnection(connectionName string) (*natsio.Conn, error) {
+	conn, ok := f.conns[connectionName]
+	if !ok {
+		available := []string{}
+		for name := range f.conns {
+			available = append(available, name)
+		}
+		return nil, errors.Errorf(
+			"connection (%s) did not exist, connections names available: %s",
+			connectionName,
+			strings.Join(available, ", "))
+	}
+	// The client reconnects by itself, we only reopen connections closed after all the reconnect attempts.
+	if !conn.IsClosed() {
+		return conn, nil
+	}
+	f.hub.Publish(hub.Message{
+		Name:   "nats.reopening_connection.info",
+		Body:   []byte("reopening one connection closed"),
+		Fields: hub.Fields{"connection": connectionName},
+	})
+	conn, err := f.openConnection(connectionName, f.config.Connections[connectionName])
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reopening the connection \"%s\"", connectionName)
+	}
+	f.conns[connectionName] = conn
+	return conn, nil
+}
+
+func (f *Factory) openConnection(name string, config Connection) (*natsio.Conn, error) {
+	return natsio.Connect(config.URL,
+		natsio.Name("message-cannon/"+f.config.Version),
+		natsio.Timeout(config.Timeout),
+		natsio.ReconnectWait(config.ReconnectWait),
+		natsio.MaxReconnects(config.MaxReconnects),
+		natsio.DisconnectErrHandler(func(_ *natsio.Conn, err error) {
+			f.hub.Publish(hub.Message{
+				Name:   "nats.connection.warning",
+				Body:   []byte("connection lost"),
+				Fields: hub.Fields{"connection": name, "error": err},
+			})
+		}),
+		natsio.ReconnectHandler(func(_ *natsio.Conn) {
+			f.hub.Publish(hub.Message{
+				Name:   "nats.connection.info",
+				Body:   []byte("connection reestablished"),
+				Fields: hub.Fields{"connection": name},
+			})
+		}),
+		natsio.ClosedHandler(func(_ *natsio.Conn) {
+			f.hub.Publish(hub.Message{
+				Name:   "nats.connection.error",
+				Body:   []byte("connection closed"),
+				Fields: hub.Fields{"connection": name},
+			})
+		}),
+	)
+}
@@ -0,0 +1,25 @@
+package nats
+
+import (
+	"strconv"
+
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	natsio "github.com/nats-io/nats.go"
+)
+
+func getHeaders(msg *natsio.Msg) runner.Headers {
+	headers := runner.Headers{
+		"Subject":    msg.Subject,
+		"Message-Id": msg.Header.Get(natsio.MsgIdHdr),
+	}
+	for k, v := range msg.Header {
+		if len(v) > 0 {
+			headers[k] = v[0]
+		}
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		headers["Message-Deaths"] = strconv.FormatUint(meta.NumDelivered-1, 10)
+		headers["Stream-Sequence"] = int64(meta.Sequence.Stream)
+	}
+	return headers
+}
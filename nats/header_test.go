@@ -0,0 +1,52 @@
+package nats
+
+import (
+	"testing"
+
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	natsio "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		args *natsio.Msg
+		want runner.Headers
+	}{
+		{
+			"with empty headers",
+			&natsio.Msg{Subject: "pictures.upload", Data: []byte(`foooo`)},
+			runner.Headers{
+				"Subject":    "pictures.upload",
+				"Message-Id": "",
+			},
+		},
+		{
+			"with headers",
+			&natsio.Msg{
+				Subject: "pictures.upload",
+				Data:    []byte(`foooo`),
+				Header: natsio.Header{
+					"Nats-Msg-Id":    []string{"12345566"},
+					"Correlation-Id": []string{"id-12334455"},
+					"Content-Type":   []string{"application/json", "text/html"},
+				},
+			},
+			runner.Headers{
+				"Subject":        "pictures.upload",
+				"Message-Id":     "12345566",
+				"Nats-Msg-Id":    "12345566",
+				"Correlation-Id": "id-12334455",
+				"Content-Type":   "application/json",
+			},
+		},
+	}
+	for _, tt := range tests {
+		ctt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := getHeaders(ctt.args)
+			require.Exactly(t, ctt.want, got)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrationSuite(t *testing.T) {
+	tests := []struct {
+		scenario string
+		method   func(*testing.T, *server.Server)
+	}{
+		{
+			scenario: "validate the behavior when we have connection trouble",
+			method:   testFactoryShouldReturnConnectionErrors,
+		},
+		{
+			scenario: "validate the push consumer exit codes",
+			method:   testPushConsumerProcess,
+		},
+		{
+			scenario: "validate the pull consumer with retries",
+			method:   testPullConsumerRetry,
+		},
+		{
+			scenario: "validate the core consumer",
+			method:   testCoreConsumerProcess,
+		},
+		{
+			scenario: "validate that all the consumers will restart without problems",
+			method:   testConsumerReconnect,
+		},
+	}
+	// -> Setup
+	dir, err := ioutil.TempDir("", "nats")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  dir,
+	})
+	require.NoError(t, err, "Could not create the nats server")
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(5*time.Second), "nats server is not ready")
+	// -> TearDown
+	defer ns.Shutdown()
+	// -> Run!
+	for _, test := range tests {
+		tt := test
+		t.Run(test.scenario, func(st *testing.T) {
+			tt.method(st, ns)
+		})
+	}
+}
+
+func testFactoryShouldReturnConnectionErrors(t *testing.T, _ *server.Server) {
+	c := getConfig(t, "valid_jetstream_config.yml")
+	conn := c.Connections["default"]
+	conn.URL = "nats://127.0.0.1:1"
+	c.Connections["default"] = conn
+	_, err := NewFactory(c, hub.New())
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error opening the connection \"default\": ")
+}
+
+func testPushConsumerProcess(t *testing.T, ns *server.Server) {
+	config := getConfig(t, "valid_jetstream_config.yml")
+	config.Connections["default"] = setURL(ns, config.Connections["default"])
+	factory, err := NewFactory(config, hub.New())
+	require.NoError(t, err, "Failed to create the factory")
+	cons, err := factory.CreateConsumer("upload_picture")
+	require.NoError(t, err, "Failed to create the consumer")
+	mock := &mockRunner{exitStatus: runner.ExitACK}
+	cons.(*consumer).runner = mock
+	cons.Run()
+	defer cons.Kill()
+	js, err := factory.conns["default"].JetStream()
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = js.Publish("pictures.upload", []byte(`{"fooo": "bazzz"}`))
+		require.NoError(t, err, "error publishing to NATS")
+	}
+	require.Eventually(t, func() bool {
+		return mock.messagesProcessed() == 5
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		info, err := js.ConsumerInfo("pictures", "upload_picture")
+		return err == nil && info.NumAckPending == 0 && info.NumPending == 0
+	}, 2*time.Second, 10*time.Millisecond, "all the messages should be acked")
+	require.Equal(t, "pictures.upload", mock.lastHeaders()["Subject"])
+}
+
+func testPullConsumerRetry(t *testing.T, ns *server.Server) {
+	config := getConfig(t, "valid_jetstream_config.yml")
+	config.Connections["default"] = setURL(ns, config.Connections["default"])
+	factory, err := NewFactory(config, hub.New())
+	require.NoError(t, err, "Failed to create the factory")
+	cons, err := factory.CreateConsumer("resize_picture")
+	require.NoError(t, err, "Failed to create the consumer")
+	// retry the first delivery and ack the redelivery
+	mock := &mockRunner{exitStatus: runner.ExitACK, firstStatus: runner.ExitRetry}
+	cons.(*consumer).runner = mock
+	cons.Run()
+	defer cons.Kill()
+	js, err := factory.conns["default"].JetStream()
+	require.NoError(t, err)
+	_, err = js.Publish("pictures.resize", []byte(`{"fooo": "bazzz"}`))
+	require.NoError(t, err, "error publishing to NATS")
+	require.Eventually(t, func() bool {
+		return mock.messagesProcessed() == 2
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Equal(t, "1", mock.lastHeaders()["Message-Deaths"])
+}
+
+func testCoreConsumerProcess(t *testing.T, ns *server.Server) {
+	config := getConfig(t, "valid_jetstream_config.yml")
+	config.Connections["default"] = setURL(ns, config.Connections["default"])
+	factory, err := NewFactory(config, hub.New())
+	require.NoError(t, err, "Failed to create the factory")
+	cons, err := factory.CreateConsumer("notify")
+	require.NoError(t, err, "Failed to create the consumer")
+	mock := &mockRunner{exitStatus: runner.ExitACK}
+	cons.(*consumer).runner = mock
+	cons.Run()
+	defer cons.Kill()
+	// wait the subscription
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		err = factory.conns["default"].Publish("notifications.email", []byte(`{"fooo": "bazzz"}`))
+		require.NoError(t, err, "error publishing to NATS")
+	}
+	require.Eventually(t, func() bool {
+		return mock.messagesProcessed() == 3
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func testConsumerReconnect(t *testing.T, ns *server.Server) {
+	config := getConfig(t, "valid_jetstream_config.yml")
+	config.Connections["default"] = setURL(ns, config.Connections["default"])
+	h := hub.New()
+	reconectionSubscriber := h.Subscribe(10, "supervisor.recreating_consumer.*")
+	reopenSubscriber := h.Subscribe(10, "nats.reopening_connection.*")
+	factory, err := NewFactory(config, h)
+	require.NoError(t, err, "Failed to create the factory")
+	for name := range factory.config.Consumers {
+		cfg := factory.config.Consumers[name]
+		cfg.Runner = runner.Config{Type: "http", Options: runner.Options{URL: "http://localhost"}}
+		factory.config.Consumers[name] = cfg
+	}
+
+	// start the supervisor
+	sup := supervisor.NewManager(10*time.Millisecond, h)
+	err = sup.Start([]supervisor.Factory{factory})
+	require.NoError(t, err, "Failed to start the supervisor")
+	// force the connection to close
+	factory.conns["default"].Close()
+	//receive the message of consumer reconnect
+	select {
+	case <-reconectionSubscriber.Receiver:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the consumers should be recreated")
+	}
+	select {
+	case <-reopenSubscriber.Receiver:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the connection should be reopened")
+	}
+	sup.Stop()
+	require.False(t, factory.conns["default"].IsClosed(), "the connection should be reopened")
+}
+
+func getConfig(t *testing.T, configFile string) Config {
+	c := Config{}
+	viper.Reset()
+	viper.SetConfigType("yaml")
+	yaml, err := ioutil.ReadFile(filepath.Join("testdata", configFile))
+	assert.NoError(t, err, "Failed to read the config file: ")
+	err = viper.ReadConfig(bytes.NewBuffer(yaml))
+	assert.NoError(t, err)
+
+	err = viper.UnmarshalKey("nats", &c)
+	assert.NoError(t, err, "Failed to marshal the config struct: ")
+	assert.NoError(t, setConfigDefaults(&c))
+	return c
+}
+
+func setURL(ns *server.Server, conn Connection) Connection {
+	conn.URL = ns.ClientURL()
+	return conn
+}
+
+type mockRunner struct {
+	mu          sync.Mutex
+	count       int64
+	exitStatus  int
+	firstStatus int
+	headers     runner.Headers
+}
+
+func (m *mockRunner) Process(_ context.Context, msg runner.Message) (int, error) {
+	m.mu.Lock()
+	m.headers = msg.Headers
+	m.mu.Unlock()
+	if atomic.AddInt64(&m.count, 1) == 1 && m.firstStatus != 0 {
+		return m.firstStatus, nil
+	}
+	return m.exitStatus, nil
+}
+
+func (m *mockRunner) messagesProcessed() int64 {
+	return atomic.LoadInt64(&m.count)
+}
+
+func (m *mockRunner) lastHeaders() runner.Headers {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.headers
+}
+
+// make sure the mock didn't break the Runnable interface
+var _ runner.Runnable = &mockRunner{}
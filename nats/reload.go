@@ -0,0 +1,82 @@
+package nats
+
+import (
+	"reflect"
+
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	natsio "github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// Reload take the config and the connections from a new factory.
+// The connections with the same config are kept, so the consumers not changed keep running.
+// The connections replaced are closed by Release, after the old consumers were killed.
+func (f *Factory) Reload(nf supervisor.Factory) ([]string, []string, error) {
+	n, ok := nf.(*Factory)
+	if !ok {
+		return nil, nil, errors.Errorf("can't reload the NATS factory using a %T", nf)
+	}
+	changedConns := map[string]bool{}
+	for name, conn := range n.conns {
+		old, exist := f.conns[name]
+		if exist && reflect.DeepEqual(f.config.Connections[name], n.config.Connections[name]) {
+			// keep the running connection and drop the one opened by the new factory
+			conn.Close()
+			n.conns[name] = old
+			continue
+		}
+		changedConns[name] = true
+	}
+	for name, conn := range f.conns {
+		if n.conns[name] != conn {
+			f.retired = append(f.retired, conn)
+		}
+	}
+	changed := []string{}
+	removed := []string{}
+	for name, cfg := range f.config.Consumers {
+		ncfg, exist := n.config.Consumers[name]
+		switch {
+		case !exist:
+			removed = append(removed, name)
+		case changedConns[ncfg.Connection] || !reflect.DeepEqual(cfg, ncfg):
+			changed = append(changed, name)
+		// the streams are declared by the consumers using them.
+		case !reflect.DeepEqual(f.config.Streams[cfg.Stream], n.config.Streams[ncfg.Stream]):
+			changed = append(changed, name)
+		}
+	}
+	for name := range n.config.Consumers {
+		if _, exist := f.config.Consumers[name]; !exist {
+			changed = append(changed, name)
+		}
+	}
+	f.config = n.config
+	f.conns = n.conns
+	return changed, removed, nil
+}
+
+// Release close the connections replaced by the last Reload.
+func (f *Factory) Release() {
+	for _, conn := range f.retired {
+		conn.Close()
+	}
+	f.retired = nil
+}
+
+// Close all the connections opened by this factory.
+func (f *Factory) Close() error {
+	for _, conn := range append(f.retired, connections(f.conns)...) {
+		conn.Close()
+	}
+	f.retired = nil
+	return nil
+}
+
+func connections(conns map[string]*natsio.Conn) []*natsio.Conn {
+	list := make([]*natsio.Conn, 0, len(conns))
+	for _, conn := range conns {
+		list = append(list, conn)
+	}
+	return list
+}
@@ -0,0 +1,74 @@
+package nats
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	natsio "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFactory_Reload(t *testing.T) {
+	newFactory := func(consumers map[string]ConsumerConfig, streams map[string]StreamConfig) *Factory {
+		return &Factory{
+			config: Config{Consumers: consumers, Streams: streams},
+			conns:  map[string]*natsio.Conn{},
+			hub:    hub.New(),
+		}
+	}
+	consumer := func(stream, url string) ConsumerConfig {
+		return ConsumerConfig{
+			Connection: "default",
+			Mode:       "pull",
+			Stream:     stream,
+			Subject:    stream + ".created",
+			Runner:     runner.Config{Type: "http", Options: runner.Options{URL: url}},
+		}
+	}
+	streams := map[string]StreamConfig{
+		"orders":   {Subjects: []string{"orders.>"}},
+		"payments": {Subjects: []string{"payments.>"}},
+	}
+	running := newFactory(map[string]ConsumerConfig{
+		"orders":   consumer("orders", "http://localhost/orders"),
+		"payments": consumer("payments", "http://localhost/payments"),
+		"refunds":  consumer("payments", "http://localhost/refunds"),
+		"invoices": consumer("orders", "http://localhost/invoices"),
+	}, streams)
+
+	changed, removed, err := running.Reload(newFactory(map[string]ConsumerConfig{
+		"orders":   consumer("orders", "http://localhost/orders"),
+		"payments": consumer("payments", "http://localhost/v2/payments"),
+		"refunds":  consumer("payments", "http://localhost/refunds"),
+		"shipping": consumer("orders", "http://localhost/shipping"),
+	}, streams))
+	require.NoError(t, err)
+	sort.Strings(changed)
+	require.Equal(t, []string{"payments", "shipping"}, changed)
+	require.Equal(t, []string{"invoices"}, removed)
+	require.Equal(t, "http://localhost/v2/payments", running.config.Consumers["payments"].Runner.Options.URL)
+
+	// changing one stream recreate only the consumers using it
+	changed, removed, err = running.Reload(newFactory(running.config.Consumers, map[string]StreamConfig{
+		"orders":   {Subjects: []string{"orders.>"}},
+		"payments": {Subjects: []string{"payments.>"}, Replicas: 3},
+	}))
+	require.NoError(t, err)
+	sort.Strings(changed)
+	require.Equal(t, []string{"payments", "refunds"}, changed)
+	require.Empty(t, removed)
+
+	_, _, err = running.Reload(&otherFactory{})
+	require.EqualError(t, err, "can't reload the NATS factory using a *nats.otherFactory")
+}
+
+type otherFactory struct{}
+
+func (f *otherFactory) CreateConsumers() ([]supervisor.Consumer, error)      { return nil, nil }
+func (f *otherFactory) CreateConsumer(_ string) (supervisor.Consumer, error) { return nil, nil }
+func (f *otherFactory) Name() string                                         { return "other" }
+
+var _ supervisor.Reloader = &Factory{}
@@ -0,0 +1,50 @@
+package nats
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/pkg/errors"
+)
+
+// Validate apply the default values and check the config without opening any connection.
+// Every problem is returned with the path of the option, ie: "consumers.upload.connection: ...".
+func Validate(config Config) []error {
+	var errs []error
+	if err := setConfigDefaults(&config); err != nil {
+		return []error{errors.Wrap(err, "failed to set default values for configs")}
+	}
+	names := make([]string, 0, len(config.Consumers))
+	for name := range config.Consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cfg := config.Consumers[name]
+		path := fmt.Sprintf("consumers.%s", name)
+		if _, ok := config.Connections[cfg.Connection]; !ok {
+			errs = append(errs, errors.Errorf("%s.connection: connection \"%s\" did not exist", path, cfg.Connection))
+		}
+		switch cfg.Mode {
+		case "core":
+		case "push", "pull":
+			if _, ok := config.Streams[cfg.Stream]; !ok {
+				errs = append(errs, errors.Errorf("%s.stream: stream \"%s\" did not exist", path, cfg.Stream))
+			}
+		default:
+			errs = append(errs, errors.Errorf("%s.mode: invalid consumer mode (\"%s\") expecting one of (push, pull, core)", path, cfg.Mode))
+		}
+		if len(cfg.Subject) == 0 {
+			errs = append(errs, errors.Errorf("%s.subject: the subject is required", path))
+		}
+		if cfg.PrefetchCount < cfg.MaxWorkers {
+			errs = append(errs, errors.Errorf("%s.prefetch_count: the prefetch_count (%d) must be greater or equal than workers (%d)",
+				path, cfg.PrefetchCount, cfg.MaxWorkers))
+		}
+		for _, err := range runner.Validate(cfg.Runner) {
+			errs = append(errs, errors.Errorf("%s.runner.%s", path, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,42 @@
+package rabbit
+
+import (
+	"context"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/streadway/amqp"
+)
+
+func (c *consumer) batchJob(msgs []amqp.Delivery, throttled time.Duration) func(context.Context) {
+	return func(ctx context.Context) {
+		c.processBatch(ctx, msgs, throttled)
+	}
+}
+
+// processBatch send the messages to the runner in one call, every message is acked or rejected on its own.
+// throttled is the time all the messages waited the rate limit.
+func (c *consumer) processBatch(ctx context.Context, msgs []amqp.Delivery, throttled time.Duration) {
+	c.hub.Publish(hub.Message{
+		Name:   "rabbit.process.start",
+		Fields: hub.Fields{"in-flight": c.workerPool.InFlight(), "throttled": throttled, "batch-size": len(msgs)},
+	})
+	start := time.Now()
+	rmsgs := make([]runner.Message, len(msgs))
+	for i, msg := range msgs {
+		rmsgs[i] = runner.Message{Body: msg.Body, Headers: getHeaders(msg)}
+	}
+	results := runner.ProcessBatch(ctx, c.runner, rmsgs)
+	duration := time.Since(start)
+	for i, msg := range msgs {
+		results[i].Status = requeueCanceled(ctx, results[i].Status, results[i].Err)
+		c.publishResult(results[i].Status, results[i].Err, hub.Fields{
+			"duration":   duration,
+			"batch-size": len(msgs),
+			// the worker running this batch is released after the last message
+			"in-flight": c.workerPool.InFlight() - 1,
+		})
+		c.handle(msg, results[i].Status, nil)
+	}
+}
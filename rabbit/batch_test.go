@@ -0,0 +1,81 @@
+package rabbit
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/internal/pool"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+// acknowledger record how every delivery was acknowledged.
+type acknowledger struct {
+	mu   sync.Mutex
+	acks map[uint64]string
+}
+
+func (a *acknowledger) set(tag uint64, ack string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acks[tag] = ack
+	return nil
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error { return a.set(tag, "ack") }
+func (a *acknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	if requeue {
+		return a.set(tag, "nack-requeue")
+	}
+	return a.set(tag, "nack")
+}
+func (a *acknowledger) Reject(tag uint64, requeue bool) error { return a.set(tag, "reject") }
+
+// batchRunner return the exit code from the message body.
+type batchRunner struct {
+	batches [][]runner.Message
+}
+
+func (r *batchRunner) Process(ctx context.Context, msg runner.Message) (int, error) {
+	return runner.ExitACK, nil
+}
+
+func (r *batchRunner) ProcessBatch(ctx context.Context, msgs []runner.Message) []runner.Result {
+	r.batches = append(r.batches, msgs)
+	results := make([]runner.Result, len(msgs))
+	for i, msg := range msgs {
+		results[i].Status = int(msg.Body[0] - '0')
+	}
+	return results
+}
+
+func Test_consumer_processBatch(t *testing.T) {
+	a := &acknowledger{acks: map[uint64]string{}}
+	r := &batchRunner{}
+	h := hub.New()
+	sub := h.Subscribe(10, "rabbit.process.*")
+	c := &consumer{runner: r, hub: h, workerPool: pool.New(1)}
+	msgs := []amqp.Delivery{
+		{Acknowledger: a, DeliveryTag: 1, Body: []byte("0")},
+		{Acknowledger: a, DeliveryTag: 2, Body: []byte("4")},
+		{Acknowledger: a, DeliveryTag: 3, Body: []byte("3")},
+	}
+	c.processBatch(context.Background(), msgs, 0)
+
+	require.Len(t, r.batches, 1, "the messages must be sent in one call")
+	require.Len(t, r.batches[0], 3)
+	require.Equal(t, map[uint64]string{1: "ack", 2: "nack-requeue", 3: "nack"}, a.acks)
+	require.False(t, c.LastSuccess().IsZero())
+
+	start := <-sub.Receiver
+	require.Equal(t, "rabbit.process.start", start.Name)
+	require.Equal(t, 3, start.Fields["batch-size"])
+	for _, status := range []int{0, 4, 3} {
+		msg := <-sub.Receiver
+		require.Equal(t, "rabbit.process.sucess", msg.Name)
+		require.Equal(t, status, msg.Fields["status-code"])
+	}
+}
@@ -1,11 +1,11 @@
 package rabbit
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/creasty/defaults"
 	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
 	"github.com/streadway/amqp"
 )
 
@@ -21,27 +21,97 @@ type Config struct {
 	DeadLetters map[string]DeadLetter `mapstructure:"dead_letters" default:"{}"`
 	// Consumers describes configuration list for consumers.
 	Consumers map[string]ConsumerConfig `mapstructure:"consumers" default:"{}"`
+	// RateLimiters are the named rate limits shared by all the consumers using them,
+	// ie: every consumer calling the same API.
+	RateLimiters map[string]RateLimiter `mapstructure:"rate_limiters" default:"{}"`
 	//Versioning internal config - used to mount the user agents
 	Version string
 }
 
 // Connection describe a config for one connection.
+// The TLS config is used by the connections with an amqps:// DSN.
 type Connection struct {
-	DSN     string        `mapstructure:"dsn"`
-	Timeout time.Duration `mapstructure:"timeout" default:"2s"`
-	Sleep   time.Duration `mapstructure:"sleep" default:"500ms"`
-	Retries int           `mapstructure:"retries" default:"5"`
+	DSN string `mapstructure:"dsn"`
+	// DSNs are the nodes of one cluster, used instead of the DSN.
+	DSNs []string `mapstructure:"dsns"`
+	// Hosts replace the host of the DSN keeping the user, password and vhost, ie: ["rabbit-1:5672", "rabbit-2:5672"].
+	Hosts []string `mapstructure:"hosts"`
+	// HostSelection is how the node is chosen on every dial: round-robin or shuffle.
+	HostSelection string        `mapstructure:"host_selection" default:"round-robin"`
+	Timeout       time.Duration `mapstructure:"timeout" default:"2s"`
+	Sleep         time.Duration `mapstructure:"sleep" default:"500ms"`
+	Retries       int           `mapstructure:"retries" default:"5"`
+	TLS           TLSConfig     `mapstructure:"tls"`
+	// MaxSleep limit the backoff used to reopen the connections closed by the broker.
+	MaxSleep time.Duration `mapstructure:"max_sleep" default:"30s"`
+}
+
+// TLSConfig describe the certificates used to connect with the broker.
+// With ExternalAuth the client certificate is used to authenticate (SASL EXTERNAL) instead of the DSN user and password.
+type TLSConfig struct {
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	ServerName         string `mapstructure:"server_name"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	ExternalAuth       bool   `mapstructure:"external_auth"`
 }
 
 // ConsumerConfig describes consumer's configuration.
+// With Reply the runner output of the messages acked is published to their ReplyTo queue.
+// With a BatchSize greater than 1 the messages are sent to the runner in batches,
+// waiting at most the BatchTimeout to fill one batch.
+// Restart override the --restart-* flags for this consumer, the fields not set use the flags.
 type ConsumerConfig struct {
-	Connection    string        `mapstructure:"connection"`
-	MaxWorkers    int           `mapstructure:"workers" default:"1"`
-	PrefetchCount int           `mapstructure:"prefetch_count" default:"10"`
-	DeadLetter    string        `mapstructure:"dead_letter"`
-	Queue         QueueConfig   `mapstructure:"queue"`
-	Options       Options       `mapstructure:"options"`
-	Runner        runner.Config `mapstructure:"runner"`
+	Connection    string                   `mapstructure:"connection"`
+	MaxWorkers    int                      `mapstructure:"workers" default:"1"`
+	PrefetchCount int                      `mapstructure:"prefetch_count" default:"10"`
+	BatchSize     int                      `mapstructure:"batch_size"`
+	BatchTimeout  time.Duration            `mapstructure:"batch_timeout" default:"1s"`
+	DeadLetter    string                   `mapstructure:"dead_letter"`
+	Reply         bool                     `mapstructure:"reply"`
+	RateLimit     RateLimitConfig          `mapstructure:"rate_limit"`
+	Output        OutputConfig             `mapstructure:"output"`
+	Retry         *RetryConfig             `mapstructure:"retry"`
+	Queue         QueueConfig              `mapstructure:"queue"`
+	Options       Options                  `mapstructure:"options"`
+	Runner        runner.Config            `mapstructure:"runner"`
+	Restart       supervisor.RestartPolicy `mapstructure:"restart"`
+}
+
+// RateLimitConfig limit the messages per second passed to the runner, the workers only limit the concurrency.
+// Limiter use one of the shared rate_limiters instead of a limit only for this consumer.
+type RateLimitConfig struct {
+	Rate    float64 `mapstructure:"rate"`
+	Burst   int     `mapstructure:"burst"`
+	Limiter string  `mapstructure:"limiter"`
+}
+
+// RateLimiter describe one token bucket: Rate is the messages per second and Burst is the bucket size (default 1).
+type RateLimiter struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// RetryConfig describes how the messages returning ExitRetry are delayed before being delivered again.
+// Every delay has its own queue, the messages expire there and are dead-lettered back to the consumer queue.
+// Without the retry config the messages returning ExitRetry are requeued right away.
+type RetryConfig struct {
+	// Delay used by the first retry, the next ones are multiplied by the Multiplier.
+	Delay      time.Duration `mapstructure:"delay" default:"1s"`
+	Multiplier float64       `mapstructure:"multiplier" default:"2"`
+	MaxDelay   time.Duration `mapstructure:"max_delay" default:"1h"`
+	// MaxRetries before rejecting the message to the queue dead letter.
+	MaxRetries int `mapstructure:"max_retries" default:"5"`
+}
+
+// OutputConfig describes where the runner output of the messages acked is published.
+type OutputConfig struct {
+	Exchange string `mapstructure:"exchange"`
+	// RoutingKey is a text/template using the incoming message, ie: "pictures.{{ index .Headers \"x-tenant\" }}.resized".
+	RoutingKey  string `mapstructure:"routing_key"`
+	ContentType string `mapstructure:"content_type"`
+	Persistent  bool   `mapstructure:"persistent"`
 }
 
 // ExchangeConfig describes exchange's configuration.
@@ -99,13 +169,12 @@ func setConfigDefaults(config *Config) error {
 		if err := defaults.Set(&cfg); err != nil {
 			return err
 		}
-		if len(cfg.Runner.Options.Headers) == 0 {
-			cfg.Runner.Options.Headers = map[string]string{}
-		}
-		_, exist := cfg.Runner.Options.Headers["User-Agent"]
-		if cfg.Runner.Type == "http" && !exist {
-			cfg.Runner.Options.Headers["User-Agent"] = fmt.Sprint("message-cannon/", config.Version)
+		if cfg.Retry != nil {
+			if err := defaults.Set(cfg.Retry); err != nil {
+				return err
+			}
 		}
+		cfg.Runner.SetDefaults(config.Version, cfg.MaxWorkers)
 		config.Consumers[k] = cfg
 	}
 
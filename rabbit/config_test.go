@@ -14,6 +14,10 @@ func Test_withDefaults(t *testing.T) {
 			"de": {DSN: "amqp://localhost:5672"},
 		},
 		Consumers: map[string]ConsumerConfig{
+			"consumer2": {
+				Queue: QueueConfig{Name: "baaar"},
+				Retry: &RetryConfig{MaxRetries: 3},
+			},
 			"consumer1": {
 				Connection: "server1",
 				Queue:      QueueConfig{Name: "fooo"},
@@ -36,6 +40,8 @@ func Test_withDefaults(t *testing.T) {
 	require.Equal(t, 1, config.Consumers["consumer1"].MaxWorkers)
 	require.Equal(t, 10, config.Consumers["consumer1"].PrefetchCount)
 	require.Equal(t, 4, config.Consumers["consumer1"].Runner.Options.ReturnOn5xx)
+	require.Nil(t, config.Consumers["consumer1"].Retry, "the retry queues are only used when configured")
+	require.Equal(t, &RetryConfig{Delay: time.Second, Multiplier: 2, MaxDelay: time.Hour, MaxRetries: 3}, config.Consumers["consumer2"].Retry)
 	require.Equal(t, "message-cannon/0.0.5", config.Consumers["consumer1"].Runner.Options.Headers["User-Agent"])
 	config.Consumers["consumer1"].Runner.Options.Headers["User-Agent"] = "UserAgent From Config"
 	err = setConfigDefaults(&config)
@@ -0,0 +1,196 @@
+package rabbit
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+	"gopkg.in/tomb.v2"
+)
+
+// connection keep one rabbitMQ connection open, reopening it when the broker close the connection.
+// The reconnections use an exponential backoff, starting with the sleep and limited by the max_sleep.
+// The blocked and unblocked notifications (memory and disk alarms) are published on the hub.
+type connection struct {
+	name    string
+	config  Connection
+	version string
+	nodes   *nodes
+	hub     *hub.Hub
+	t       tomb.Tomb
+
+	mu     sync.RWMutex
+	conn   *amqp.Connection
+	closed chan *amqp.Error
+	// recovered is called after the connection was reopened.
+	recovered func(connection string)
+}
+
+func newConnection(name string, config Connection, version string, h *hub.Hub) (*connection, error) {
+	n, err := newNodes(config)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := openConnection(name, n, config, version, h)
+	if err != nil {
+		return nil, err
+	}
+	c := &connection{name: name, config: config, version: version, nodes: n, hub: h}
+	closed, blocked := c.replace(conn)
+	c.t.Go(func() error {
+		c.monitor(closed, blocked)
+		return nil
+	})
+	return c, nil
+}
+
+// Channel open a new channel, the error is amqp.ErrClosed while the connection is reopened.
+func (c *connection) Channel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn.Channel()
+}
+
+// check return an error when the connection is closed.
+func (c *connection) check() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	// the channel receive the error and is closed after the connection shutdown
+	select {
+	case <-c.closed:
+		return errors.Errorf("the connection \"%s\" is closed", c.name)
+	default:
+		return nil
+	}
+}
+
+// notify set the function called after the connection was reopened.
+func (c *connection) notify(fn func(connection string)) {
+	c.mu.Lock()
+	c.recovered = fn
+	c.mu.Unlock()
+}
+
+// Close stop the reconnections and close the connection.
+func (c *connection) Close() error {
+	c.t.Kill(nil)
+	_ = c.t.Wait()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn.Close()
+}
+
+// replace start using the new connection, returning the channels watched by the monitor.
+// The check uses its own close channel, because the receive takes the error.
+func (c *connection) replace(conn *amqp.Connection) (chan *amqp.Error, chan amqp.Blocking) {
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	blocked := conn.NotifyBlocked(make(chan amqp.Blocking, 1))
+	c.mu.Lock()
+	c.conn = conn
+	c.closed = conn.NotifyClose(make(chan *amqp.Error, 1))
+	c.mu.Unlock()
+	return closed, blocked
+}
+
+func (c *connection) monitor(closed chan *amqp.Error, blocked chan amqp.Blocking) {
+	for {
+		select {
+		case <-c.t.Dying():
+			return
+		case b, ok := <-blocked:
+			if !ok {
+				blocked = nil
+				continue
+			}
+			c.publishBlocking(b)
+		case err := <-closed:
+			// the channel is closed without any error when the connection is closed by us
+			if err == nil {
+				return
+			}
+			c.hub.Publish(hub.Message{
+				Name:   "rabbit.connection.warning",
+				Body:   []byte("the connection was closed by the broker, reconnecting"),
+				Fields: hub.Fields{"connection": c.name, "error": err},
+			})
+			conn, attempts, rerr := c.reconnect()
+			if rerr != nil {
+				return
+			}
+			closed, blocked = c.replace(conn)
+			c.hub.Publish(hub.Message{
+				Name:   "rabbit.reopening_connection.info",
+				Body:   []byte("connection reopened"),
+				Fields: hub.Fields{"connection": c.name, "attempts": attempts},
+			})
+			c.mu.RLock()
+			recovered := c.recovered
+			c.mu.RUnlock()
+			if recovered != nil {
+				recovered(c.name)
+			}
+		}
+	}
+}
+
+func (c *connection) publishBlocking(b amqp.Blocking) {
+	if b.Active {
+		c.hub.Publish(hub.Message{
+			Name:   "rabbit.connection.warning",
+			Body:   []byte("the broker blocked the connection"),
+			Fields: hub.Fields{"connection": c.name, "reason": b.Reason},
+		})
+		return
+	}
+	c.hub.Publish(hub.Message{
+		Name:   "rabbit.connection.info",
+		Body:   []byte("the broker unblocked the connection"),
+		Fields: hub.Fields{"connection": c.name},
+	})
+}
+
+// reconnect dial the nodes until one of them accept the connection or the connection is closed.
+func (c *connection) reconnect() (*amqp.Connection, int, error) {
+	cfg, err := amqpConfig(c.config, c.version)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "invalid tls config")
+	}
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-c.t.Dying():
+			return nil, attempt, tomb.ErrDying
+		case <-time.After(backoff(attempt, c.config.Sleep, c.config.MaxSleep)):
+		}
+		conn, err := dial(c.name, c.nodes, cfg, c.hub)
+		if err != nil {
+			continue
+		}
+		select {
+		case <-c.t.Dying():
+			_ = conn.Close()
+			return nil, attempt + 1, tomb.ErrDying
+		default:
+			return conn, attempt + 1, nil
+		}
+	}
+}
+
+// backoff return the sleep before one reconnection attempt, doubling the sleep until the max.
+// The sleep is jittered between the half and the full value, so the instances don't reconnect all at once.
+func backoff(attempt int, sleep, maxSleep time.Duration) time.Duration {
+	d := sleep
+	for i := 0; i < attempt && d < maxSleep; i++ {
+		d *= 2
+	}
+	if d > maxSleep {
+		d = maxSleep
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
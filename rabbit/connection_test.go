@@ -0,0 +1,39 @@
+package rabbit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_backoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 250 * time.Millisecond, 500 * time.Millisecond},
+		{1, 500 * time.Millisecond, time.Second},
+		{3, 2 * time.Second, 4 * time.Second},
+		{10, 15 * time.Second, 30 * time.Second},
+		{100, 15 * time.Second, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := backoff(tt.attempt, 500*time.Millisecond, 30*time.Second)
+			require.True(t, got >= tt.min && got <= tt.max, "attempt %d: %s should be between %s and %s", tt.attempt, got, tt.min, tt.max)
+		}
+	}
+	require.Zero(t, backoff(3, 0, 0))
+}
+
+func TestFactory_ConnectionConsumers(t *testing.T) {
+	f := &Factory{config: Config{Consumers: map[string]ConsumerConfig{
+		"upload": {Connection: "default"},
+		"resize": {Connection: "default"},
+		"report": {Connection: "reports"},
+	}}}
+	require.Equal(t, []string{"resize", "upload"}, f.ConnectionConsumers("default"))
+	require.Equal(t, []string{"report"}, f.ConnectionConsumers("reports"))
+	require.Empty(t, f.ConnectionConsumers("missing"))
+}
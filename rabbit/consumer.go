@@ -3,27 +3,48 @@ package rabbit
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/tomb.v2"
 
 	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/internal/pool"
 	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
 	"github.com/streadway/amqp"
+	"golang.org/x/time/rate"
 )
 
 type consumer struct {
-	runner      runner.Runnable
-	hash        string
-	name        string
-	queue       string
-	workerPool  pool
-	timeout     time.Duration
-	factoryName string
-	opts        Options
-	channel     *amqp.Channel
-	t           tomb.Tomb
-	hub         *hub.Hub
+	lastSuccess   int64 // unix nano, keep it first for the 64-bit alignment
+	killTimeout   int64 // nanoseconds waiting the messages in flight when killed, zero means no limit
+	paused        int32
+	circuitOpen   int32
+	runner        runner.Runnable
+	hash          string
+	name          string
+	queue         string
+	workerPool    *pool.Pool
+	limiter       *rate.Limiter
+	prefetchCount int
+	batchSize     int
+	batchTimeout  time.Duration
+	timeout       time.Duration
+	factoryName   string
+	opts          Options
+	retryCfg      *RetryConfig
+	restart       supervisor.RestartPolicy
+	reply         bool
+	output        *output
+	channel       *amqp.Channel
+	publisher     *publisher
+	// control notify the consume loop about a pause or resume.
+	control chan struct{}
+	t       tomb.Tomb
+	hub     *hub.Hub
 }
 
 // Run start a goroutine to consume messages and pass to one runner.
@@ -38,34 +59,90 @@ func (c *consumer) Run() {
 					Fields: hub.Fields{"error": err},
 				})
 			}
+			if c.publisher != nil {
+				err = c.publisher.Close()
+				if err != nil {
+					c.hub.Publish(hub.Message{
+						Name:   "rabbit.consumer.error",
+						Body:   []byte("Error closing the retry channel"),
+						Fields: hub.Fields{"error": err},
+					})
+				}
+			}
+			// Runners holding resources (like long-lived processes) are released with the consumer.
+			if closer, ok := c.runner.(io.Closer); ok {
+				if err := closer.Close(); err != nil {
+					c.hub.Publish(hub.Message{
+						Name:   "rabbit.consumer.error",
+						Body:   []byte("Error closing the consumer runner"),
+						Fields: hub.Fields{"error": err},
+					})
+				}
+			}
 		}()
-		d, err := c.channel.Consume(c.queue, "rabbitmq-"+c.name+"-"+c.hash,
-			c.opts.AutoAck,
-			c.opts.Exclusive,
-			c.opts.NoLocal,
-			c.opts.NoWait,
-			c.opts.Args)
+		tag := "rabbitmq-" + c.name + "-" + c.hash
+		d, err := c.consume(tag)
 		if err != nil {
-			c.hub.Publish(hub.Message{
-				Name:   "rabbit.consumer.error",
-				Body:   []byte("Failed to start consume"),
-				Fields: hub.Fields{"error": err},
-			})
 			return err
 		}
+		// consuming is false after the consume is canceled by a pause,
+		// d is kept until the messages already delivered are drained.
+		consuming := true
 		dying := c.t.Dying()
 		closed := c.channel.NotifyClose(make(chan *amqp.Error))
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
+		// the messages waiting to fill one batch, they are requeued when the consumer dies.
+		var (
+			batch          []amqp.Delivery
+			batchThrottled time.Duration
+			flush          <-chan time.Time
+		)
 		for {
 			select {
+			case <-flush:
+				c.dispatch(ctx, c.batchJob(batch, batchThrottled))
+				batch, batchThrottled, flush = nil, 0, nil
 			case <-dying:
-				// When dying we wait for any remaining worker to finish
-				c.workerPool.Wait()
+				// When dying we stop the consume and wait for the remaining workers to finish,
+				// the messages not processed are requeued when the channel is closed.
+				c.drain(tag, consuming, cancel)
 				return nil
 			case err := <-closed:
 				return err
+			case <-c.control:
+				switch stop := c.stopped(); {
+				case stop && consuming:
+					if err := c.channel.Cancel(tag, false); err != nil {
+						c.hub.Publish(hub.Message{
+							Name:   "rabbit.consumer.error",
+							Body:   []byte("Failed to cancel the consume"),
+							Fields: hub.Fields{"error": err},
+						})
+						return err
+					}
+					consuming = false
+					c.hub.Publish(hub.Message{Name: "rabbit.consumer.info", Body: []byte("consumer paused"), Fields: hub.Fields{"circuit-open": !c.Paused()}})
+				case !stop && !consuming && d == nil:
+					if d, err = c.consume(tag); err != nil {
+						return err
+					}
+					consuming = true
+					c.hub.Publish(hub.Message{Name: "rabbit.consumer.info", Body: []byte("consumer resumed")})
+				}
 			case msg, ok := <-d:
+				if !ok && !consuming {
+					// the canceled consume was drained, resume if requested meanwhile.
+					d = nil
+					if !c.stopped() {
+						if d, err = c.consume(tag); err != nil {
+							return err
+						}
+						consuming = true
+						c.hub.Publish(hub.Message{Name: "rabbit.consumer.info", Body: []byte("consumer resumed")})
+					}
+					continue
+				}
 				if !ok {
 					c.hub.Publish(hub.Message{
 						Name:   "rabbit.consumer.error",
@@ -74,24 +151,142 @@ func (c *consumer) Run() {
 					})
 					return errors.New("receive an empty delivery")
 				}
-				// When maxWorkers goroutines are in flight, Acquire blocks until one of the
-				// workers finishes.
-				c.workerPool.Acquire()
-				go func(msg amqp.Delivery) {
-					nctx := ctx
-					if c.timeout >= time.Second {
-						var canc context.CancelFunc
-						nctx, canc = context.WithTimeout(ctx, c.timeout)
-						defer canc()
-					}
-					c.processMessage(nctx, msg)
-					c.workerPool.Release()
-				}(msg)
+				// The rate limit is applied before the workers, a message waiting a token don't hold a worker.
+				throttled, err := c.throttle(c.t.Context(nil))
+				if err != nil {
+					// the consumer is dying, the message is requeued when the channel is closed.
+					continue
+				}
+				if c.batchSize < 2 {
+					c.dispatch(ctx, func(ctx context.Context) {
+						c.processMessage(ctx, msg, throttled)
+					})
+					continue
+				}
+				batch = append(batch, msg)
+				batchThrottled += throttled
+				if len(batch) == 1 {
+					flush = time.After(c.batchTimeout)
+				}
+				if len(batch) == c.batchSize {
+					c.dispatch(ctx, c.batchJob(batch, batchThrottled))
+					batch, batchThrottled, flush = nil, 0, nil
+				}
 			}
 		}
 	})
 }
 
+// dispatch run the job in one worker applying the runner timeout.
+// When maxWorkers goroutines are in flight, Acquire blocks until one of the workers finishes.
+func (c *consumer) dispatch(ctx context.Context, job func(context.Context)) {
+	c.workerPool.Acquire()
+	go func() {
+		nctx := ctx
+		if c.timeout >= time.Second {
+			var canc context.CancelFunc
+			nctx, canc = context.WithTimeout(ctx, c.timeout)
+			defer canc()
+		}
+		job(nctx)
+		c.workerPool.Release()
+	}()
+}
+
+func (c *consumer) consume(tag string) (<-chan amqp.Delivery, error) {
+	d, err := c.channel.Consume(c.queue, tag,
+		c.opts.AutoAck,
+		c.opts.Exclusive,
+		c.opts.NoLocal,
+		c.opts.NoWait,
+		c.opts.Args)
+	if err != nil {
+		c.hub.Publish(hub.Message{
+			Name:   "rabbit.consumer.error",
+			Body:   []byte("Failed to start consume"),
+			Fields: hub.Fields{"error": err},
+		})
+	}
+	return d, err
+}
+
+// Pause cancel the consume keeping the channel open, the messages already delivered are processed.
+func (c *consumer) Pause() error {
+	return c.setPaused(1)
+}
+
+// Resume start to consume again after a pause.
+func (c *consumer) Resume() error {
+	return c.setPaused(0)
+}
+
+// Paused returns true if the consumer was paused.
+func (c *consumer) Paused() bool {
+	return atomic.LoadInt32(&c.paused) == 1
+}
+
+func (c *consumer) setPaused(paused int32) error {
+	if !c.t.Alive() {
+		return errors.New("the consumer is not running")
+	}
+	atomic.StoreInt32(&c.paused, paused)
+	c.wake()
+	return nil
+}
+
+// circuitChanged stop consuming while the runner circuit is open.
+// The half-open state consume again, the circuit breaker only let one message probe the runner.
+func (c *consumer) circuitChanged(state string) {
+	var open int32
+	if state == runner.CircuitOpen {
+		open = 1
+	}
+	atomic.StoreInt32(&c.circuitOpen, open)
+	c.wake()
+}
+
+// stopped returns true when the consume must be canceled, by a pause or by the circuit breaker.
+func (c *consumer) stopped() bool {
+	return c.Paused() || atomic.LoadInt32(&c.circuitOpen) == 1
+}
+
+// wake notify the consume loop about a pause or resume.
+func (c *consumer) wake() {
+	select {
+	case c.control <- struct{}{}:
+	default:
+		// one notification is already waiting
+	}
+}
+
+// Scale change the number of workers, the QoS is increased when the prefetch_count is lower than the workers.
+func (c *consumer) Scale(workers int) error {
+	if workers < 1 {
+		return errors.New("the number of workers must be greater than zero")
+	}
+	if err := c.channel.Qos(max(c.prefetchCount, workers), 0, false); err != nil {
+		return err
+	}
+	c.workerPool.Resize(workers)
+	return nil
+}
+
+// Workers return the number of concurrent workers.
+func (c *consumer) Workers() int {
+	return c.workerPool.Size()
+}
+
+// InFlight return the number of messages being processed.
+func (c *consumer) InFlight() int {
+	return c.workerPool.InFlight()
+}
+
+// KillTimeout stop the consume and wait the messages in flight at most the timeout.
+func (c *consumer) KillTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&c.killTimeout, int64(timeout))
+	c.Kill()
+}
+
 // Kill will try to stop the internal work.
 func (c *consumer) Kill() {
 	c.t.Kill(nil)
@@ -103,6 +298,20 @@ func (c *consumer) Alive() bool {
 	return c.t.Alive()
 }
 
+// RestartPolicy return the restart policy from the consumer config.
+func (c *consumer) RestartPolicy() supervisor.RestartPolicy {
+	return c.restart
+}
+
+// LastSuccess return the time of the last message acked.
+func (c *consumer) LastSuccess() time.Time {
+	last := atomic.LoadInt64(&c.lastSuccess)
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
 // Name return the consumer name
 func (c *consumer) Name() string {
 	return c.name
@@ -113,15 +322,37 @@ func (c *consumer) FactoryName() string {
 	return c.factoryName
 }
 
-func (c *consumer) processMessage(ctx context.Context, msg amqp.Delivery) {
-	var err error
+// processMessage call the runner, throttled is the time the message waited the rate limit.
+func (c *consumer) processMessage(ctx context.Context, msg amqp.Delivery, throttled time.Duration) {
+	var (
+		status int
+		output []byte
+		err    error
+	)
+	c.hub.Publish(hub.Message{
+		Name:   "rabbit.process.start",
+		Fields: hub.Fields{"in-flight": c.workerPool.InFlight(), "throttled": throttled},
+	})
 	start := time.Now()
-	status, err := c.runner.Process(ctx, runner.Message{Body: msg.Body, Headers: getHeaders(msg)})
-	duration := time.Since(start)
-	fields := hub.Fields{
-		"duration":    duration,
-		"status-code": status,
+	rmsg := runner.Message{Body: msg.Body, Headers: getHeaders(msg)}
+	if c.shouldReply(msg) || c.output != nil {
+		status, output, err = runner.ProcessOutput(ctx, c.runner, rmsg)
+	} else {
+		status, err = c.runner.Process(ctx, rmsg)
 	}
+	status = requeueCanceled(ctx, status, err)
+	c.publishResult(status, err, hub.Fields{
+		"duration":  time.Since(start),
+		"throttled": throttled,
+		// the worker running this message is released after this event
+		"in-flight": c.workerPool.InFlight() - 1,
+	})
+	c.handle(msg, status, output)
+}
+
+// publishResult publish the rabbit.process.* event of one message.
+func (c *consumer) publishResult(status int, err error, fields hub.Fields) {
+	fields["status-code"] = status
 	topic := "rabbit.process.sucess"
 	if err != nil {
 		topic = "rabbit.process.error"
@@ -138,12 +369,19 @@ func (c *consumer) processMessage(ctx context.Context, msg amqp.Delivery) {
 		Name:   topic,
 		Fields: fields,
 	})
+}
+
+// handle ack, reject or retry the message using the runner exit code.
+func (c *consumer) handle(msg amqp.Delivery, status int, output []byte) {
+	var err error
 	switch status {
 	case runner.ExitACK:
-		err = msg.Ack(false)
+		err = c.ack(msg, output)
 	case runner.ExitFailed:
 		err = msg.Reject(true)
-	case runner.ExitRetry, runner.ExitNACKRequeue, runner.ExitTimeout:
+	case runner.ExitRetry:
+		err = c.retry(msg)
+	case runner.ExitNACKRequeue, runner.ExitTimeout:
 		err = msg.Nack(false, true)
 	case runner.ExitNACK:
 		err = msg.Nack(false, false)
@@ -163,3 +401,42 @@ func (c *consumer) processMessage(ctx context.Context, msg amqp.Delivery) {
 		})
 	}
 }
+
+// Action describe what the consumer does with one message after the runner returns the status.
+// It follows the handle method and is used by the run command.
+func (c ConsumerConfig) Action(status int) string {
+	switch status {
+	case runner.ExitACK:
+		return "ack"
+	case runner.ExitFailed:
+		return "reject, the message is requeued"
+	case runner.ExitRetry:
+		if c.Retry == nil {
+			return "nack, the message is requeued"
+		}
+		if len(c.Queue.Name) == 0 {
+			return "nack, the message is requeued (server named queues didn't have retry queues)"
+		}
+		return fmt.Sprintf("retry after %s, rejected to the dead letter after %d retries", c.Retry.delay(0), c.Retry.MaxRetries)
+	case runner.ExitNACKRequeue, runner.ExitTimeout:
+		return "nack, the message is requeued"
+	case runner.ExitNACK:
+		return "nack, the message is rejected to the dead letter"
+	}
+	return "unexpected exit code, the message is requeued"
+}
+
+// ack publish the reply and the output before acking the message.
+func (c *consumer) ack(msg amqp.Delivery, output []byte) error {
+	if c.shouldReply(msg) && c.sendReply(msg, output) != nil {
+		return msg.Nack(false, true)
+	}
+	if c.output != nil {
+		if requeue, err := c.sendOutput(msg, output); err != nil {
+			return msg.Nack(false, requeue)
+		}
+	}
+	err := msg.Ack(false)
+	atomic.StoreInt64(&c.lastSuccess, time.Now().UnixNano())
+	return err
+}
@@ -0,0 +1,38 @@
+package rabbit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_consumer_circuitChanged(t *testing.T) {
+	c := &consumer{control: make(chan struct{}, 1)}
+	c.circuitChanged(runner.CircuitOpen)
+	require.True(t, c.stopped())
+	require.False(t, c.Paused(), "the circuit must not change the admin pause")
+	require.Len(t, c.control, 1)
+
+	c.circuitChanged(runner.CircuitHalfOpen)
+	require.False(t, c.stopped())
+	require.Len(t, c.control, 1, "only one notification should wait the consume loop")
+}
+
+func TestConsumerConfig_Action(t *testing.T) {
+	cfg := ConsumerConfig{
+		Queue: QueueConfig{Name: "upload_picture"},
+		Retry: &RetryConfig{Delay: time.Second, Multiplier: 2, MaxDelay: time.Hour, MaxRetries: 3},
+	}
+	require.Equal(t, "ack", cfg.Action(runner.ExitACK))
+	require.Equal(t, "reject, the message is requeued", cfg.Action(runner.ExitFailed))
+	require.Equal(t, "retry after 1s, rejected to the dead letter after 3 retries", cfg.Action(runner.ExitRetry))
+	require.Equal(t, "nack, the message is requeued", cfg.Action(runner.ExitTimeout))
+	require.Equal(t, "nack, the message is rejected to the dead letter", cfg.Action(runner.ExitNACK))
+	require.Equal(t, "unexpected exit code, the message is requeued", cfg.Action(42))
+	cfg.Queue.Name = ""
+	require.Contains(t, cfg.Action(runner.ExitRetry), "server named queues")
+	cfg.Retry = nil
+	require.Equal(t, "nack, the message is requeued", cfg.Action(runner.ExitRetry))
+}
@@ -0,0 +1,321 @@
+package rabbit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+	"golang.org/x/time/rate"
+)
+
+// DeadMessage is one message from a dead letter queue.
+type DeadMessage struct {
+	// Position of the message in the queue, starting from 1.
+	Position   int
+	MessageID  string
+	Exchange   string
+	RoutingKey string
+	Headers    amqp.Table
+	Body       []byte
+	Deaths     []Death
+	// TotalDeaths count the deaths of all the queues, except the expirations used by the delays.
+	TotalDeaths int64
+	delivery    amqp.Delivery
+}
+
+func newDeadMessage(position int, d amqp.Delivery) DeadMessage {
+	m := DeadMessage{
+		Position:   position,
+		MessageID:  d.MessageId,
+		Exchange:   d.Exchange,
+		RoutingKey: d.RoutingKey,
+		Headers:    d.Headers,
+		Body:       d.Body,
+		Deaths:     []Death{},
+		delivery:   d,
+	}
+	if xdeaths, ok := d.Headers["x-death"].([]interface{}); ok {
+		m.Deaths = parseDeaths(xdeaths)
+		m.TotalDeaths = processDeaths(xdeaths)
+	}
+	return m
+}
+
+// Origin return the exchange and routing key used before the message was rejected.
+// The expirations are skipped because the retry and delay queues are not the origin.
+func (m DeadMessage) Origin() (exchange string, routingKey string) {
+	for _, d := range m.Deaths {
+		if d.Reason == "expired" {
+			continue
+		}
+		return originOf(d)
+	}
+	if len(m.Deaths) > 0 {
+		return originOf(m.Deaths[0])
+	}
+	return "", ""
+}
+
+func originOf(d Death) (string, string) {
+	if len(d.RoutingKeys) == 0 {
+		return d.Exchange, ""
+	}
+	return d.Exchange, d.RoutingKeys[0]
+}
+
+// publishing copy the message to be published again, without the delayed retries count.
+func (m DeadMessage) publishing() amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range m.Headers {
+		if k == retryCountHeader {
+			continue
+		}
+		headers[k] = v
+	}
+	d := m.delivery
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    d.DeliveryMode,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		ReplyTo:         d.ReplyTo,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
+}
+
+// DeadLetterFilter select the messages, the empty fields match all the messages.
+type DeadLetterFilter struct {
+	// RoutingKey match the origin routing key or the routing key used to dead letter the message.
+	RoutingKey string
+	Headers    map[string]string
+	MessageID  string
+}
+
+func (f DeadLetterFilter) empty() bool {
+	return len(f.RoutingKey) == 0 && len(f.Headers) == 0 && len(f.MessageID) == 0
+}
+
+func (f DeadLetterFilter) match(m DeadMessage) bool {
+	if len(f.MessageID) > 0 && f.MessageID != m.MessageID {
+		return false
+	}
+	if len(f.RoutingKey) > 0 {
+		_, key := m.Origin()
+		if f.RoutingKey != key && f.RoutingKey != m.RoutingKey {
+			return false
+		}
+	}
+	for k, v := range f.Headers {
+		hv, ok := m.Headers[k]
+		if !ok || tableValue(hv) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func tableValue(v interface{}) string {
+	switch vt := v.(type) {
+	case string:
+		return vt
+	case []byte:
+		return string(vt)
+	}
+	return fmt.Sprint(v)
+}
+
+// ReplayOptions change how the messages are published again.
+type ReplayOptions struct {
+	// Limit the number of messages replayed, zero means all the messages matching the filter.
+	Limit int
+	// Rate is the max messages per second, zero means no limit.
+	Rate float64
+	// Exchange and RoutingKey replace the origin of the messages when not empty.
+	Exchange   string
+	RoutingKey string
+}
+
+// DeadLetterQueue inspect and replay the messages of one dead letter from the config.
+// The messages are read with basic.get without ack, the messages not replayed or purged are requeued in the same order.
+type DeadLetterQueue struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	queue   string
+}
+
+// OpenDeadLetterQueue open the connection used to read the dead letter queue.
+func OpenDeadLetterQueue(config Config, connection, name string, h *hub.Hub) (*DeadLetterQueue, error) {
+	if err := setConfigDefaults(&config); err != nil {
+		return nil, errors.Wrap(err, "failed to set default values for configs")
+	}
+	dead, ok := config.DeadLetters[name]
+	if !ok {
+		return nil, errors.Errorf("dead letter \"%s\" did not exist", name)
+	}
+	if len(dead.Queue.Name) == 0 {
+		return nil, errors.Errorf("the dead letter \"%s\" didn't have a queue name", name)
+	}
+	cfgConn, ok := config.Connections[connection]
+	if !ok {
+		return nil, errors.Errorf("connection (%s) did not exist", connection)
+	}
+	n, err := newNodes(cfgConn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening the connection \"%s\"", connection)
+	}
+	conn, err := openConnection(connection, n, cfgConn, config.Version, h)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening the connection \"%s\"", connection)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to open the rabbitMQ channel")
+	}
+	return &DeadLetterQueue{conn: conn, channel: ch, queue: dead.Queue.Name}, nil
+}
+
+// Queue return the name of the dead letter queue.
+func (q *DeadLetterQueue) Queue() string {
+	return q.queue
+}
+
+// Browse return the messages matching the filter, all the messages are kept in the queue.
+func (q *DeadLetterQueue) Browse(filter DeadLetterFilter, limit int) ([]DeadMessage, error) {
+	msgs := []DeadMessage{}
+	err := q.scan(func(m DeadMessage) (bool, bool, error) {
+		if filter.match(m) {
+			msgs = append(msgs, m)
+		}
+		return false, limit > 0 && len(msgs) >= limit, nil
+	})
+	return msgs, err
+}
+
+// Replay publish the messages matching the filter to their origin, with publisher confirms.
+// The messages are removed from the dead letter queue only after the broker confirmed the publish.
+// fn is called after every message replayed.
+func (q *DeadLetterQueue) Replay(ctx context.Context, filter DeadLetterFilter, opts ReplayOptions, fn func(DeadMessage)) (int, error) {
+	ch, err := q.conn.Channel()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to open the publisher channel")
+	}
+	defer ch.Close()
+	pub, err := newPublisher(ch)
+	if err != nil {
+		return 0, err
+	}
+	// the unroutable messages would be lost after the ack
+	pub.mandatory()
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if opts.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.Rate), 1)
+	}
+	replayed := 0
+	err = q.scan(func(m DeadMessage) (bool, bool, error) {
+		if !filter.match(m) {
+			return false, false, nil
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return false, true, err
+		}
+		exchange, key := m.Origin()
+		if len(opts.Exchange) > 0 || len(opts.RoutingKey) > 0 {
+			exchange, key = opts.Exchange, opts.RoutingKey
+		}
+		if err := pub.Publish(exchange, key, m.publishing()); err != nil {
+			return false, true, errors.Wrapf(err, "failed to replay the message %d", m.Position)
+		}
+		if err := m.delivery.Ack(false); err != nil {
+			return false, true, errors.Wrapf(err, "the message %d was replayed but not removed from the queue", m.Position)
+		}
+		replayed++
+		if fn != nil {
+			fn(m)
+		}
+		return true, opts.Limit > 0 && replayed >= opts.Limit, nil
+	})
+	return replayed, err
+}
+
+// Purge remove the messages matching the filter, without filter and limit the whole queue is purged.
+func (q *DeadLetterQueue) Purge(filter DeadLetterFilter, limit int) (int, error) {
+	if filter.empty() && limit == 0 {
+		n, err := q.channel.QueuePurge(q.queue, false)
+		return n, errors.Wrapf(err, "failed to purge the queue \"%s\"", q.queue)
+	}
+	purged := 0
+	err := q.scan(func(m DeadMessage) (bool, bool, error) {
+		if !filter.match(m) {
+			return false, false, nil
+		}
+		if err := m.delivery.Ack(false); err != nil {
+			return false, true, errors.Wrapf(err, "failed to remove the message %d", m.Position)
+		}
+		purged++
+		return true, limit > 0 && purged >= limit, nil
+	})
+	return purged, err
+}
+
+// Close the channel and the connection, the messages not acked are requeued by the broker.
+func (q *DeadLetterQueue) Close() error {
+	if err := q.channel.Close(); err != nil {
+		q.conn.Close()
+		return err
+	}
+	return q.conn.Close()
+}
+
+func (q *DeadLetterQueue) scan(fn func(DeadMessage) (acked bool, done bool, err error)) error {
+	state, err := q.channel.QueueInspect(q.queue)
+	if err != nil {
+		return errors.Wrapf(err, "failed to inspect the queue \"%s\"", q.queue)
+	}
+	return scan(q.channel, q.queue, state.Messages, fn)
+}
+
+// getter is the part of the channel used by the scan.
+type getter interface {
+	Get(queue string, autoAck bool) (amqp.Delivery, bool, error)
+}
+
+// scan get at most max messages without ack, fn returns if the message was acked and if the scan is done.
+// The messages not acked are requeued at the end, the messages published while scanning are not read.
+func scan(g getter, queue string, max int, fn func(DeadMessage) (acked bool, done bool, err error)) (err error) {
+	var pending *amqp.Delivery
+	defer func() {
+		if pending == nil {
+			return
+		}
+		// one nack requeue all the messages not acked, keeping their order
+		if nerr := pending.Nack(true, true); nerr != nil && err == nil {
+			err = errors.Wrap(nerr, "failed to requeue the messages")
+		}
+	}()
+	for position := 1; position <= max; position++ {
+		d, ok, err := g.Get(queue, false)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get the messages from \"%s\"", queue)
+		}
+		if !ok {
+			return nil
+		}
+		acked, done, err := fn(newDeadMessage(position, d))
+		if !acked {
+			pending = &d
+		}
+		if err != nil || done {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package rabbit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+// queueGetter return the deliveries as a queue.
+type queueGetter struct {
+	deliveries []amqp.Delivery
+}
+
+func (g *queueGetter) Get(_ string, _ bool) (amqp.Delivery, bool, error) {
+	if len(g.deliveries) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+	d := g.deliveries[0]
+	g.deliveries = g.deliveries[1:]
+	return d, true, nil
+}
+
+func deadDelivery(ack *acknowledger, tag uint64, id, key string) amqp.Delivery {
+	deathTime := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	return amqp.Delivery{
+		Acknowledger: ack,
+		DeliveryTag:  tag,
+		MessageId:    id,
+		Exchange:     "fallback",
+		RoutingKey:   "upload",
+		Headers: amqp.Table{
+			"tenant":         "acme",
+			retryCountHeader: int64(5),
+			"x-death": []interface{}{
+				amqp.Table{"queue": "upload", "reason": "rejected", "count": int64(1), "exchange": "upload-picture",
+					"routing-keys": []interface{}{key}, "time": deathTime},
+				amqp.Table{"queue": "upload.retry.1s", "reason": "expired", "count": int64(5), "exchange": "",
+					"routing-keys": []interface{}{"upload.retry.1s"}, "time": deathTime},
+			},
+		},
+	}
+}
+
+func TestDeadMessage(t *testing.T) {
+	m := newDeadMessage(3, deadDelivery(nil, 1, "msg-1", "android.profile.upload"))
+	require.Equal(t, 3, m.Position)
+	require.Equal(t, int64(1), m.TotalDeaths, "the expirations are not counted")
+	require.Len(t, m.Deaths, 2)
+	require.Equal(t, Death{
+		Queue:       "upload",
+		Reason:      "rejected",
+		Exchange:    "upload-picture",
+		RoutingKeys: []string{"android.profile.upload"},
+		Count:       1,
+		Time:        time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+	}, m.Deaths[0])
+	exchange, key := m.Origin()
+	require.Equal(t, "upload-picture", exchange)
+	require.Equal(t, "android.profile.upload", key)
+
+	p := m.publishing()
+	require.NotContains(t, p.Headers, retryCountHeader, "the replayed messages start the retries again")
+	require.Equal(t, "acme", p.Headers["tenant"])
+	require.Equal(t, "msg-1", p.MessageId)
+}
+
+func TestDeadLetterFilter_match(t *testing.T) {
+	m := newDeadMessage(1, deadDelivery(nil, 1, "msg-1", "android.profile.upload"))
+	tests := []struct {
+		name   string
+		filter DeadLetterFilter
+		want   bool
+	}{
+		{"empty filter", DeadLetterFilter{}, true},
+		{"origin routing key", DeadLetterFilter{RoutingKey: "android.profile.upload"}, true},
+		{"dead letter routing key", DeadLetterFilter{RoutingKey: "upload"}, true},
+		{"other routing key", DeadLetterFilter{RoutingKey: "ios.profile.upload"}, false},
+		{"header", DeadLetterFilter{Headers: map[string]string{"tenant": "acme"}}, true},
+		{"numeric header", DeadLetterFilter{Headers: map[string]string{retryCountHeader: "5"}}, true},
+		{"other header value", DeadLetterFilter{Headers: map[string]string{"tenant": "other"}}, false},
+		{"missing header", DeadLetterFilter{Headers: map[string]string{"region": "eu"}}, false},
+		{"message id", DeadLetterFilter{MessageID: "msg-2"}, false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, tt.filter.match(m), tt.name)
+	}
+}
+
+func Test_scan(t *testing.T) {
+	ack := &acknowledger{acks: map[uint64]string{}}
+	g := &queueGetter{}
+	for i, key := range []string{"a", "b", "a", "b", "a"} {
+		g.deliveries = append(g.deliveries, deadDelivery(ack, uint64(i+1), "", key))
+	}
+	filter := DeadLetterFilter{RoutingKey: "a"}
+	var positions []int
+	err := scan(g, "fallback", 4, func(m DeadMessage) (bool, bool, error) {
+		if !filter.match(m) {
+			return false, false, nil
+		}
+		positions = append(positions, m.Position)
+		return true, false, m.delivery.Ack(false)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 3}, positions, "only the messages in the queue when the scan started are read")
+	require.Equal(t, map[uint64]string{1: "ack", 3: "ack", 4: "nack-requeue"}, ack.acks,
+		"one nack requeue all the messages not acked")
+	require.Len(t, g.deliveries, 1)
+}
@@ -1,7 +1,7 @@
 package rabbit
 
 import (
-	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -9,19 +9,28 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/internal/pool"
 	"github.com/leandro-lugaresi/message-cannon/runner"
 	"github.com/leandro-lugaresi/message-cannon/supervisor"
 	"github.com/pkg/errors"
 	retry "github.com/rafaeljesus/retry-go"
 	"github.com/streadway/amqp"
+	"golang.org/x/time/rate"
 )
 
 // Factory is the block responsible for create consumers and restart the rabbitMQ connections.
 type Factory struct {
 	config Config
-	conns  map[string]*amqp.Connection
-	hub    *hub.Hub
-	number int64
+	// conns are the connections used by the consumers, reopened when the broker close them.
+	conns map[string]*connection
+	// retired connections are closed after the reload.
+	retired []*connection
+	// limiters are the rate limiters shared by the consumers.
+	limiters map[string]*rate.Limiter
+	// recovered is called after one connection was reopened.
+	recovered func(connection string)
+	hub       *hub.Hub
+	number    int64
 }
 
 // NewFactory will open the initial connections and start the recover connections procedure.
@@ -34,7 +43,7 @@ func NewFactory(config Config, h *hub.Hub) (*Factory, error) {
 			Fields: hub.Fields{"error": err},
 		})
 	}
-	conns := make(map[string]*amqp.Connection)
+	conns := make(map[string]*connection)
 	for name, cfgConn := range config.Connections {
 		h.Publish(hub.Message{
 			Name: "rabbit.opening_connection.info",
@@ -45,8 +54,11 @@ func NewFactory(config Config, h *hub.Hub) (*Factory, error) {
 				"connection": name,
 			},
 		})
-		conn, err := openConnection(cfgConn, config.Version)
+		conn, err := newConnection(name, cfgConn, config.Version, h)
 		if err != nil {
+			for _, opened := range conns {
+				_ = opened.Close()
+			}
 			return nil, errors.Wrapf(err, "error opening the connection \"%s\"", name)
 		}
 		conns[name] = conn
@@ -54,6 +66,9 @@ func NewFactory(config Config, h *hub.Hub) (*Factory, error) {
 	f := &Factory{
 		config,
 		conns,
+		nil,
+		newLimiters(config.RateLimiters),
+		nil,
 		h,
 		1,
 	}
@@ -87,11 +102,65 @@ func (f *Factory) Name() string {
 	return "rabbitmq"
 }
 
-func (f *Factory) newConsumer(name string, cfg ConsumerConfig) (*consumer, error) {
+// CheckConnection return an error when the connection used by the consumer is closed.
+func (f *Factory) CheckConnection(consumer string) error {
+	cfg, ok := f.config.Consumers[consumer]
+	if !ok {
+		return errors.Errorf("consumer \"%s\" did not exist", consumer)
+	}
+	conn, ok := f.conns[cfg.Connection]
+	if !ok {
+		return errors.Errorf("connection (%s) did not exist", cfg.Connection)
+	}
+	return conn.check()
+}
+
+// NotifyRecovered register the function called after one connection was reopened.
+func (f *Factory) NotifyRecovered(fn func(connection string)) {
+	f.recovered = fn
+	for _, conn := range f.conns {
+		conn.notify(fn)
+	}
+}
+
+// ConnectionConsumers return the consumers using the connection.
+func (f *Factory) ConnectionConsumers(connection string) []string {
+	names := []string{}
+	for name, cfg := range f.config.Consumers {
+		if cfg.Connection == connection {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *Factory) newConsumer(name string, cfg ConsumerConfig) (_ *consumer, err error) {
+	// the batches are checked here too because the launch didn't run the validate command
+	if cfg.BatchSize > 1 {
+		if errs := validateBatch(name, cfg); len(errs) > 0 {
+			msgs := make([]string, len(errs))
+			for i, err := range errs {
+				msgs[i] = err.Error()
+			}
+			return nil, errors.Errorf("invalid batch config: %s", strings.Join(msgs, "; "))
+		}
+	}
 	ch, err := f.getChannel(cfg.Connection)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to open the rabbitMQ channel for consumer %s", name)
 	}
+	var pub *publisher
+	// the channels are only kept by the consumer created
+	defer func() {
+		if err == nil {
+			return
+		}
+		ch.Close()
+		if pub != nil {
+			pub.Close()
+		}
+	}()
 	if len(cfg.DeadLetter) > 0 {
 		err = f.declareDeadLetters(ch, cfg.DeadLetter)
 		if err != nil {
@@ -102,6 +171,34 @@ func (f *Factory) newConsumer(name string, cfg ConsumerConfig) (*consumer, error
 	if err != nil {
 		return nil, err
 	}
+	// server named queues can't receive the messages back from the retry queues
+	if len(cfg.Queue.Name) > 0 && cfg.Retry != nil {
+		err = f.declareRetryQueues(ch, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	out, err := newOutput(cfg.Output)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the output for consumer %s", name)
+	}
+	if out != nil && len(out.exchange) > 0 {
+		err = f.declareExchange(ch, out.exchange)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if (len(cfg.Queue.Name) > 0 && cfg.Retry != nil) || cfg.Reply || out != nil {
+		pch, err := f.getChannel(cfg.Connection)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open the publisher channel for consumer %s", name)
+		}
+		pub, err = newPublisher(pch)
+		if err != nil {
+			pch.Close()
+			return nil, err
+		}
+	}
 	f.hub.Publish(hub.Message{
 		Name: "rabbit.declare.debug",
 		Body: []byte("setting QoS"),
@@ -114,7 +211,11 @@ func (f *Factory) newConsumer(name string, cfg ConsumerConfig) (*consumer, error
 		return nil, errors.Wrap(err, "failed to set QoS")
 	}
 
-	runner, err := runner.New(cfg.Runner, f.hub.With(hub.Fields{"consumer": name}))
+	limiter, err := f.limiter(cfg.RateLimit)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the rate limit for consumer %s", name)
+	}
+	r, err := runner.New(cfg.Runner, f.hub.With(hub.Fields{"consumer": name}))
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed creating a runner")
 	}
@@ -126,19 +227,33 @@ func (f *Factory) newConsumer(name string, cfg ConsumerConfig) (*consumer, error
 			"consumer":    name,
 		},
 	})
-	return &consumer{
-		queue:       cfg.Queue.Name,
-		name:        name,
-		hash:        strconv.FormatInt(atomic.AddInt64(&f.number, 1), 10),
-		opts:        cfg.Options,
-		factoryName: f.Name(),
-		channel:     ch,
-		t:           tomb.Tomb{},
-		runner:      runner,
-		hub:         f.hub.With(hub.Fields{"consumer": name}),
-		workerPool:  make(pool, cfg.MaxWorkers),
-		timeout:     cfg.Runner.Timeout,
-	}, nil
+	c := &consumer{
+		queue:         cfg.Queue.Name,
+		name:          name,
+		hash:          strconv.FormatInt(atomic.AddInt64(&f.number, 1), 10),
+		opts:          cfg.Options,
+		retryCfg:      cfg.Retry,
+		restart:       cfg.Restart,
+		reply:         cfg.Reply,
+		output:        out,
+		publisher:     pub,
+		factoryName:   f.Name(),
+		channel:       ch,
+		t:             tomb.Tomb{},
+		runner:        r,
+		hub:           f.hub.With(hub.Fields{"consumer": name}),
+		workerPool:    pool.New(cfg.MaxWorkers),
+		limiter:       limiter,
+		prefetchCount: cfg.PrefetchCount,
+		batchSize:     cfg.BatchSize,
+		batchTimeout:  cfg.BatchTimeout,
+		control:       make(chan struct{}, 1),
+		timeout:       cfg.Runner.Timeout,
+	}
+	if breaker, ok := r.(runner.Breaker); ok {
+		breaker.Notify(c.circuitChanged)
+	}
+	return c, nil
 }
 
 func (f *Factory) declareExchange(ch *amqp.Channel, name string) error {
@@ -258,50 +373,54 @@ func (f *Factory) getChannel(connectionName string) (*amqp.Channel, error) {
 			strings.Join(available, ", "))
 	}
 
-	var ch *amqp.Channel
-	var errCH error
-	conn := f.conns[connectionName]
-	ch, errCH = conn.Channel()
-	// Reconnect the connection when receive an connection closed error
-	if errCH != nil && errCH.Error() == amqp.ErrClosed.Error() {
-		cfgConn := f.config.Connections[connectionName]
-		f.hub.Publish(hub.Message{
-			Name: "rabbit.reopening_connection.info",
-			Body: []byte("reopening one connection closed"),
-			Fields: hub.Fields{
-				"sleep":      cfgConn.Sleep,
-				"timeout":    cfgConn.Timeout,
-				"connection": connectionName,
-			},
-		})
-		conn, err := openConnection(cfgConn, f.config.Version)
-		if err != nil {
-			return nil, errors.Wrapf(err, "error reopening the connection \"%s\"", connectionName)
-		}
-		f.conns[connectionName] = conn
-		ch, errCH = conn.Channel()
+	ch, err := f.conns[connectionName].Channel()
+	// the closed connections are reopened in background
+	if err != nil && err.Error() == amqp.ErrClosed.Error() {
+		return nil, errors.Errorf("the connection \"%s\" is closed, waiting the reconnection", connectionName)
 	}
-	return ch, errCH
+	return ch, err
 }
 
-func openConnection(config Connection, version string) (*amqp.Connection, error) {
+// openConnection dial the nodes of one connection until one of them accept it.
+// The node chosen is published on the rabbit.opening_connection.info event.
+func openConnection(name string, nodes *nodes, config Connection, version string, h *hub.Hub) (*amqp.Connection, error) {
+	cfg, err := amqpConfig(config, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid tls config")
+	}
 	var conn *amqp.Connection
-	err := retry.Do(func() error {
+	err = retry.Do(func() error {
 		var err error
-		conn, err = amqp.DialConfig(config.DSN, amqp.Config{
-			Dial: func(network, addr string) (net.Conn, error) {
-				return net.DialTimeout(network, addr, config.Timeout)
-			},
-			Properties: amqp.Table{
-				"product": "message-cannon",
-				"version": version,
-			},
-		})
+		conn, err = dial(name, nodes, cfg, h)
 		return err
-	}, 5, config.Sleep)
+	}, max(config.Retries, nodes.len()), config.Sleep)
 	return conn, err
 }
 
+// dial try to open the connection with the next node.
+func dial(name string, nodes *nodes, cfg amqp.Config, h *hub.Hub) (*amqp.Connection, error) {
+	dsn := nodes.pick()
+	if cfg.TLSClientConfig != nil {
+		// the dial set the server name using the node host
+		cfg.TLSClientConfig = cfg.TLSClientConfig.Clone()
+	}
+	conn, err := amqp.DialConfig(dsn, cfg)
+	if err != nil {
+		h.Publish(hub.Message{
+			Name:   "rabbit.opening_connection.warning",
+			Body:   []byte("failed to open the connection with one node"),
+			Fields: hub.Fields{"connection": name, "host": dsnHost(dsn), "error": err},
+		})
+		return nil, err
+	}
+	h.Publish(hub.Message{
+		Name:   "rabbit.opening_connection.info",
+		Body:   []byte("connection opened"),
+		Fields: hub.Fields{"connection": name, "host": dsnHost(dsn)},
+	})
+	return conn, nil
+}
+
 func assertRightTableTypes(args amqp.Table) amqp.Table {
 	nArgs := amqp.Table{}
 	for k, v := range args {
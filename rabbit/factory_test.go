@@ -2,7 +2,9 @@ package rabbit
 
 import (
 	"testing"
+	"time"
 
+	"github.com/leandro-lugaresi/message-cannon/runner"
 	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/require"
 )
@@ -28,3 +30,17 @@ func Test_assertRightArgsTypes(t *testing.T) {
 		})
 	}
 }
+
+func TestFactory_newConsumer_invalidBatch(t *testing.T) {
+	f := &Factory{}
+	_, err := f.newConsumer("upload", ConsumerConfig{
+		PrefetchCount: 5,
+		BatchSize:     10,
+		BatchTimeout:  time.Second,
+		Reply:         true,
+		Runner:        runner.Config{Type: "http"},
+	})
+	require.EqualError(t, err, "invalid batch config: "+
+		"upload.prefetch_count: the prefetch_count (5) must be greater or equal than batch_size (10); "+
+		"upload.batch_size: the batches can't be used with reply or output")
+}
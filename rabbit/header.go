@@ -14,6 +14,13 @@ func getHeaders(msg amqp.Delivery) runner.Headers {
 		"Content-Encoding": msg.ContentEncoding,
 		"Correlation-Id":   msg.CorrelationId,
 		"Message-Id":       msg.MessageId,
+		"Reply-To":         msg.ReplyTo,
+	}
+	if !msg.Timestamp.IsZero() {
+		headers["Timestamp"] = msg.Timestamp
+	}
+	if msg.DeliveryMode > 0 {
+		headers["Delivery-Mode"] = int(msg.DeliveryMode)
 	}
 	for k, v := range msg.Headers {
 		switch vt := v.(type) {
@@ -21,15 +28,25 @@ func getHeaders(msg amqp.Delivery) runner.Headers {
 			headers[k] = vt
 		}
 	}
+	var deaths int64
 	xdeaths, ok := msg.Headers["x-death"].([]interface{})
 	if ok {
-		headers["Message-Deaths"] = processDeaths(xdeaths)
+		deaths = processDeaths(xdeaths)
+	}
+	// the delayed retries are also counted as deaths
+	retries := getRetryCount(msg)
+	if retries > 0 {
+		headers["Retry-Count"] = strconv.Itoa(retries)
+		deaths += int64(retries)
+	}
+	if ok || retries > 0 {
+		headers["Message-Deaths"] = strconv.FormatInt(deaths, 10)
 	}
 
 	return headers
 }
 
-func processDeaths(xdeaths []interface{}) string {
+func processDeaths(xdeaths []interface{}) int64 {
 	var (
 		count, deathCount int64
 	)
@@ -43,5 +60,40 @@ func processDeaths(xdeaths []interface{}) string {
 			deathCount += count
 		}
 	}
-	return strconv.FormatInt(deathCount, 10)
+	return deathCount
+}
+
+// Death is one entry of the x-death header, added by the broker every time the message is dead lettered.
+// The most recent death is the first one.
+type Death struct {
+	Queue       string
+	Reason      string
+	Exchange    string
+	RoutingKeys []string
+	Count       int64
+	Time        time.Time
+}
+
+func parseDeaths(xdeaths []interface{}) []Death {
+	deaths := []Death{}
+	for _, ideath := range xdeaths {
+		xdeath, ok := ideath.(amqp.Table)
+		if !ok {
+			continue
+		}
+		d := Death{}
+		d.Queue, _ = xdeath["queue"].(string)
+		d.Reason, _ = xdeath["reason"].(string)
+		d.Exchange, _ = xdeath["exchange"].(string)
+		d.Count, _ = xdeath["count"].(int64)
+		d.Time, _ = xdeath["time"].(time.Time)
+		keys, _ := xdeath["routing-keys"].([]interface{})
+		for _, k := range keys {
+			if key, ok := k.(string); ok {
+				d.RoutingKeys = append(d.RoutingKeys, key)
+			}
+		}
+		deaths = append(deaths, d)
+	}
+	return deaths
 }
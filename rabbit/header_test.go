@@ -2,6 +2,7 @@ package rabbit
 
 import (
 	"testing"
+	"time"
 
 	"github.com/leandro-lugaresi/message-cannon/runner"
 	"github.com/streadway/amqp"
@@ -22,6 +23,7 @@ func Test_getHeaders(t *testing.T) {
 				"Content-Type":     "",
 				"Correlation-Id":   "",
 				"Message-Id":       "",
+				"Reply-To":         "",
 			},
 		},
 		{
@@ -31,6 +33,9 @@ func Test_getHeaders(t *testing.T) {
 				ContentType:     "application/json",
 				CorrelationId:   "id-12334455",
 				MessageId:       "12345566",
+				ReplyTo:         "amq.rabbitmq.reply-to.g1h2",
+				Timestamp:       time.Date(2018, 2, 13, 17, 50, 26, 0, time.UTC),
+				DeliveryMode:    amqp.Persistent,
 				Body:            []byte(`foooo`),
 			},
 			runner.Headers{
@@ -38,6 +43,9 @@ func Test_getHeaders(t *testing.T) {
 				"Content-Type":     "application/json",
 				"Correlation-Id":   "id-12334455",
 				"Message-Id":       "12345566",
+				"Reply-To":         "amq.rabbitmq.reply-to.g1h2",
+				"Timestamp":        time.Date(2018, 2, 13, 17, 50, 26, 0, time.UTC),
+				"Delivery-Mode":    2,
 			},
 		},
 		{
@@ -53,6 +61,7 @@ func Test_getHeaders(t *testing.T) {
 				"Content-Type":     "",
 				"Correlation-Id":   "",
 				"Message-Id":       "",
+				"Reply-To":         "",
 			},
 		},
 		{
@@ -87,9 +96,41 @@ func Test_getHeaders(t *testing.T) {
 				"Content-Type":     "",
 				"Correlation-Id":   "",
 				"Message-Id":       "",
+				"Reply-To":         "",
 				"Message-Deaths":   "6",
 			},
 		},
+		{
+			"with delayed retries",
+			amqp.Delivery{
+				Body: []byte(`foooo`),
+				Headers: amqp.Table{
+					"x-retry-count": int64(2),
+					"x-death": []interface{}{
+						amqp.Table{
+							"count":    int64(2),
+							"exchange": "",
+							"queue":    "GenerateReport.retry.1s",
+							"reason":   "expired"},
+						amqp.Table{
+							"count":    int64(1),
+							"exchange": "fallback",
+							"queue":    "GenerateReport",
+							"reason":   "rejected"},
+					},
+				},
+			},
+			runner.Headers{
+				"Content-Encoding": "",
+				"Content-Type":     "",
+				"Correlation-Id":   "",
+				"Message-Id":       "",
+				"Reply-To":         "",
+				"x-retry-count":    int64(2),
+				"Retry-Count":      "2",
+				"Message-Deaths":   "3",
+			},
+		},
 		{
 			"with custom headers",
 			amqp.Delivery{
@@ -104,6 +145,7 @@ func Test_getHeaders(t *testing.T) {
 				"Content-Type":     "",
 				"Correlation-Id":   "",
 				"Message-Id":       "",
+				"Reply-To":         "",
 				"Authorization":    "Basic YWxhZGRpbjpvcGVuc2VzYW1l",
 				"X-Forwarded-For":  "203.0.113.195, 70.41.3.18, 150.172.238.178",
 			},
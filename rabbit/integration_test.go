@@ -38,6 +38,22 @@ func TestIntegrationSuite(t *testing.T) {
 			scenario: "validate the behavior of one healthy consumer",
 			method:   testConsumerProcess,
 		},
+		{
+			scenario: "validate the delayed retries",
+			method:   testConsumerDelayedRetry,
+		},
+		{
+			scenario: "validate the replies to the reply-to queue",
+			method:   testConsumerReply,
+		},
+		{
+			scenario: "validate the output published to another queue",
+			method:   testConsumerOutput,
+		},
+		{
+			scenario: "validate the publisher confirms",
+			method:   testPublisher,
+		},
 		{
 			scenario: "validate that all the consumers will restart without problems",
 			method:   testConsumerReconnect,
@@ -145,6 +161,144 @@ func testConsumerProcess(t *testing.T, resource *dockertest.Resource) {
 	}
 }
 
+func testConsumerDelayedRetry(t *testing.T, resource *dockertest.Resource) {
+	config := getConfig(t, "valid_queue_and_exchange_config.yml")
+	config.Connections["default"] = setDSN(resource, config.Connections["default"])
+	cfg := config.Consumers["test1"]
+	cfg.Retry = &RetryConfig{Delay: 100 * time.Millisecond, Multiplier: 2, MaxDelay: time.Second, MaxRetries: 2}
+	config.Consumers["test1"] = cfg
+	h := hub.New()
+	retrySubscriber := h.Subscribe(10, "rabbit.retry.*")
+	factory, err := NewFactory(config, h)
+	require.NoError(t, err, "Failed to create the factory")
+	cons, err := factory.CreateConsumer("test1")
+	require.NoError(t, err, "Failed to create the consumer")
+	mock := &mockRunner{count: 0, exitStatus: runner.ExitRetry}
+	cons.(*consumer).runner = mock
+	cons.Run()
+	sendMessages(t, resource, "upload-picture", "android.profile.upload", 1, 1)
+	select {
+	case msg := <-retrySubscriber.Receiver:
+		require.Equal(t, "rabbit.retry.warning", msg.Name)
+	case <-time.After(3 * time.Second):
+		t.Fatal("the message should be rejected after the max retries")
+	}
+	assert.EqualValues(t, 3, mock.messagesProcessed())
+	cons.Kill()
+	ch, err := factory.conns["default"].Channel()
+	require.NoError(t, err, "Error opening a channel")
+	fallback, err := ch.QueueInspect("fallback")
+	require.NoError(t, err)
+	assert.Equal(t, 1, fallback.Messages, "the message should be in the dead letter")
+	for _, delay := range cfg.Retry.delays() {
+		_, err := ch.QueueDelete(retryQueueName(cfg.Queue.Name, delay), false, false, false)
+		require.NoError(t, err)
+	}
+	for _, cfg := range factory.config.Consumers {
+		_, err := ch.QueueDelete(cfg.Queue.Name, false, false, false)
+		require.NoError(t, err)
+	}
+	_, err = ch.QueueDelete("fallback", false, false, false)
+	require.NoError(t, err)
+}
+
+func testConsumerReply(t *testing.T, resource *dockertest.Resource) {
+	config := getConfig(t, "valid_queue_and_exchange_config.yml")
+	config.Connections["default"] = setDSN(resource, config.Connections["default"])
+	cfg := config.Consumers["test1"]
+	cfg.Reply = true
+	config.Consumers["test1"] = cfg
+	factory, err := NewFactory(config, hub.New())
+	require.NoError(t, err, "Failed to create the factory")
+	cons, err := factory.CreateConsumer("test1")
+	require.NoError(t, err, "Failed to create the consumer")
+	cons.(*consumer).runner = &outputRunner{output: []byte(`{"result": 42}`)}
+	cons.Run()
+	defer cons.Kill()
+	ch, err := factory.conns["default"].Channel()
+	require.NoError(t, err, "Error opening a channel")
+	replies, err := ch.QueueDeclare("", false, true, true, false, nil)
+	require.NoError(t, err)
+	err = ch.Publish("upload-picture", "android.profile.upload", false, false, amqp.Publishing{
+		ReplyTo:       replies.Name,
+		CorrelationId: "req-1",
+		Body:          []byte(`{"fooo": "bazzz"}`),
+	})
+	require.NoError(t, err, "error publishing to rabbitMQ")
+	var reply amqp.Delivery
+	require.Eventually(t, func() bool {
+		var ok bool
+		reply, ok, err = ch.Get(replies.Name, true)
+		return err == nil && ok
+	}, 3*time.Second, 50*time.Millisecond, "the reply should be published")
+	assert.Equal(t, "req-1", reply.CorrelationId)
+	assert.Equal(t, `{"result": 42}`, string(reply.Body))
+	_, err = ch.QueueDelete(cfg.Queue.Name, false, false, false)
+	require.NoError(t, err)
+}
+
+func testConsumerOutput(t *testing.T, resource *dockertest.Resource) {
+	config := getConfig(t, "valid_queue_and_exchange_config.yml")
+	config.Connections["default"] = setDSN(resource, config.Connections["default"])
+	cfg := config.Consumers["test1"]
+	cfg.Output = OutputConfig{RoutingKey: "{{ .RoutingKey }}.output", ContentType: "application/json"}
+	config.Consumers["test1"] = cfg
+	factory, err := NewFactory(config, hub.New())
+	require.NoError(t, err, "Failed to create the factory")
+	ch, err := factory.conns["default"].Channel()
+	require.NoError(t, err, "Error opening a channel")
+	_, err = ch.QueueDeclare("android.profile.upload.output", false, false, false, false, nil)
+	require.NoError(t, err)
+	cons, err := factory.CreateConsumer("test1")
+	require.NoError(t, err, "Failed to create the consumer")
+	cons.(*consumer).runner = &outputRunner{output: []byte(`{"result": 42}`)}
+	cons.Run()
+	defer cons.Kill()
+	sendMessages(t, resource, "upload-picture", "android.profile.upload", 1, 1)
+	var out amqp.Delivery
+	require.Eventually(t, func() bool {
+		var ok bool
+		out, ok, err = ch.Get("android.profile.upload.output", true)
+		return err == nil && ok
+	}, 3*time.Second, 50*time.Millisecond, "the output should be published")
+	assert.Equal(t, "application/json", out.ContentType)
+	assert.Equal(t, `{"result": 42}`, string(out.Body))
+	for _, name := range []string{cfg.Queue.Name, "android.profile.upload.output"} {
+		_, err = ch.QueueDelete(name, false, false, false)
+		require.NoError(t, err)
+	}
+}
+
+func testPublisher(t *testing.T, resource *dockertest.Resource) {
+	config := getConfig(t, "valid_queue_and_exchange_config.yml")
+	config.Connections["default"] = setDSN(resource, config.Connections["default"])
+	factory, err := NewFactory(config, hub.New())
+	require.NoError(t, err, "Failed to create the factory")
+	_, err = factory.CreateConsumer("test1")
+	require.NoError(t, err, "Failed to declare the queue")
+	p, err := NewPublisher(config, PublisherOptions{
+		Connection: "default",
+		Exchange:   "upload-picture",
+		Confirm:    true,
+		Mandatory:  true,
+	}, hub.New())
+	require.NoError(t, err, "Failed to create the publisher")
+	defer p.Close()
+	err = p.Publish("android.profile.upload", amqp.Publishing{Body: []byte(`{"fooo": "bazzz"}`)})
+	require.NoError(t, err, "the message should be accepted")
+	err = p.Publish("unknown.key", amqp.Publishing{Body: []byte(`{"fooo": "bazzz"}`)})
+	require.EqualError(t, err, "the broker returned the message: NO_ROUTE")
+	ch, err := factory.conns["default"].Channel()
+	require.NoError(t, err, "Error opening a channel")
+	q, err := ch.QueueInspect(config.Consumers["test1"].Queue.Name)
+	require.NoError(t, err)
+	assert.Equal(t, 1, q.Messages)
+	for _, cfg := range factory.config.Consumers {
+		_, err := ch.QueueDelete(cfg.Queue.Name, false, false, false)
+		require.NoError(t, err)
+	}
+}
+
 func testConsumerReconnect(t *testing.T, resource *dockertest.Resource) {
 	config := getConfig(t, "valid_queue_and_exchange_config.yml")
 	config.Connections["default"] = setDSN(resource, config.Connections["default"])
@@ -238,3 +392,15 @@ func (m *mockRunner) Process(_ context.Context, _ runner.Message) (int, error) {
 func (m *mockRunner) messagesProcessed() int64 {
 	return atomic.LoadInt64(&m.count)
 }
+
+type outputRunner struct {
+	output []byte
+}
+
+func (r *outputRunner) Process(_ context.Context, _ runner.Message) (int, error) {
+	return runner.ExitACK, nil
+}
+
+func (r *outputRunner) ProcessOutput(_ context.Context, _ runner.Message) (int, []byte, error) {
+	return runner.ExitACK, r.output, nil
+}
@@ -0,0 +1,77 @@
+package rabbit
+
+import (
+	"math/rand"
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// nodes select the node dialed by one connection.
+// The next dial, including the reconnections, starts from the node after the last one used.
+// With shuffle the order is shuffled on every round.
+type nodes struct {
+	mu      sync.Mutex
+	dsns    []string
+	shuffle bool
+	next    int
+}
+
+func newNodes(cfg Connection) (*nodes, error) {
+	dsns, err := cfg.dsns()
+	if err != nil {
+		return nil, err
+	}
+	return &nodes{dsns: dsns, shuffle: cfg.HostSelection == "shuffle"}, nil
+}
+
+// dsns return the DSN of every node.
+func (c Connection) dsns() ([]string, error) {
+	if len(c.DSNs) > 0 {
+		// copied because the shuffle changes the order
+		return append([]string(nil), c.DSNs...), nil
+	}
+	if len(c.Hosts) == 0 {
+		return []string{c.DSN}, nil
+	}
+	u, err := url.Parse(c.DSN)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid dsn")
+	}
+	dsns := make([]string, len(c.Hosts))
+	for i, host := range c.Hosts {
+		node := *u
+		node.Host = host
+		dsns[i] = node.String()
+	}
+	return dsns, nil
+}
+
+// pick return the DSN used by the next dial.
+func (n *nodes) pick() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.shuffle && n.next == 0 {
+		rand.Shuffle(len(n.dsns), func(i, j int) {
+			n.dsns[i], n.dsns[j] = n.dsns[j], n.dsns[i]
+		})
+	}
+	dsn := n.dsns[n.next]
+	n.next = (n.next + 1) % len(n.dsns)
+	return dsn
+}
+
+// len return the number of nodes.
+func (n *nodes) len() int {
+	return len(n.dsns)
+}
+
+// dsnHost return the host of the DSN without the user and password, used on the events.
+func dsnHost(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
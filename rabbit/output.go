@@ -0,0 +1,92 @@
+package rabbit
+
+import (
+	"bytes"
+	"text/template"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+// output publish the runner output of the messages acked to another exchange.
+type output struct {
+	exchange     string
+	routingKey   *template.Template
+	contentType  string
+	deliveryMode uint8
+}
+
+// outputData is used to execute the routing key template.
+type outputData struct {
+	Exchange   string
+	RoutingKey string
+	Headers    runner.Headers
+}
+
+func (o OutputConfig) enabled() bool {
+	return len(o.Exchange) > 0 || len(o.RoutingKey) > 0
+}
+
+func newOutput(cfg OutputConfig) (*output, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+	tmpl, err := template.New("routing_key").Option("missingkey=error").Parse(cfg.RoutingKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid output routing_key")
+	}
+	o := &output{
+		exchange:     cfg.Exchange,
+		routingKey:   tmpl,
+		contentType:  cfg.ContentType,
+		deliveryMode: amqp.Transient,
+	}
+	if cfg.Persistent {
+		o.deliveryMode = amqp.Persistent
+	}
+	return o, nil
+}
+
+// key execute the routing key template with the incoming message.
+func (o *output) key(msg amqp.Delivery) (string, error) {
+	var b bytes.Buffer
+	err := o.routingKey.Execute(&b, outputData{
+		Exchange:   msg.Exchange,
+		RoutingKey: msg.RoutingKey,
+		Headers:    getHeaders(msg),
+	})
+	return b.String(), errors.Wrap(err, "failed to execute the output routing_key")
+}
+
+// sendOutput publish the runner output, the original message is only acked after the broker confirms it.
+// Messages with an invalid routing key are rejected to the dead letter, they would fail again if requeued.
+func (c *consumer) sendOutput(msg amqp.Delivery, body []byte) (requeue bool, err error) {
+	key, err := c.output.key(msg)
+	if err != nil {
+		c.hub.Publish(hub.Message{
+			Name:   "rabbit.output.error",
+			Body:   []byte("failed to build the output routing key. Message will be rejected."),
+			Fields: hub.Fields{"error": err},
+		})
+		return false, err
+	}
+	err = c.publisher.Publish(c.output.exchange, key, amqp.Publishing{
+		ContentType:   c.output.contentType,
+		DeliveryMode:  c.output.deliveryMode,
+		CorrelationId: msg.CorrelationId,
+		Timestamp:     time.Now(),
+		Body:          body,
+	})
+	if err != nil {
+		c.hub.Publish(hub.Message{
+			Name:   "rabbit.output.error",
+			Body:   []byte("failed to publish the output. Message will be requeued."),
+			Fields: hub.Fields{"error": err, "exchange": c.output.exchange, "routing-key": key},
+		})
+		return true, err
+	}
+	return false, nil
+}
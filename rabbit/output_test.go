@@ -0,0 +1,46 @@
+package rabbit
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_output_key(t *testing.T) {
+	msg := amqp.Delivery{
+		Exchange:   "upload-picture",
+		RoutingKey: "android.profile.upload",
+		Headers:    amqp.Table{"x-tenant": "acme"},
+	}
+	tests := []struct {
+		name       string
+		routingKey string
+		want       string
+		err        string
+	}{
+		{"static key", "pictures.resized", "pictures.resized", ""},
+		{"key from the headers", `pictures.{{ index .Headers "x-tenant" }}.resized`, "pictures.acme.resized", ""},
+		{"key from the incoming routing key", "{{ .RoutingKey }}.resized", "android.profile.upload.resized", ""},
+		{"missing header", "pictures.{{ .Headers.tenant }}", "", `failed to execute the output routing_key: template: routing_key:1:20: executing "routing_key" at <.Headers.tenant>: map has no entry for key "tenant"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := newOutput(OutputConfig{Exchange: "results", RoutingKey: tt.routingKey})
+			require.NoError(t, err)
+			key, err := o.key(msg)
+			if len(tt.err) > 0 {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, key)
+		})
+	}
+	t.Run("disabled without exchange and routing key", func(t *testing.T) {
+		o, err := newOutput(OutputConfig{ContentType: "application/json"})
+		assert.NoError(t, err)
+		assert.Nil(t, o)
+	})
+}
@@ -0,0 +1,151 @@
+package rabbit
+
+import (
+	"sync"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+// publisher send messages using a channel in confirm mode.
+// Publish only returns after the broker confirms the message, so we can safely ack the original one.
+type publisher struct {
+	mu       sync.Mutex
+	channel  *amqp.Channel
+	confirms chan amqp.Confirmation
+	// returns receive the unroutable messages when publishing as mandatory.
+	returns chan amqp.Return
+	tag     uint64
+}
+
+func newPublisher(ch *amqp.Channel) (*publisher, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, errors.Wrap(err, "failed to put the channel in confirm mode")
+	}
+	return &publisher{
+		channel:  ch,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+	}, nil
+}
+
+// mandatory publish the next messages as mandatory, the unroutable messages are returned as errors.
+func (p *publisher) mandatory() {
+	p.returns = p.channel.NotifyReturn(make(chan amqp.Return, 1))
+}
+
+// Publish send one message and wait for the broker confirmation.
+func (p *publisher) Publish(exchange, key string, msg amqp.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.channel.Publish(exchange, key, p.returns != nil, false, msg); err != nil {
+		return errors.Wrap(err, "failed to publish the message")
+	}
+	p.tag++
+	for {
+		confirm, ok := <-p.confirms
+		if !ok {
+			return errors.New("the channel was closed before the publish confirmation")
+		}
+		// confirmations of old messages could arrive, we only care about the last one
+		if confirm.DeliveryTag < p.tag {
+			continue
+		}
+		if !confirm.Ack {
+			return errors.New("the broker refused the message")
+		}
+		// the broker send the return before the confirmation
+		select {
+		case r := <-p.returns:
+			return errors.Errorf("the broker returned the message: %s", r.ReplyText)
+		default:
+		}
+		return nil
+	}
+}
+
+// Close the channel used by the publisher.
+func (p *publisher) Close() error {
+	return p.channel.Close()
+}
+
+// PublisherOptions are used to create a Publisher.
+type PublisherOptions struct {
+	// Connection is the name of the connection in the config.
+	Connection string
+	// Exchange receive the messages, it's declared when present in the config.
+	Exchange string
+	// Confirm wait the broker confirmation of every message.
+	Confirm bool
+	// Mandatory return an error when the message can't be routed to one queue, requires Confirm.
+	Mandatory bool
+}
+
+// Publisher send messages using the connections and exchanges from the config, used to test the consumers.
+type Publisher struct {
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	confirms *publisher
+	exchange string
+}
+
+// NewPublisher open the connection and declare the exchange.
+func NewPublisher(config Config, opts PublisherOptions, h *hub.Hub) (*Publisher, error) {
+	if err := setConfigDefaults(&config); err != nil {
+		return nil, errors.Wrap(err, "failed to set default values for configs")
+	}
+	cfgConn, ok := config.Connections[opts.Connection]
+	if !ok {
+		return nil, errors.Errorf("connection (%s) did not exist", opts.Connection)
+	}
+	if opts.Mandatory && !opts.Confirm {
+		return nil, errors.New("the mandatory messages requires the publisher confirms")
+	}
+	n, err := newNodes(cfgConn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening the connection \"%s\"", opts.Connection)
+	}
+	conn, err := openConnection(opts.Connection, n, cfgConn, config.Version, h)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening the connection \"%s\"", opts.Connection)
+	}
+	p := &Publisher{conn: conn, exchange: opts.Exchange}
+	p.channel, err = conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to open the rabbitMQ channel")
+	}
+	f := &Factory{config: config, hub: h}
+	if err = f.declareExchange(p.channel, opts.Exchange); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if opts.Confirm {
+		p.confirms, err = newPublisher(p.channel)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if opts.Mandatory {
+			p.confirms.mandatory()
+		}
+	}
+	return p, nil
+}
+
+// Publish send one message, with confirms it only returns after the broker accepted the message.
+func (p *Publisher) Publish(key string, msg amqp.Publishing) error {
+	if p.confirms != nil {
+		return p.confirms.Publish(p.exchange, key, msg)
+	}
+	return errors.Wrap(p.channel.Publish(p.exchange, key, false, false, msg), "failed to publish the message")
+}
+
+// Close the channel and the connection.
+func (p *Publisher) Close() error {
+	if err := p.channel.Close(); err != nil {
+		p.conn.Close()
+		return err
+	}
+	return p.conn.Close()
+}
@@ -0,0 +1,64 @@
+package rabbit
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+func (r RateLimiter) limit() (rate.Limit, int) {
+	burst := r.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.Limit(r.Rate), burst
+}
+
+// newLimiters create the token buckets shared by the consumers.
+func newLimiters(cfgs map[string]RateLimiter) map[string]*rate.Limiter {
+	limiters := make(map[string]*rate.Limiter, len(cfgs))
+	for name, cfg := range cfgs {
+		limiters[name] = rate.NewLimiter(cfg.limit())
+	}
+	return limiters
+}
+
+// reloadLimiters keep the running limiters updating their limits, the consumers not recreated keep sharing them.
+func reloadLimiters(running, limiters map[string]*rate.Limiter) {
+	for name, l := range limiters {
+		old, exist := running[name]
+		if !exist {
+			continue
+		}
+		old.SetLimit(l.Limit())
+		old.SetBurst(l.Burst())
+		limiters[name] = old
+	}
+}
+
+// limiter return the limiter used by one consumer or nil when the consumer has no limit.
+func (f *Factory) limiter(cfg RateLimitConfig) (*rate.Limiter, error) {
+	if len(cfg.Limiter) > 0 {
+		l, ok := f.limiters[cfg.Limiter]
+		if !ok {
+			return nil, errors.Errorf("rate limiter \"%s\" did not exist", cfg.Limiter)
+		}
+		return l, nil
+	}
+	if cfg.Rate <= 0 {
+		return nil, nil
+	}
+	return rate.NewLimiter(RateLimiter{Rate: cfg.Rate, Burst: cfg.Burst}.limit()), nil
+}
+
+// throttle wait the rate limit and return the time waited.
+func (c *consumer) throttle(ctx context.Context) (time.Duration, error) {
+	if c.limiter == nil {
+		return 0, nil
+	}
+	start := time.Now()
+	err := c.limiter.Wait(ctx)
+	return time.Since(start), err
+}
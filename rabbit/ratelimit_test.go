@@ -0,0 +1,64 @@
+package rabbit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestFactory_limiter(t *testing.T) {
+	f := &Factory{limiters: newLimiters(map[string]RateLimiter{"payments": {Rate: 10}})}
+
+	l, err := f.limiter(RateLimitConfig{})
+	require.NoError(t, err)
+	require.Nil(t, l, "the consumers without rate don't have a limiter")
+
+	l, err = f.limiter(RateLimitConfig{Rate: 2, Burst: 3})
+	require.NoError(t, err)
+	require.Equal(t, rate.Limit(2), l.Limit())
+	require.Equal(t, 3, l.Burst())
+
+	shared, err := f.limiter(RateLimitConfig{Limiter: "payments"})
+	require.NoError(t, err)
+	other, err := f.limiter(RateLimitConfig{Limiter: "payments"})
+	require.NoError(t, err)
+	require.True(t, shared == other, "the consumers must share the named limiter")
+	require.Equal(t, 1, shared.Burst())
+
+	_, err = f.limiter(RateLimitConfig{Limiter: "missing"})
+	require.EqualError(t, err, `rate limiter "missing" did not exist`)
+}
+
+func Test_reloadLimiters(t *testing.T) {
+	running := newLimiters(map[string]RateLimiter{"payments": {Rate: 10}, "search": {Rate: 1}})
+	limiters := newLimiters(map[string]RateLimiter{"payments": {Rate: 20, Burst: 5}, "maps": {Rate: 3}})
+	reloadLimiters(running, limiters)
+
+	require.True(t, running["payments"] == limiters["payments"], "the running limiter must be kept")
+	require.Equal(t, rate.Limit(20), limiters["payments"].Limit())
+	require.Equal(t, 5, limiters["payments"].Burst())
+	require.NotContains(t, limiters, "search")
+	require.Contains(t, limiters, "maps")
+}
+
+func Test_consumer_throttle(t *testing.T) {
+	c := &consumer{}
+	throttled, err := c.throttle(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, throttled)
+
+	c.limiter = rate.NewLimiter(20, 1)
+	_, err = c.throttle(context.Background())
+	require.NoError(t, err)
+	throttled, err = c.throttle(context.Background())
+	require.NoError(t, err)
+	require.True(t, throttled >= 30*time.Millisecond, "the second message should wait the token, waited %s", throttled)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.throttle(ctx)
+	require.Error(t, err)
+}
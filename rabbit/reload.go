@@ -0,0 +1,96 @@
+package rabbit
+
+import (
+	"reflect"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+// Reload take the config and the connections from a new factory.
+// The connections with the same config are kept, so the consumers not changed keep running.
+// The connections replaced are closed by Release, after the old consumers were killed.
+func (f *Factory) Reload(nf supervisor.Factory) ([]string, []string, error) {
+	n, ok := nf.(*Factory)
+	if !ok {
+		return nil, nil, errors.Errorf("can't reload the rabbitMQ factory using a %T", nf)
+	}
+	changedConns := map[string]bool{}
+	for name, conn := range n.conns {
+		old, exist := f.conns[name]
+		if exist && reflect.DeepEqual(f.config.Connections[name], n.config.Connections[name]) {
+			// keep the running connection and drop the one opened by the new factory
+			_ = conn.Close()
+			n.conns[name] = old
+			continue
+		}
+		conn.notify(f.recovered)
+		changedConns[name] = true
+	}
+	for name, conn := range f.conns {
+		if n.conns[name] != conn {
+			f.retired = append(f.retired, conn)
+		}
+	}
+	// exchanges and dead letters are declared by the consumers, all of them are recreated.
+	declarations := !reflect.DeepEqual(f.config.Exchanges, n.config.Exchanges) ||
+		!reflect.DeepEqual(f.config.DeadLetters, n.config.DeadLetters)
+	changed := []string{}
+	removed := []string{}
+	for name, cfg := range f.config.Consumers {
+		ncfg, exist := n.config.Consumers[name]
+		switch {
+		case !exist:
+			removed = append(removed, name)
+		case declarations || changedConns[ncfg.Connection] || !reflect.DeepEqual(cfg, ncfg):
+			changed = append(changed, name)
+		}
+	}
+	for name := range n.config.Consumers {
+		if _, exist := f.config.Consumers[name]; !exist {
+			changed = append(changed, name)
+		}
+	}
+	reloadLimiters(f.limiters, n.limiters)
+	f.config = n.config
+	f.conns = n.conns
+	f.limiters = n.limiters
+	return changed, removed, nil
+}
+
+// Release close the connections replaced by the last Reload.
+func (f *Factory) Release() {
+	for _, conn := range f.retired {
+		err := conn.Close()
+		if err != nil && err != amqp.ErrClosed {
+			f.hub.Publish(hub.Message{
+				Name:   "rabbit.connection.error",
+				Body:   []byte("Error closing one connection replaced by the reload"),
+				Fields: hub.Fields{"error": err},
+			})
+		}
+	}
+	f.retired = nil
+}
+
+// Close all the connections opened by this factory.
+func (f *Factory) Close() error {
+	var err error
+	for _, conn := range append(f.retired, connections(f.conns)...) {
+		if cerr := conn.Close(); cerr != nil && cerr != amqp.ErrClosed {
+			err = cerr
+		}
+	}
+	f.retired = nil
+	return err
+}
+
+func connections(conns map[string]*connection) []*connection {
+	list := make([]*connection, 0, len(conns))
+	for _, conn := range conns {
+		list = append(list, conn)
+	}
+	return list
+}
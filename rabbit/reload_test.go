@@ -0,0 +1,62 @@
+package rabbit
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/leandro-lugaresi/message-cannon/supervisor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFactory_Reload(t *testing.T) {
+	newFactory := func(consumers map[string]ConsumerConfig, exchanges map[string]ExchangeConfig) *Factory {
+		return &Factory{
+			config: Config{Consumers: consumers, Exchanges: exchanges},
+			conns:  map[string]*connection{},
+			hub:    hub.New(),
+		}
+	}
+	consumer := func(url string) ConsumerConfig {
+		return ConsumerConfig{
+			Connection: "default",
+			Queue:      QueueConfig{Name: "upload"},
+			Runner:     runner.Config{Type: "http", Options: runner.Options{URL: url}},
+		}
+	}
+	exchanges := map[string]ExchangeConfig{"upload": {Type: "topic"}}
+	running := newFactory(map[string]ConsumerConfig{
+		"upload": consumer("http://localhost/upload"),
+		"resize": consumer("http://localhost/resize"),
+		"delete": consumer("http://localhost/delete"),
+	}, exchanges)
+
+	changed, removed, err := running.Reload(newFactory(map[string]ConsumerConfig{
+		"upload": consumer("http://localhost/upload"),
+		"resize": consumer("http://localhost/v2/resize"),
+		"crop":   consumer("http://localhost/crop"),
+	}, exchanges))
+	require.NoError(t, err)
+	sort.Strings(changed)
+	require.Equal(t, []string{"crop", "resize"}, changed)
+	require.Equal(t, []string{"delete"}, removed)
+	require.Equal(t, "http://localhost/v2/resize", running.config.Consumers["resize"].Runner.Options.URL)
+
+	// changing the exchanges recreate all the consumers
+	changed, removed, err = running.Reload(newFactory(running.config.Consumers,
+		map[string]ExchangeConfig{"upload": {Type: "direct"}}))
+	require.NoError(t, err)
+	sort.Strings(changed)
+	require.Equal(t, []string{"crop", "resize", "upload"}, changed)
+	require.Empty(t, removed)
+
+	_, _, err = running.Reload(&otherFactory{})
+	require.EqualError(t, err, "can't reload the rabbitMQ factory using a *rabbit.otherFactory")
+}
+
+type otherFactory struct{}
+
+func (f *otherFactory) CreateConsumers() ([]supervisor.Consumer, error)      { return nil, nil }
+func (f *otherFactory) CreateConsumer(_ string) (supervisor.Consumer, error) { return nil, nil }
+func (f *otherFactory) Name() string                                         { return "other" }
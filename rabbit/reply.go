@@ -0,0 +1,30 @@
+package rabbit
+
+import (
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/streadway/amqp"
+)
+
+func (c *consumer) shouldReply(msg amqp.Delivery) bool {
+	return c.reply && len(msg.ReplyTo) > 0 && c.publisher != nil
+}
+
+// sendReply publish the runner output to the ReplyTo queue using the default exchange.
+// The original message is only acked after the broker confirms the reply.
+func (c *consumer) sendReply(msg amqp.Delivery, output []byte) error {
+	err := c.publisher.Publish("", msg.ReplyTo, amqp.Publishing{
+		CorrelationId: msg.CorrelationId,
+		Timestamp:     time.Now(),
+		Body:          output,
+	})
+	if err != nil {
+		c.hub.Publish(hub.Message{
+			Name:   "rabbit.reply.error",
+			Body:   []byte("failed to publish the reply. Message will be requeued."),
+			Fields: hub.Fields{"error": err, "reply-to": msg.ReplyTo, "correlation-id": msg.CorrelationId},
+		})
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package rabbit
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/streadway/amqp"
+)
+
+const retryCountHeader = "x-retry-count"
+
+// delay return the time a message waits before the retry number n (starting from 0).
+func (r RetryConfig) delay(n int) time.Duration {
+	d := float64(r.Delay) * math.Pow(r.Multiplier, float64(n))
+	if d > float64(r.MaxDelay) {
+		return r.MaxDelay
+	}
+	return time.Duration(d)
+}
+
+// delays return all the distinct delays used until the MaxRetries.
+func (r RetryConfig) delays() []time.Duration {
+	delays := []time.Duration{}
+	for n := 0; n < r.MaxRetries; n++ {
+		d := r.delay(n)
+		if len(delays) > 0 && delays[len(delays)-1] == d {
+			continue
+		}
+		delays = append(delays, d)
+	}
+	return delays
+}
+
+func retryQueueName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%s", queue, delay)
+}
+
+// declareRetryQueues create one queue for every delay.
+// The messages expire on these queues and go back to the consumer queue using the default exchange.
+func (f *Factory) declareRetryQueues(ch *amqp.Channel, cfg ConsumerConfig) error {
+	for _, delay := range cfg.Retry.delays() {
+		err := f.declareQueue(ch, QueueConfig{
+			Name: retryQueueName(cfg.Queue.Name, delay),
+			Options: Options{
+				Durable: cfg.Queue.Options.Durable,
+				Args: amqp.Table{
+					"x-message-ttl":             delay.Nanoseconds() / int64(time.Millisecond),
+					"x-dead-letter-exchange":    "",
+					"x-dead-letter-routing-key": cfg.Queue.Name,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retry send the message to the retry queue of the next delay and ack the original message.
+// After the MaxRetries the message is rejected and goes to the dead letter configured on the queue.
+// Without the retry config the message is requeued.
+func (c *consumer) retry(msg amqp.Delivery) error {
+	// server named queues didn't have retry queues
+	if c.retryCfg == nil || c.publisher == nil || len(c.queue) == 0 {
+		return msg.Nack(false, true)
+	}
+	count := getRetryCount(msg)
+	if count >= c.retryCfg.MaxRetries {
+		c.hub.Publish(hub.Message{
+			Name:   "rabbit.retry.warning",
+			Body:   []byte("max retries reached, rejecting the message to the dead letter"),
+			Fields: hub.Fields{"retries": count, "queue": c.queue},
+		})
+		return msg.Nack(false, false)
+	}
+	delay := c.retryCfg.delay(count)
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[retryCountHeader] = int64(count + 1)
+	err := c.publisher.Publish("", retryQueueName(c.queue, delay), amqp.Publishing{
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		Body:            msg.Body,
+	})
+	if err != nil {
+		c.hub.Publish(hub.Message{
+			Name:   "rabbit.retry.error",
+			Body:   []byte("failed to publish the message to the retry queue. Message will be requeued."),
+			Fields: hub.Fields{"error": err, "delay": delay},
+		})
+		return msg.Nack(false, true)
+	}
+	return msg.Ack(false)
+}
+
+// getRetryCount return how many times the message was sent to the retry queues.
+func getRetryCount(msg amqp.Delivery) int {
+	switch v := msg.Headers[retryCountHeader].(type) {
+	case int:
+		return v
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,43 @@
+package rabbit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryConfig_delay(t *testing.T) {
+	r := RetryConfig{Delay: time.Second, Multiplier: 2, MaxDelay: 5 * time.Second, MaxRetries: 6}
+	require.Equal(t, time.Second, r.delay(0))
+	require.Equal(t, 2*time.Second, r.delay(1))
+	require.Equal(t, 4*time.Second, r.delay(2))
+	require.Equal(t, 5*time.Second, r.delay(3))
+	require.Equal(t, 5*time.Second, r.delay(100))
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}, r.delays())
+}
+
+func Test_retryQueueName(t *testing.T) {
+	require.Equal(t, "upload-picture.retry.1m30s", retryQueueName("upload-picture", 90*time.Second))
+	require.Equal(t, "upload-picture.retry.500ms", retryQueueName("upload-picture", 500*time.Millisecond))
+}
+
+func Test_getRetryCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers amqp.Table
+		want    int
+	}{
+		{"without headers", nil, 0},
+		{"with int64", amqp.Table{retryCountHeader: int64(3)}, 3},
+		{"with int32", amqp.Table{retryCountHeader: int32(2)}, 2},
+		{"with invalid type", amqp.Table{retryCountHeader: "3"}, 0},
+	}
+	for _, tt := range tests {
+		ctt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, ctt.want, getRetryCount(amqp.Delivery{Headers: ctt.headers}))
+		})
+	}
+}
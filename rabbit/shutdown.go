@@ -0,0 +1,71 @@
+package rabbit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+)
+
+// cancelGrace is how long the messages canceled by the kill timeout have to finish.
+const cancelGrace = time.Second
+
+// drain cancel the consume, so no new deliveries arrive, and wait the messages in flight.
+// After the kill timeout the contexts of the messages in flight are canceled and they are requeued.
+func (c *consumer) drain(tag string, consuming bool, cancel context.CancelFunc) {
+	if consuming {
+		if err := c.channel.Cancel(tag, false); err != nil {
+			c.hub.Publish(hub.Message{
+				Name:   "rabbit.consumer.error",
+				Body:   []byte("Failed to cancel the consume"),
+				Fields: hub.Fields{"error": err},
+			})
+		}
+	}
+	timeout := time.Duration(atomic.LoadInt64(&c.killTimeout))
+	if timeout <= 0 {
+		c.workerPool.Wait()
+		return
+	}
+	if c.waitWorkers(timeout) {
+		return
+	}
+	c.hub.Publish(hub.Message{
+		Name:   "rabbit.consumer.warning",
+		Body:   []byte("the kill timeout was reached, canceling the messages in flight"),
+		Fields: hub.Fields{"in-flight": c.workerPool.InFlight(), "timeout": timeout},
+	})
+	cancel()
+	if !c.waitWorkers(cancelGrace) {
+		c.hub.Publish(hub.Message{
+			Name:   "rabbit.consumer.warning",
+			Body:   []byte("the messages in flight didn't stop after canceled, they are requeued when the channel is closed"),
+			Fields: hub.Fields{"in-flight": c.workerPool.InFlight()},
+		})
+	}
+}
+
+// waitWorkers return false if the workers are still running after the timeout.
+func (c *consumer) waitWorkers(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		c.workerPool.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// requeueCanceled requeue the messages failed because the kill timeout canceled them.
+func requeueCanceled(ctx context.Context, status int, err error) int {
+	if err != nil && ctx.Err() == context.Canceled {
+		return runner.ExitNACKRequeue
+	}
+	return status
+}
@@ -0,0 +1,46 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/internal/pool"
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_consumer_drain(t *testing.T) {
+	h := hub.New()
+	sub := h.Subscribe(10, "rabbit.consumer.*")
+	c := &consumer{hub: h, workerPool: pool.New(2), killTimeout: int64(20 * time.Millisecond)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// one message finish before the timeout and the other is stuck until canceled
+	c.dispatch(ctx, func(ctx context.Context) { time.Sleep(5 * time.Millisecond) })
+	c.dispatch(ctx, func(ctx context.Context) { <-ctx.Done() })
+
+	start := time.Now()
+	c.drain("tag", false, cancel)
+	require.True(t, time.Since(start) >= 20*time.Millisecond, "the drain should wait the kill timeout")
+	require.Equal(t, 0, c.InFlight())
+	msg := <-sub.Receiver
+	require.Equal(t, "rabbit.consumer.warning", msg.Name)
+	require.Equal(t, 1, msg.Fields["in-flight"])
+}
+
+func Test_requeueCanceled(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeout, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-timeout.Done()
+	err := errors.New("killed")
+
+	require.Equal(t, runner.ExitNACKRequeue, requeueCanceled(canceled, runner.ExitFailed, err))
+	require.Equal(t, runner.ExitACK, requeueCanceled(canceled, runner.ExitACK, nil), "the messages processed are acked")
+	require.Equal(t, runner.ExitTimeout, requeueCanceled(timeout, runner.ExitTimeout, err))
+	require.Equal(t, runner.ExitNACK, requeueCanceled(context.Background(), runner.ExitNACK, err))
+}
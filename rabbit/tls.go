@@ -0,0 +1,72 @@
+package rabbit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+// externalAuth is the SASL EXTERNAL mechanism, the broker authenticate the client using the certificate.
+type externalAuth struct{}
+
+func (externalAuth) Mechanism() string { return "EXTERNAL" }
+func (externalAuth) Response() string  { return "" }
+
+func (c TLSConfig) enabled() bool {
+	return c != TLSConfig{}
+}
+
+// tlsConfig load the certificates used by the amqps connections.
+func (c TLSConfig) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if len(c.CAFile) > 0 {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the ca file")
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("no certificates found in the ca file %s", c.CAFile)
+		}
+	}
+	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load the client certificate")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// amqpConfig create the config used to dial one connection.
+func amqpConfig(config Connection, version string) (amqp.Config, error) {
+	cfg := amqp.Config{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, config.Timeout)
+		},
+		Properties: amqp.Table{
+			"product": "message-cannon",
+			"version": version,
+		},
+	}
+	if !config.TLS.enabled() {
+		return cfg, nil
+	}
+	tlsCfg, err := config.TLS.tlsConfig()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.TLSClientConfig = tlsCfg
+	if config.TLS.ExternalAuth {
+		cfg.SASL = []amqp.Authentication{externalAuth{}}
+	}
+	return cfg, nil
+}
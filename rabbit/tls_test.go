@@ -0,0 +1,125 @@
+package rabbit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert create one certificate signed by the parent (self-signed when nil) and write it to the dir.
+func writeCert(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, key
+}
+
+func Test_amqpConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", nil, nil)
+	writeCert(t, dir, "client", ca, caKey)
+
+	cfg, err := amqpConfig(Connection{DSN: "amqp://localhost:5672"}, "1.0.0")
+	require.NoError(t, err)
+	require.Nil(t, cfg.TLSClientConfig)
+	require.Nil(t, cfg.SASL, "the DSN user and password are used by default")
+
+	cfg, err = amqpConfig(Connection{DSN: "amqps://localhost:5671", TLS: TLSConfig{
+		CAFile:       filepath.Join(dir, "ca.pem"),
+		CertFile:     filepath.Join(dir, "client.pem"),
+		KeyFile:      filepath.Join(dir, "client-key.pem"),
+		ServerName:   "rabbitmq.internal",
+		ExternalAuth: true,
+	}}, "1.0.0")
+	require.NoError(t, err)
+	require.NotNil(t, cfg.TLSClientConfig.RootCAs)
+	require.Len(t, cfg.TLSClientConfig.Certificates, 1)
+	require.Equal(t, "rabbitmq.internal", cfg.TLSClientConfig.ServerName)
+	require.Len(t, cfg.SASL, 1)
+	require.Equal(t, "EXTERNAL", cfg.SASL[0].Mechanism())
+
+	_, err = amqpConfig(Connection{TLS: TLSConfig{CAFile: filepath.Join(dir, "client-key.pem")}}, "1.0.0")
+	require.EqualError(t, err, "no certificates found in the ca file "+filepath.Join(dir, "client-key.pem"))
+}
+
+func Test_amqpConfig_mutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", nil, nil)
+	writeCert(t, dir, "server", ca, caKey)
+	writeCert(t, dir, "client", ca, caKey)
+	serverCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"))
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	require.NoError(t, err)
+	defer l.Close()
+	peers := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		if tlsConn.Handshake() == nil {
+			peers <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+		}
+	}()
+
+	cfg, err := amqpConfig(Connection{Timeout: time.Second, TLS: TLSConfig{
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		CertFile:   filepath.Join(dir, "client.pem"),
+		KeyFile:    filepath.Join(dir, "client-key.pem"),
+		ServerName: "localhost",
+	}}, "1.0.0")
+	require.NoError(t, err)
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	// the fake broker close the connection after the handshake
+	_, err = amqp.DialConfig("amqps://127.0.0.1:"+port, cfg)
+	require.Error(t, err)
+	select {
+	case cn := <-peers:
+		require.Equal(t, "client", cn)
+	case <-time.After(time.Second):
+		t.Fatal("the tls handshake didn't happen")
+	}
+}
@@ -0,0 +1,190 @@
+package rabbit
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/pkg/errors"
+)
+
+// Validate apply the default values and check the config without opening any connection.
+// Every problem is returned with the path of the option, ie: "consumers.upload.connection: ...".
+func Validate(config Config) []error {
+	var errs []error
+	if err := setConfigDefaults(&config); err != nil {
+		return []error{errors.Wrap(err, "failed to set default values for configs")}
+	}
+	names := make([]string, 0, len(config.Connections))
+	for name := range config.Connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := fmt.Sprintf("connections.%s", name)
+		errs = append(errs, validateNodes(path, config.Connections[name])...)
+		if conn := config.Connections[name]; conn.MaxSleep < conn.Sleep {
+			errs = append(errs, errors.Errorf("%s.max_sleep: the max_sleep (%s) must be greater or equal than sleep (%s)", path, conn.MaxSleep, conn.Sleep))
+		}
+		errs = append(errs, validateTLS(path, config.Connections[name])...)
+	}
+	names = names[:0]
+	for name := range config.Consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cfg := config.Consumers[name]
+		path := fmt.Sprintf("consumers.%s", name)
+		if _, ok := config.Connections[cfg.Connection]; !ok {
+			errs = append(errs, errors.Errorf("%s.connection: connection \"%s\" did not exist", path, cfg.Connection))
+		}
+		if len(cfg.DeadLetter) > 0 {
+			if _, ok := config.DeadLetters[cfg.DeadLetter]; !ok {
+				errs = append(errs, errors.Errorf("%s.dead_letter: dead letter \"%s\" did not exist", path, cfg.DeadLetter))
+			}
+		}
+		for i, b := range cfg.Queue.Bindings {
+			if _, ok := config.Exchanges[b.Exchange]; !ok {
+				errs = append(errs, errors.Errorf("%s.queue.bindings.%d.exchange: exchange \"%s\" did not exist", path, i, b.Exchange))
+			}
+		}
+		if cfg.PrefetchCount > 0 && cfg.PrefetchCount < cfg.MaxWorkers {
+			errs = append(errs, errors.Errorf("%s.prefetch_count: the prefetch_count (%d) must be greater or equal than workers (%d)",
+				path, cfg.PrefetchCount, cfg.MaxWorkers))
+		}
+		if cfg.BatchSize > 1 {
+			errs = append(errs, validateBatch(path, cfg)...)
+		}
+		if _, err := newOutput(cfg.Output); err != nil {
+			errs = append(errs, errors.Errorf("%s.output.routing_key: %s", path, errors.Cause(err)))
+		}
+		if _, ok := config.Exchanges[cfg.Output.Exchange]; len(cfg.Output.Exchange) > 0 && !ok {
+			errs = append(errs, errors.Errorf("%s.output.exchange: exchange \"%s\" did not exist", path, cfg.Output.Exchange))
+		}
+		errs = append(errs, validateRateLimit(config, path, cfg.RateLimit)...)
+		if cfg.Retry != nil && cfg.Retry.Multiplier < 1 {
+			errs = append(errs, errors.Errorf("%s.retry.multiplier: the multiplier (%v) must be greater or equal than 1", path, cfg.Retry.Multiplier))
+		}
+		for _, err := range runner.Validate(cfg.Runner) {
+			errs = append(errs, errors.Errorf("%s.runner.%s", path, err))
+		}
+	}
+	names = names[:0]
+	for name := range config.RateLimiters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l := config.RateLimiters[name]
+		if l.Rate <= 0 {
+			errs = append(errs, errors.Errorf("rate_limiters.%s.rate: the rate (%v) must be greater than zero", name, l.Rate))
+		}
+		if l.Burst < 0 {
+			errs = append(errs, errors.Errorf("rate_limiters.%s.burst: the burst (%d) can't be negative", name, l.Burst))
+		}
+	}
+	names = names[:0]
+	for name := range config.DeadLetters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for i, b := range config.DeadLetters[name].Queue.Bindings {
+			if _, ok := config.Exchanges[b.Exchange]; !ok {
+				errs = append(errs, errors.Errorf("dead_letters.%s.queue.bindings.%d.exchange: exchange \"%s\" did not exist", name, i, b.Exchange))
+			}
+		}
+	}
+	return errs
+}
+
+// validateNodes check the cluster nodes of one connection.
+func validateNodes(path string, conn Connection) []error {
+	var errs []error
+	if len(conn.DSNs) > 0 && (len(conn.DSN) > 0 || len(conn.Hosts) > 0) {
+		errs = append(errs, errors.Errorf("%s.dsns: the dsns can't be used with dsn or hosts", path))
+	}
+	if len(conn.DSNs) == 0 && len(conn.Hosts) > 0 {
+		if _, err := conn.dsns(); err != nil {
+			errs = append(errs, errors.Errorf("%s.dsn: %s", path, err))
+		}
+	}
+	if conn.HostSelection != "round-robin" && conn.HostSelection != "shuffle" {
+		errs = append(errs, errors.Errorf("%s.host_selection: invalid host selection \"%s\", use round-robin or shuffle", path, conn.HostSelection))
+	}
+	return errs
+}
+
+// validateTLS check the certificates exist and are used by an amqps connection.
+func validateTLS(path string, conn Connection) []error {
+	var errs []error
+	cfg := conn.TLS
+	if !cfg.enabled() {
+		return nil
+	}
+	dsns, _ := conn.dsns()
+	for _, dsn := range dsns {
+		if !strings.HasPrefix(dsn, "amqps://") {
+			errs = append(errs, errors.Errorf("%s.tls: the tls config requires an amqps:// dsn", path))
+			break
+		}
+	}
+	for _, file := range []struct{ option, name string }{
+		{"ca_file", cfg.CAFile},
+		{"cert_file", cfg.CertFile},
+		{"key_file", cfg.KeyFile},
+	} {
+		if _, err := os.Stat(file.name); len(file.name) > 0 && err != nil {
+			errs = append(errs, errors.Errorf("%s.tls.%s: %s", path, file.option, err))
+		}
+	}
+	if (len(cfg.CertFile) > 0) != (len(cfg.KeyFile) > 0) {
+		errs = append(errs, errors.Errorf("%s.tls: the cert_file and key_file must be used together", path))
+	}
+	if cfg.ExternalAuth && len(cfg.CertFile) == 0 {
+		errs = append(errs, errors.Errorf("%s.tls.external_auth: the external auth requires the client certificate", path))
+	}
+	return errs
+}
+
+// validateBatch check the batches can be filled and only use the features supported by the batches.
+func validateBatch(path string, cfg ConsumerConfig) []error {
+	var errs []error
+	if cfg.PrefetchCount < cfg.BatchSize {
+		errs = append(errs, errors.Errorf("%s.prefetch_count: the prefetch_count (%d) must be greater or equal than batch_size (%d)",
+			path, cfg.PrefetchCount, cfg.BatchSize))
+	}
+	if cfg.BatchTimeout <= 0 {
+		errs = append(errs, errors.Errorf("%s.batch_timeout: the batch_timeout (%s) must be greater than zero", path, cfg.BatchTimeout))
+	}
+	if cfg.Runner.Type != "http" {
+		errs = append(errs, errors.Errorf("%s.batch_size: the batches are only supported by the http runner", path))
+	}
+	if cfg.Reply || cfg.Output.enabled() {
+		errs = append(errs, errors.Errorf("%s.batch_size: the batches can't be used with reply or output", path))
+	}
+	return errs
+}
+
+// validateRateLimit check the consumer uses one existing shared limiter or its own rate.
+func validateRateLimit(config Config, path string, cfg RateLimitConfig) []error {
+	var errs []error
+	if len(cfg.Limiter) > 0 {
+		if _, ok := config.RateLimiters[cfg.Limiter]; !ok {
+			errs = append(errs, errors.Errorf("%s.rate_limit.limiter: rate limiter \"%s\" did not exist", path, cfg.Limiter))
+		}
+		if cfg.Rate != 0 || cfg.Burst != 0 {
+			errs = append(errs, errors.Errorf("%s.rate_limit.limiter: use the limiter or the rate and burst, not both", path))
+		}
+	}
+	if cfg.Rate < 0 {
+		errs = append(errs, errors.Errorf("%s.rate_limit.rate: the rate (%v) can't be negative", path, cfg.Rate))
+	}
+	if cfg.Burst < 0 {
+		errs = append(errs, errors.Errorf("%s.rate_limit.burst: the burst (%d) can't be negative", path, cfg.Burst))
+	}
+	return errs
+}
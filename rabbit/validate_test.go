@@ -0,0 +1,72 @@
+package rabbit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/message-cannon/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	config := Config{
+		Connections: map[string]Connection{
+			"default": {DSN: "amqp://localhost:5672"},
+			"secure":  {DSN: "amqp://localhost:5671", TLS: TLSConfig{CertFile: "missing.pem", ExternalAuth: true}},
+			"cluster": {DSN: "amqp://localhost:5672", DSNs: []string{"amqp://rabbit-1:5672"}, HostSelection: "random", Sleep: time.Minute},
+		},
+		Exchanges: map[string]ExchangeConfig{"upload": {Type: "topic"}},
+		DeadLetters: map[string]DeadLetter{
+			"fallback": {Queue: QueueConfig{Name: "fallback", Bindings: []Binding{{Exchange: "fallback"}}}},
+		},
+		RateLimiters: map[string]RateLimiter{"search": {Rate: 0}, "payments": {Rate: 10, Burst: 5}},
+		Consumers: map[string]ConsumerConfig{
+			"valid": {
+				Connection: "default",
+				DeadLetter: "fallback",
+				RateLimit:  RateLimitConfig{Limiter: "payments"},
+				Queue:      QueueConfig{Name: "valid", Bindings: []Binding{{Exchange: "upload"}}},
+				Runner:     runner.Config{Type: "http", Options: runner.Options{URL: "http://localhost"}},
+			},
+			"invalid": {
+				Connection:    "other",
+				DeadLetter:    "missing",
+				MaxWorkers:    4,
+				PrefetchCount: 2,
+				BatchSize:     5,
+				Queue:         QueueConfig{Name: "invalid", Bindings: []Binding{{Exchange: "missing"}}},
+				Retry:         &RetryConfig{Multiplier: 0.5},
+				Output:        OutputConfig{Exchange: "results", RoutingKey: "{{ .Headers"},
+				RateLimit:     RateLimitConfig{Limiter: "missing", Rate: 5},
+				Runner:        runner.Config{Type: "htp"},
+			},
+		},
+	}
+	var got []string
+	for _, err := range Validate(config) {
+		got = append(got, err.Error())
+	}
+	assert.Equal(t, []string{
+		"connections.cluster.dsns: the dsns can't be used with dsn or hosts",
+		`connections.cluster.host_selection: invalid host selection "random", use round-robin or shuffle`,
+		"connections.cluster.max_sleep: the max_sleep (30s) must be greater or equal than sleep (1m0s)",
+		"connections.secure.tls: the tls config requires an amqps:// dsn",
+		"connections.secure.tls.cert_file: stat missing.pem: no such file or directory",
+		"connections.secure.tls: the cert_file and key_file must be used together",
+		`consumers.invalid.connection: connection "other" did not exist`,
+		`consumers.invalid.dead_letter: dead letter "missing" did not exist`,
+		`consumers.invalid.queue.bindings.0.exchange: exchange "missing" did not exist`,
+		"consumers.invalid.prefetch_count: the prefetch_count (2) must be greater or equal than workers (4)",
+		"consumers.invalid.prefetch_count: the prefetch_count (2) must be greater or equal than batch_size (5)",
+		"consumers.invalid.batch_size: the batches are only supported by the http runner",
+		"consumers.invalid.batch_size: the batches can't be used with reply or output",
+		`consumers.invalid.output.routing_key: template: routing_key:1: unclosed action`,
+		`consumers.invalid.output.exchange: exchange "results" did not exist`,
+		`consumers.invalid.rate_limit.limiter: rate limiter "missing" did not exist`,
+		"consumers.invalid.rate_limit.limiter: use the limiter or the rate and burst, not both",
+		"consumers.invalid.retry.multiplier: the multiplier (0.5) must be greater or equal than 1",
+		`consumers.invalid.runner.type: invalid Runner type ("htp") expecting one of (command, http, process-pool, fastcgi, grpc)`,
+		"rate_limiters.search.rate: the rate (0) must be greater than zero",
+		`dead_letters.fallback.queue.bindings.0.exchange: exchange "fallback" did not exist`,
+	}, got)
+}
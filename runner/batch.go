@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// batchItem is how one message is encoded in the batch request.
+// Every body is sent as base64, so any content (json, text or binary) reach the endpoint unchanged.
+type batchItem struct {
+	Headers map[string]string `json:"headers"`
+	Body    []byte            `json:"body"`
+}
+
+// ProcessBatch process the messages in one call when the runner supports it, otherwise one by one.
+func ProcessBatch(ctx context.Context, r Runnable, msgs []Message) []Result {
+	if br, ok := r.(BatchRunnable); ok {
+		return br.ProcessBatch(ctx, msgs)
+	}
+	results := make([]Result, len(msgs))
+	for i, msg := range msgs {
+		results[i].Status, results[i].Err = r.Process(ctx, msg)
+	}
+	return results
+}
+
+// batchResults return the same result for all the messages, used when the whole batch failed.
+func batchResults(n int, status int, err error) []Result {
+	results := make([]Result, n)
+	for i := range results {
+		results[i] = Result{Status: status, Err: err}
+	}
+	return results
+}
+
+// ProcessBatch send all the messages in one request and read one response-code for every message:
+// [{"response-code": 0}, {"response-code": 4}]
+func (p *httpRunner) ProcessBatch(ctx context.Context, msgs []Message) []Result {
+	req, err := p.prepareBatchRequest(msgs)
+	if err != nil {
+		return batchResults(len(msgs), ExitNACKRequeue, errors.Wrap(err, "request creation failed"))
+	}
+	req = req.WithContext(ctx)
+	resp, body, err := p.executeRequest(req)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return batchResults(len(msgs), ExitTimeout, &Error{Err: netErr, StatusCode: -1})
+		}
+		return batchResults(len(msgs), ExitNACKRequeue, errors.Wrap(err, "failed doing the request"))
+	}
+	if resp.StatusCode >= 400 || p.ignoreOutput {
+		status, err := handleResponse(resp.StatusCode, body, p.ignoreOutput, p.returnOn5xx)
+		return batchResults(len(msgs), status, err)
+	}
+	var content []struct {
+		ResponseCode int `json:"response-code"`
+	}
+	err = json.Unmarshal(body, &content)
+	if err == nil && len(content) != len(msgs) {
+		err = errors.Errorf("expecting %d results, received %d", len(msgs), len(content))
+	}
+	if err != nil {
+		return batchResults(len(msgs), ExitNACKRequeue, &Error{
+			Err:        err,
+			StatusCode: resp.StatusCode,
+			Output:     body,
+		})
+	}
+	results := make([]Result, len(msgs))
+	for i, c := range content {
+		results[i].Status = c.ResponseCode
+	}
+	return results
+}
+
+func (p *httpRunner) prepareBatchRequest(msgs []Message) (*http.Request, error) {
+	var b bytes.Buffer
+	items := make([]batchItem, len(msgs))
+	for i, msg := range msgs {
+		items[i] = newBatchItem(msg)
+	}
+	contentType := "application/json"
+	if p.batchFormat == "ndjson" {
+		contentType = "application/x-ndjson"
+		enc := json.NewEncoder(&b)
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := json.NewEncoder(&b).Encode(items); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", p.url, &b)
+	if err != nil {
+		return req, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+func newBatchItem(msg Message) batchItem {
+	item := batchItem{Headers: make(map[string]string, len(msg.Headers)), Body: msg.Body}
+	for k, v := range msg.Headers {
+		if value, ok := headerValue(v); ok {
+			item.Headers[k] = value
+		}
+	}
+	return item
+}
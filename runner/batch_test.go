@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_httpRunner_ProcessBatch(t *testing.T) {
+	msgs := []Message{
+		{Body: []byte(`{"id":1}`), Headers: Headers{"Message-Id": "1"}},
+		{Body: []byte(`plain text`), Headers: Headers{"Retry-Count": 2}},
+	}
+	tests := []struct {
+		name         string
+		format       string
+		ignoreOutput bool
+		statusCode   int
+		response     string
+		wantBody     string
+		wantType     string
+		want         []int
+		wantErr      bool
+	}{
+		{
+			"json batch with one result for every message",
+			"json", false, http.StatusOK, `[{"response-code": 0}, {"response-code": 3}]`,
+			`[{"headers":{"Message-Id":"1"},"body":"eyJpZCI6MX0="},{"headers":{"Retry-Count":"2"},"body":"cGxhaW4gdGV4dA=="}]` + "\n",
+			"application/json",
+			[]int{ExitACK, ExitNACK}, false,
+		},
+		{
+			"ndjson batch",
+			"ndjson", false, http.StatusOK, `[{"response-code": 5}, {"response-code": 0}]`,
+			`{"headers":{"Message-Id":"1"},"body":"eyJpZCI6MX0="}` + "\n" + `{"headers":{"Retry-Count":"2"},"body":"cGxhaW4gdGV4dA=="}` + "\n",
+			"application/x-ndjson",
+			[]int{ExitRetry, ExitACK}, false,
+		},
+		{
+			"missing results requeue the batch",
+			"json", false, http.StatusOK, `[{"response-code": 0}]`, "", "",
+			[]int{ExitNACKRequeue, ExitNACKRequeue}, true,
+		},
+		{
+			"5xx error is used for the whole batch",
+			"json", false, http.StatusBadGateway, `bad gateway`, "", "",
+			[]int{ExitNACKRequeue, ExitNACKRequeue}, true,
+		},
+		{
+			"ignore output ack all the messages",
+			"json", true, http.StatusOK, ``, "", "",
+			[]int{ExitACK, ExitACK}, false,
+		},
+	}
+	for _, tt := range tests {
+		ctt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				if len(ctt.wantBody) > 0 {
+					require.Equal(t, ctt.wantBody, string(body))
+					require.Equal(t, ctt.wantType, r.Header.Get("Content-Type"))
+				}
+				w.WriteHeader(ctt.statusCode)
+				_, _ = w.Write([]byte(ctt.response))
+			}))
+			defer server.Close()
+			r, err := New(Config{
+				Type:         "http",
+				IgnoreOutput: ctt.ignoreOutput,
+				Options:      Options{URL: server.URL, BatchFormat: ctt.format, ReturnOn5xx: ExitNACKRequeue},
+			}, hub.New())
+			require.NoError(t, err)
+
+			results := ProcessBatch(context.Background(), r, msgs)
+			require.Len(t, results, len(msgs))
+			for i, result := range results {
+				require.Equal(t, ctt.want[i], result.Status)
+				if ctt.wantErr {
+					require.Error(t, result.Err)
+				} else {
+					require.NoError(t, result.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessBatch_withoutBatchSupport(t *testing.T) {
+	r := &failingRunner{}
+	results := ProcessBatch(context.Background(), r, []Message{{}, {}, {}})
+	require.Equal(t, []Result{{Status: ExitACK}, {Status: ExitACK}, {Status: ExitACK}}, results)
+	require.Equal(t, 3, r.count())
+}
+
+func Test_newBatchItem(t *testing.T) {
+	for _, body := range [][]byte{[]byte(`"abc"`), []byte(`abc`), {0xff, 0x00, 0xfe}} {
+		b, err := json.Marshal(newBatchItem(Message{Body: body}))
+		require.NoError(t, err)
+		item := batchItem{}
+		require.NoError(t, json.Unmarshal(b, &item))
+		require.Equal(t, body, item.Body)
+	}
+}
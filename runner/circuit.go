@@ -0,0 +1,227 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/pkg/errors"
+)
+
+// Circuit states published on the runner.circuit.* events.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned when the message was not processed because the circuit is open.
+var ErrCircuitOpen = errors.New("the circuit breaker is open")
+
+type (
+	// CircuitConfig describe when the circuit breaker opens.
+	// The circuit is disabled when both ConsecutiveFailures and ErrorRate are zero.
+	CircuitConfig struct {
+		ConsecutiveFailures int `mapstructure:"consecutive-failures"`
+		// ErrorRate (between 0 and 1) is calculated every Window after MinRequests.
+		ErrorRate   float64       `mapstructure:"error-rate"`
+		Window      time.Duration `mapstructure:"window" default:"1m"`
+		MinRequests int           `mapstructure:"min-requests" default:"10"`
+		// OpenTimeout is how long the circuit stays open before trying one message (half-open).
+		OpenTimeout time.Duration `mapstructure:"open-timeout" default:"30s"`
+	}
+
+	// Breaker is implemented by the runners wrapped by a circuit breaker.
+	Breaker interface {
+		// Notify register one function called on every state change,
+		// used by the consumers to stop consuming while the circuit is open.
+		Notify(fn func(state string))
+	}
+)
+
+func (c CircuitConfig) enabled() bool {
+	return c.ConsecutiveFailures > 0 || c.ErrorRate > 0
+}
+
+// circuitBreaker wrap one runner and stop calling it after too many errors.
+// While open the messages wait the OpenTimeout and are requeued without calling the runner.
+type circuitBreaker struct {
+	runner Runnable
+	cfg    CircuitConfig
+	hub    *hub.Hub
+
+	mu          sync.Mutex
+	state       string
+	consecutive int
+	requests    int
+	failures    int
+	windowStart time.Time
+	probing     bool
+	halfOpen    *time.Timer
+	// leave is closed when the circuit leaves the open state.
+	leave  chan struct{}
+	notify []func(string)
+}
+
+func newCircuitBreaker(r Runnable, cfg CircuitConfig, h *hub.Hub) *circuitBreaker {
+	return &circuitBreaker{
+		runner:      r,
+		cfg:         cfg,
+		hub:         h,
+		state:       CircuitClosed,
+		windowStart: time.Now(),
+	}
+}
+
+func (b *circuitBreaker) Process(ctx context.Context, msg Message) (int, error) {
+	status, _, err := b.ProcessOutput(ctx, msg)
+	return status, err
+}
+
+// ProcessOutput call the runner when the circuit is closed or when this message is the half-open probe.
+func (b *circuitBreaker) ProcessOutput(ctx context.Context, msg Message) (int, []byte, error) {
+	wait, probe, ok := b.allow()
+	if !ok {
+		select {
+		case <-wait:
+		case <-ctx.Done():
+		}
+		return ExitNACKRequeue, nil, ErrCircuitOpen
+	}
+	status, output, err := ProcessOutput(ctx, b.runner, msg)
+	b.record(err == nil, probe)
+	return status, output, err
+}
+
+// ProcessBatch call the runner with all the messages, the batch counts as one request for the circuit.
+func (b *circuitBreaker) ProcessBatch(ctx context.Context, msgs []Message) []Result {
+	wait, probe, ok := b.allow()
+	if !ok {
+		select {
+		case <-wait:
+		case <-ctx.Done():
+		}
+		return batchResults(len(msgs), ExitNACKRequeue, ErrCircuitOpen)
+	}
+	results := ProcessBatch(ctx, b.runner, msgs)
+	success := true
+	for _, r := range results {
+		success = success && r.Err == nil
+	}
+	b.record(success, probe)
+	return results
+}
+
+// Notify register one function called on every state change, fn must not block.
+func (b *circuitBreaker) Notify(fn func(state string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.notify = append(b.notify, fn)
+}
+
+// Close stop the half-open timer and close the wrapped runner.
+func (b *circuitBreaker) Close() error {
+	b.mu.Lock()
+	if b.halfOpen != nil && b.halfOpen.Stop() {
+		// release the messages waiting the circuit
+		close(b.leave)
+	}
+	b.mu.Unlock()
+	if closer, ok := b.runner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// allow return if the message can be processed now and if it's the half-open probe.
+// When not allowed the message must wait the returned channel before being requeued.
+func (b *circuitBreaker) allow() (wait <-chan struct{}, probe bool, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.state == CircuitClosed:
+		return nil, false, true
+	case b.state == CircuitHalfOpen && !b.probing:
+		b.probing = true
+		return nil, true, true
+	case b.state == CircuitHalfOpen:
+		// another message is probing, wait the OpenTimeout to not requeue in a tight loop.
+		w := make(chan struct{})
+		time.AfterFunc(b.cfg.OpenTimeout, func() { close(w) })
+		return w, false, false
+	}
+	return b.leave, false, false
+}
+
+func (b *circuitBreaker) record(success, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+		if success {
+			b.transition(CircuitClosed, hub.Fields{})
+		} else {
+			b.open(hub.Fields{"reason": "the half-open probe failed"})
+		}
+		return
+	}
+	// the results of messages started before the circuit opened are ignored
+	if b.state != CircuitClosed {
+		return
+	}
+	if time.Since(b.windowStart) > b.cfg.Window {
+		b.windowStart = time.Now()
+		b.requests, b.failures = 0, 0
+	}
+	b.requests++
+	if success {
+		b.consecutive = 0
+		return
+	}
+	b.failures++
+	b.consecutive++
+	rate := float64(b.failures) / float64(b.requests)
+	switch {
+	case b.cfg.ConsecutiveFailures > 0 && b.consecutive >= b.cfg.ConsecutiveFailures:
+		b.open(hub.Fields{"reason": "consecutive failures", "failures": b.consecutive})
+	case b.cfg.ErrorRate > 0 && b.requests >= b.cfg.MinRequests && rate >= b.cfg.ErrorRate:
+		b.open(hub.Fields{"reason": "error rate", "error-rate": rate, "requests": b.requests})
+	}
+}
+
+// open must be called with the lock held.
+func (b *circuitBreaker) open(fields hub.Fields) {
+	b.leave = make(chan struct{})
+	leave := b.leave
+	b.halfOpen = time.AfterFunc(b.cfg.OpenTimeout, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		close(leave)
+		b.transition(CircuitHalfOpen, hub.Fields{})
+	})
+	b.transition(CircuitOpen, fields)
+}
+
+// transition must be called with the lock held.
+func (b *circuitBreaker) transition(state string, fields hub.Fields) {
+	from := b.state
+	b.state = state
+	b.consecutive, b.requests, b.failures = 0, 0, 0
+	b.windowStart = time.Now()
+	fields["state"] = state
+	fields["from"] = from
+	level := "info"
+	if state == CircuitOpen {
+		level = "warning"
+	}
+	b.hub.Publish(hub.Message{
+		Name:   "runner.circuit." + level,
+		Body:   []byte("the circuit breaker changed the state"),
+		Fields: fields,
+	})
+	for _, fn := range b.notify {
+		fn(state)
+	}
+}
@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRunner fail while fail is true.
+type failingRunner struct {
+	mu    sync.Mutex
+	fail  bool
+	calls int
+}
+
+func (r *failingRunner) Process(ctx context.Context, msg Message) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.fail {
+		return ExitNACKRequeue, errors.New("backend is down")
+	}
+	return ExitACK, nil
+}
+
+func (r *failingRunner) set(fail bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fail = fail
+}
+
+func (r *failingRunner) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func newTestBreaker(r Runnable, cfg CircuitConfig) (*circuitBreaker, *hub.Hub, chan string) {
+	h := hub.New()
+	b := newCircuitBreaker(r, cfg, h)
+	states := make(chan string, 10)
+	b.Notify(func(state string) { states <- state })
+	return b, h, states
+}
+
+func TestCircuitBreaker_consecutiveFailures(t *testing.T) {
+	r := &failingRunner{fail: true}
+	b, h, states := newTestBreaker(r, CircuitConfig{
+		ConsecutiveFailures: 3,
+		Window:              time.Minute,
+		OpenTimeout:         50 * time.Millisecond,
+	})
+	defer b.Close()
+	sub := h.Subscribe(10, "runner.circuit.*")
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		status, err := b.Process(ctx, Message{})
+		require.Error(t, err)
+		require.Equal(t, ExitNACKRequeue, status)
+	}
+	require.Equal(t, CircuitOpen, <-states)
+	msg := <-sub.Receiver
+	require.Equal(t, "runner.circuit.warning", msg.Name)
+	require.Equal(t, CircuitOpen, msg.Fields["state"])
+	require.Equal(t, CircuitClosed, msg.Fields["from"])
+	require.Equal(t, "consecutive failures", msg.Fields["reason"])
+
+	// while open the runner is not called and the message waits the half-open state.
+	start := time.Now()
+	status, err := b.Process(ctx, Message{})
+	require.Equal(t, ErrCircuitOpen, err)
+	require.Equal(t, ExitNACKRequeue, status)
+	require.Equal(t, 3, r.count())
+	require.True(t, time.Since(start) >= 40*time.Millisecond, "the message should wait the open timeout")
+	require.Equal(t, CircuitHalfOpen, <-states)
+
+	// the failed probe opens the circuit again
+	_, err = b.Process(ctx, Message{})
+	require.EqualError(t, err, "backend is down")
+	require.Equal(t, CircuitOpen, <-states)
+	require.Equal(t, CircuitHalfOpen, <-states)
+
+	// the probe succeeded and close the circuit
+	r.set(false)
+	status, err = b.Process(ctx, Message{})
+	require.NoError(t, err)
+	require.Equal(t, ExitACK, status)
+	require.Equal(t, CircuitClosed, <-states)
+	require.Equal(t, 5, r.count())
+}
+
+func TestCircuitBreaker_errorRate(t *testing.T) {
+	r := &failingRunner{}
+	b, _, states := newTestBreaker(r, CircuitConfig{
+		ErrorRate:   0.5,
+		MinRequests: 4,
+		Window:      time.Minute,
+		OpenTimeout: time.Minute,
+	})
+	defer b.Close()
+	ctx := context.Background()
+
+	for _, fail := range []bool{false, true, false} {
+		r.set(fail)
+		_, _ = b.Process(ctx, Message{})
+	}
+	require.Len(t, states, 0, "the circuit should wait the min requests")
+	r.set(true)
+	_, _ = b.Process(ctx, Message{})
+	require.Equal(t, CircuitOpen, <-states)
+}
+
+func TestCircuitBreaker_contextCanceled(t *testing.T) {
+	r := &failingRunner{fail: true}
+	b, _, states := newTestBreaker(r, CircuitConfig{
+		ConsecutiveFailures: 1,
+		Window:              time.Minute,
+		OpenTimeout:         time.Minute,
+	})
+	defer b.Close()
+	_, _ = b.Process(context.Background(), Message{})
+	require.Equal(t, CircuitOpen, <-states)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	status, err := b.Process(ctx, Message{})
+	require.Equal(t, ErrCircuitOpen, err)
+	require.Equal(t, ExitNACKRequeue, status)
+	require.Equal(t, 1, r.count())
+}
+
+func TestNew_withCircuit(t *testing.T) {
+	r, err := New(Config{
+		Type:    "http",
+		Options: Options{URL: "http://localhost:8080"},
+		Circuit: CircuitConfig{ConsecutiveFailures: 5, OpenTimeout: time.Second},
+	}, hub.New())
+	require.NoError(t, err)
+	_, ok := r.(Breaker)
+	require.True(t, ok, "the runner should be wrapped by the circuit breaker")
+}
@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"strings"
@@ -19,10 +20,16 @@ type command struct {
 }
 
 func (c *command) Process(ctx context.Context, msg Message) (int, error) {
+	status, _, err := c.ProcessOutput(ctx, msg)
+	return status, err
+}
+
+// ProcessOutput run the command and return the stdout, the stderr is only used in the errors.
+func (c *command) ProcessOutput(ctx context.Context, msg Message) (int, []byte, error) {
 	cmd := exec.CommandContext(ctx, c.cmd, c.args...)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return ExitNACKRequeue, errors.Wrap(err, "open pipe to stdin failed")
+		return ExitNACKRequeue, nil, errors.Wrap(err, "open pipe to stdin failed")
 	}
 	go func() {
 		_, pipeErr := stdin.Write(msg.Body)
@@ -42,20 +49,23 @@ func (c *command) Process(ctx context.Context, msg Message) (int, error) {
 			})
 		}
 	}()
-	output, err := cmd.CombinedOutput()
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
 	if err != nil {
 		if exiterr, ok := err.(*exec.ExitError); ok {
 			if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
-				return status.ExitStatus(), &Error{
+				return status.ExitStatus(), nil, &Error{
 					Err:        exiterr,
-					Output:     output,
+					Output:     append(stdout.Bytes(), stderr.Bytes()...),
 					StatusCode: status.ExitStatus(),
 				}
 			}
 		}
-		return ExitNACKRequeue, err
+		return ExitNACKRequeue, nil, err
 	}
-	return ExitACK, nil
+	return ExitACK, stdout.Bytes(), nil
 }
 
 func newCommand(c Config, h *hub.Hub) (*command, error) {
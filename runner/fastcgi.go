@@ -0,0 +1,350 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/pkg/errors"
+)
+
+// FastCGI record types and constants used by this client.
+// See https://fast-cgi.github.io/spec for the protocol specification.
+const (
+	fcgiVersion       = 1
+	fcgiBeginRequest  = 1
+	fcgiEndRequest    = 3
+	fcgiParams        = 4
+	fcgiStdin         = 5
+	fcgiStdout        = 6
+	fcgiStderr        = 7
+	fcgiResponder     = 1
+	fcgiKeepConn      = 1
+	fcgiMaxWrite      = 65535
+	fcgiRequestID     = 1
+	fcgiHeaderLen     = 8
+	fcgiDefaultStatus = 200
+)
+
+type fastcgiRunner struct {
+	network        string
+	address        string
+	scriptFilename string
+	ignoreOutput   bool
+	returnOn5xx    int
+	dialTimeout    time.Duration
+	timeout        time.Duration
+	headers        map[string]string
+	conns          chan net.Conn
+	hub            *hub.Hub
+}
+
+type fcgiResponse struct {
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+func (f *fastcgiRunner) Process(ctx context.Context, msg Message) (int, error) {
+	status, _, err := f.ProcessOutput(ctx, msg)
+	return status, err
+}
+
+// ProcessOutput send the request and return the response body.
+// The server can close the idle connections (ie: pm.max_requests on PHP-FPM), so the requests
+// failing on a pooled connection before any response are sent once again on a new connection.
+func (f *fastcgiRunner) ProcessOutput(ctx context.Context, msg Message) (int, []byte, error) {
+	conn, reused, err := f.acquire()
+	if err != nil {
+		return ExitNACKRequeue, nil, errors.Wrap(err, "failed to connect with the fastcgi server")
+	}
+	resp, received, err := f.request(ctx, conn, msg)
+	if err != nil && reused && !received && ctx.Err() == nil && !isTimeout(err) {
+		f.discard(conn)
+		conn, err = f.dial()
+		if err != nil {
+			return ExitNACKRequeue, nil, errors.Wrap(err, "failed to connect with the fastcgi server")
+		}
+		resp, _, err = f.request(ctx, conn, msg)
+	}
+	if err != nil {
+		f.discard(conn)
+		if isTimeout(err) {
+			return ExitTimeout, nil, &Error{Err: err, StatusCode: -1}
+		}
+		return ExitNACKRequeue, nil, errors.Wrap(err, "failed doing the request")
+	}
+	f.release(conn)
+	if resp.stderr.Len() > 0 {
+		f.hub.Publish(hub.Message{
+			Name:   "runner.fastcgi.warning",
+			Body:   []byte("fastcgi server wrote to stderr"),
+			Fields: hub.Fields{"output": resp.stderr.Bytes()},
+		})
+	}
+	status, body, err := parseCGIResponse(resp.stdout.Bytes())
+	if err != nil {
+		return ExitNACKRequeue, nil, &Error{Err: err, StatusCode: -1, Output: resp.stdout.Bytes()}
+	}
+	status, err = handleResponse(status, body, f.ignoreOutput, f.returnOn5xx)
+	if err != nil {
+		return status, nil, err
+	}
+	return status, body, nil
+}
+
+// Close all the idle connections.
+func (f *fastcgiRunner) Close() error {
+	for {
+		select {
+		case conn := <-f.conns:
+			f.discard(conn)
+		default:
+			return nil
+		}
+	}
+}
+
+// request do the round trip respecting the ctx and the runner timeout.
+// received is true when the server sent any byte of the response.
+func (f *fastcgiRunner) request(ctx context.Context, conn net.Conn, msg Message) (resp *fcgiResponse, received bool, err error) {
+	deadline, ok := ctx.Deadline()
+	if !ok && f.timeout > 0 {
+		deadline = time.Now().Add(f.timeout)
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		return nil, false, errors.Wrap(err, "failed to set the connection deadline")
+	}
+	done := make(chan struct{})
+	watching := make(chan struct{})
+	go func() {
+		defer close(watching)
+		select {
+		case <-ctx.Done():
+			// unblock any read/write when the context is canceled.
+			_ = conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	resp, received, err = f.roundTrip(conn, msg)
+	close(done)
+	<-watching
+	return resp, received, err
+}
+
+func (f *fastcgiRunner) roundTrip(conn net.Conn, msg Message) (*fcgiResponse, bool, error) {
+	w := bufio.NewWriter(conn)
+	err := writeRecord(w, fcgiBeginRequest, []byte{0, fcgiResponder, fcgiKeepConn, 0, 0, 0, 0, 0})
+	if err != nil {
+		return nil, false, err
+	}
+	if err = writeStream(w, fcgiParams, encodeParams(f.params(msg))); err != nil {
+		return nil, false, err
+	}
+	if err = writeStream(w, fcgiStdin, msg.Body); err != nil {
+		return nil, false, err
+	}
+	if err = w.Flush(); err != nil {
+		return nil, false, err
+	}
+	resp := &fcgiResponse{}
+	r := bufio.NewReader(conn)
+	header := make([]byte, fcgiHeaderLen)
+	for received := false; ; received = true {
+		if n, err := io.ReadFull(r, header); err != nil {
+			return nil, received || n > 0, err
+		}
+		length := int(binary.BigEndian.Uint16(header[4:6]))
+		padding := int(header[6])
+		content := make([]byte, length+padding)
+		if _, err = io.ReadFull(r, content); err != nil {
+			return nil, true, err
+		}
+		switch header[1] {
+		case fcgiStdout:
+			resp.stdout.Write(content[:length])
+		case fcgiStderr:
+			resp.stderr.Write(content[:length])
+		case fcgiEndRequest:
+			return resp, true, nil
+		}
+	}
+}
+
+func (f *fastcgiRunner) params(msg Message) map[string]string {
+	params := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_SOFTWARE":   "message-cannon",
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"REQUEST_METHOD":    "POST",
+		"SCRIPT_FILENAME":   f.scriptFilename,
+		"SCRIPT_NAME":       f.scriptFilename,
+		"REQUEST_URI":       f.scriptFilename,
+		"CONTENT_LENGTH":    strconv.Itoa(len(msg.Body)),
+	}
+	for k, v := range f.headers {
+		params[cgiParamName(k)] = v
+	}
+	for k, v := range msg.Headers {
+		if value, ok := headerValue(v); ok {
+			params[cgiParamName(k)] = value
+		}
+	}
+	if ct, ok := params["HTTP_CONTENT_TYPE"]; ok {
+		params["CONTENT_TYPE"] = ct
+		delete(params, "HTTP_CONTENT_TYPE")
+	}
+	return params
+}
+
+// acquire return one idle connection from the pool or a new one, reused is true for the idle ones.
+func (f *fastcgiRunner) acquire() (conn net.Conn, reused bool, err error) {
+	select {
+	case conn := <-f.conns:
+		return conn, true, nil
+	default:
+		conn, err := f.dial()
+		return conn, false, err
+	}
+}
+
+func (f *fastcgiRunner) dial() (net.Conn, error) {
+	return net.DialTimeout(f.network, f.address, f.dialTimeout)
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// release put the connection back to the pool or close when the pool is full.
+func (f *fastcgiRunner) release(conn net.Conn) {
+	select {
+	case f.conns <- conn:
+	default:
+		f.discard(conn)
+	}
+}
+
+func (f *fastcgiRunner) discard(conn net.Conn) {
+	if err := conn.Close(); err != nil {
+		f.hub.Publish(hub.Message{
+			Name:   "system.log.error",
+			Body:   []byte("error closing the fastcgi connection"),
+			Fields: hub.Fields{"error": err},
+		})
+	}
+}
+
+// cgiParamName convert one header name to the CGI format: Message-Id => HTTP_MESSAGE_ID
+func cgiParamName(header string) string {
+	return "HTTP_" + strings.ToUpper(strings.Replace(header, "-", "_", -1))
+}
+
+func writeRecord(w io.Writer, recType byte, content []byte) error {
+	header := []byte{fcgiVersion, recType, 0, fcgiRequestID, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(header[4:6], uint16(len(content)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(content)
+	return err
+}
+
+// writeStream split the content in records and send an empty record to mark the end of the stream.
+func writeStream(w io.Writer, recType byte, content []byte) error {
+	for len(content) > 0 {
+		n := len(content)
+		if n > fcgiMaxWrite {
+			n = fcgiMaxWrite
+		}
+		if err := writeRecord(w, recType, content[:n]); err != nil {
+			return err
+		}
+		content = content[n:]
+	}
+	return writeRecord(w, recType, nil)
+}
+
+func encodeParams(params map[string]string) []byte {
+	b := bytes.Buffer{}
+	for k, v := range params {
+		writeParamSize(&b, len(k))
+		writeParamSize(&b, len(v))
+		b.WriteString(k)
+		b.WriteString(v)
+	}
+	return b.Bytes()
+}
+
+func writeParamSize(b *bytes.Buffer, size int) {
+	if size <= 127 {
+		b.WriteByte(byte(size))
+		return
+	}
+	s := make([]byte, 4)
+	binary.BigEndian.PutUint32(s, uint32(size)|1<<31)
+	b.Write(s)
+}
+
+// parseCGIResponse read the headers and body written by the fastcgi application.
+// The status code is taken from the "Status" header, like web servers do.
+func parseCGIResponse(stdout []byte) (int, []byte, error) {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(stdout)))
+	header, err := r.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return 0, nil, errors.Wrap(err, "failed to read the response headers")
+	}
+	status := fcgiDefaultStatus
+	if s := header.Get("Status"); len(s) >= 3 {
+		status, err = strconv.Atoi(s[:3])
+		if err != nil {
+			return 0, nil, errors.Wrapf(err, "invalid status header \"%s\"", s)
+		}
+	}
+	body, err := ioutil.ReadAll(r.R)
+	return status, body, err
+}
+
+func newFastCGI(c Config, h *hub.Hub) (*fastcgiRunner, error) {
+	u, err := url.Parse(c.Options.URL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid fastcgi url \"%s\"", c.Options.URL)
+	}
+	address := u.Host
+	switch u.Scheme {
+	case "tcp":
+	case "unix":
+		address = u.Path
+	default:
+		return nil, errors.Errorf("invalid fastcgi url \"%s\" expecting one of (tcp://, unix://)", c.Options.URL)
+	}
+	if len(c.Options.ScriptFilename) == 0 {
+		return nil, errors.New("the fastcgi runner requires the script-filename option")
+	}
+	conns := c.Options.Connections
+	if conns < 1 {
+		conns = 1
+	}
+	return &fastcgiRunner{
+		network:        u.Scheme,
+		address:        address,
+		scriptFilename: c.Options.ScriptFilename,
+		ignoreOutput:   c.IgnoreOutput,
+		returnOn5xx:    c.Options.ReturnOn5xx,
+		dialTimeout:    5 * time.Second,
+		timeout:        c.Timeout,
+		headers:        c.Options.Headers,
+		conns:          make(chan net.Conn, conns),
+		hub:            h,
+	}, nil
+}
@@ -0,0 +1,214 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/require"
+)
+
+func startFastCGIServer(t *testing.T, network, address string) net.Listener {
+	l, err := net.Listen(network, address)
+	require.NoError(t, err)
+	serveFastCGI(l)
+	return l
+}
+
+func serveFastCGI(l net.Listener) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		env := fcgi.ProcessEnv(req)
+		if env["SCRIPT_FILENAME"] != "/var/www/consumer.php" {
+			http.Error(w, "script not found", http.StatusNotFound)
+			return
+		}
+		msg := message{}
+		if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg.Sleep > 0 {
+			time.Sleep(msg.Sleep)
+		}
+		w.Header().Set("Message-Id", req.Header.Get("Message-Id"))
+		w.WriteHeader(msg.Code)
+		_, _ = w.Write([]byte(msg.Message))
+	})
+	go func() {
+		_ = fcgi.Serve(l, handler)
+	}()
+}
+
+// closingListener keep the accepted connections to close them like one server closing the idle connections.
+type closingListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *closingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *closingListener) closeConns() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+	l.conns = nil
+}
+
+func Test_fastcgiRunner_Process(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fastcgi")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "php-fpm.sock")
+	tcp := startFastCGIServer(t, "tcp", "127.0.0.1:0")
+	defer tcp.Close()
+	unix := startFastCGIServer(t, "unix", socket)
+	defer unix.Close()
+
+	type wants struct {
+		exitCode int
+		err      string
+	}
+	tests := []struct {
+		name           string
+		url            string
+		scriptFilename string
+		body           []byte
+		wants          wants
+	}{
+		{
+			"200 with response-code using tcp",
+			"tcp://" + tcp.Addr().String(),
+			"/var/www/consumer.php",
+			[]byte(`{"code":200, "message": {"response-code":0}}`),
+			wants{ExitACK, ""},
+		},
+		{
+			"200 with response-code using unix socket",
+			"unix://" + socket,
+			"/var/www/consumer.php",
+			[]byte(`{"code":200, "message": {"response-code":3}}`),
+			wants{ExitNACK, ""},
+		},
+		{
+			"Script not found should NACK and requeue",
+			"tcp://" + tcp.Addr().String(),
+			"/var/www/not-found.php",
+			[]byte(`{"code":200, "message": {"response-code":0}}`),
+			wants{ExitNACKRequeue, "receive an 4xx error from request"},
+		},
+		{
+			"5xx should use return-on-5xx",
+			"tcp://" + tcp.Addr().String(),
+			"/var/www/consumer.php",
+			[]byte(`{"code":503, "message": {"error": "PHP Exception :p"}}`),
+			wants{ExitNACKRequeue, "receive an 5xx error from request"},
+		},
+		{
+			"request with timeout",
+			"tcp://" + tcp.Addr().String(),
+			"/var/www/consumer.php",
+			[]byte(`{"sleep": 2000000000, "code":200, "message": {"response-code":0}}`),
+			wants{ExitTimeout, "i/o timeout"},
+		},
+	}
+	for _, tt := range tests {
+		ctt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Type:    "fastcgi",
+				Timeout: 500 * time.Millisecond,
+				Options: Options{URL: ctt.url, ScriptFilename: ctt.scriptFilename, Connections: 2},
+			}
+			require.NoError(t, defaults.Set(&config))
+			runner, err := New(config, hub.New())
+			require.NoError(t, err)
+			// run twice to reuse the pooled connection
+			for i := 0; i < 2; i++ {
+				got, err := runner.Process(context.Background(), Message{
+					Body:    ctt.body,
+					Headers: Headers{"Message-Id": "12345", "Content-Type": "application/json"},
+				})
+				if len(ctt.wants.err) > 0 {
+					require.Error(t, err)
+					require.Contains(t, err.Error(), ctt.wants.err)
+				} else {
+					require.NoError(t, err)
+				}
+				require.Equal(t, ctt.wants.exitCode, got, "result fastcgiRunner.Process() differs")
+			}
+		})
+	}
+}
+
+func Test_fastcgiRunner_params(t *testing.T) {
+	f, err := newFastCGI(Config{Options: Options{
+		URL:            "unix:///var/run/php-fpm.sock",
+		ScriptFilename: "/var/www/consumer.php",
+		Headers:        map[string]string{"Authorization": "Basic from config"},
+	}}, hub.New())
+	require.NoError(t, err)
+	require.Equal(t, "unix", f.network)
+	require.Equal(t, "/var/run/php-fpm.sock", f.address)
+	params := f.params(Message{
+		Body: []byte(`{}`),
+		Headers: Headers{
+			"Message-Id":     "12345",
+			"Correlation-Id": "abc",
+			"Content-Type":   "application/json",
+			"Message-Deaths": int64(2),
+		},
+	})
+	require.Equal(t, "/var/www/consumer.php", params["SCRIPT_FILENAME"])
+	require.Equal(t, "POST", params["REQUEST_METHOD"])
+	require.Equal(t, "2", params["CONTENT_LENGTH"])
+	require.Equal(t, "application/json", params["CONTENT_TYPE"])
+	require.Equal(t, "12345", params["HTTP_MESSAGE_ID"])
+	require.Equal(t, "abc", params["HTTP_CORRELATION_ID"])
+	require.Equal(t, "2", params["HTTP_MESSAGE_DEATHS"])
+	require.Equal(t, "Basic from config", params["HTTP_AUTHORIZATION"])
+}
+
+func Test_fastcgiRunner_Process_closedConnection(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l := &closingListener{Listener: tcp}
+	defer l.Close()
+	serveFastCGI(l)
+	config := Config{
+		Type:    "fastcgi",
+		Timeout: 500 * time.Millisecond,
+		Options: Options{URL: "tcp://" + tcp.Addr().String(), ScriptFilename: "/var/www/consumer.php", Connections: 1},
+	}
+	require.NoError(t, defaults.Set(&config))
+	runner, err := New(config, hub.New())
+	require.NoError(t, err)
+	msg := Message{Body: []byte(`{"code":200, "message": {"response-code":0}}`)}
+	for i := 0; i < 3; i++ {
+		got, err := runner.Process(context.Background(), msg)
+		require.NoError(t, err, "request %d", i)
+		require.Equal(t, ExitACK, got)
+		// the pooled connection is closed by the server before the next request
+		l.closeConns()
+	}
+}
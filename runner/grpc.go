@@ -0,0 +1,176 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/runner/pb"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcRunner call the Process rpc defined in pb/runner.proto for every message.
+type grpcRunner struct {
+	conn     *grpc.ClientConn
+	client   pb.RunnerClient
+	metadata metadata.MD
+	timeout  time.Duration
+	hub      *hub.Hub
+	url      string
+	cancel   context.CancelFunc
+}
+
+func (g *grpcRunner) Process(ctx context.Context, msg Message) (int, error) {
+	if _, ok := ctx.Deadline(); !ok && g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+	ctx = metadata.NewOutgoingContext(ctx, g.metadata)
+	result, err := g.client.Process(ctx, &pb.Message{Body: msg.Body, Headers: toProtoHeaders(msg.Headers)})
+	if err != nil {
+		st := status.Convert(err)
+		if st.Code() == codes.DeadlineExceeded {
+			return ExitTimeout, &Error{Err: err, StatusCode: -1}
+		}
+		return ExitNACKRequeue, &Error{
+			Err:        errors.Wrap(err, "failed doing the request"),
+			StatusCode: int(st.Code()),
+		}
+	}
+	code := int(result.GetExitCode())
+	if len(result.GetError()) > 0 {
+		return code, &Error{
+			Err:        errors.New(result.GetError()),
+			StatusCode: code,
+			Output:     []byte(result.GetTrace()),
+		}
+	}
+	return code, nil
+}
+
+// Close the grpc connection.
+func (g *grpcRunner) Close() error {
+	g.cancel()
+	return g.conn.Close()
+}
+
+// watchState publish the connection state changes until the runner is closed.
+func (g *grpcRunner) watchState(ctx context.Context) {
+	state := g.conn.GetState()
+	for g.conn.WaitForStateChange(ctx, state) {
+		state = g.conn.GetState()
+		level := "info"
+		if state == connectivity.TransientFailure {
+			level = "warning"
+		}
+		g.hub.Publish(hub.Message{
+			Name:   "runner.grpc." + level,
+			Body:   []byte("grpc connection state changed"),
+			Fields: hub.Fields{"url": g.url, "state": state.String()},
+		})
+	}
+}
+
+func toProtoHeaders(headers Headers) map[string]*pb.HeaderValue {
+	ph := make(map[string]*pb.HeaderValue, len(headers))
+	for k, v := range headers {
+		var value *pb.HeaderValue
+		switch vt := v.(type) {
+		case string:
+			value = &pb.HeaderValue{Value: &pb.HeaderValue_StringValue{StringValue: vt}}
+		case int:
+			value = &pb.HeaderValue{Value: &pb.HeaderValue_IntValue{IntValue: int64(vt)}}
+		case int16:
+			value = &pb.HeaderValue{Value: &pb.HeaderValue_IntValue{IntValue: int64(vt)}}
+		case int32:
+			value = &pb.HeaderValue{Value: &pb.HeaderValue_IntValue{IntValue: int64(vt)}}
+		case int64:
+			value = &pb.HeaderValue{Value: &pb.HeaderValue_IntValue{IntValue: vt}}
+		case float32:
+			value = &pb.HeaderValue{Value: &pb.HeaderValue_DoubleValue{DoubleValue: float64(vt)}}
+		case float64:
+			value = &pb.HeaderValue{Value: &pb.HeaderValue_DoubleValue{DoubleValue: vt}}
+		case bool:
+			value = &pb.HeaderValue{Value: &pb.HeaderValue_BoolValue{BoolValue: vt}}
+		case []byte:
+			value = &pb.HeaderValue{Value: &pb.HeaderValue_BytesValue{BytesValue: vt}}
+		case time.Time:
+			value = &pb.HeaderValue{Value: &pb.HeaderValue_TimeValue{TimeValue: timestamppb.New(vt)}}
+		default:
+			continue
+		}
+		ph[k] = value
+	}
+	return ph
+}
+
+func grpcCredentials(c TLSOptions) (credentials.TransportCredentials, error) {
+	if !c.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+	cfg := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if len(c.CAFile) > 0 {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the ca file")
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("no certificates found in the ca file %s", c.CAFile)
+		}
+	}
+	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load the client certificate")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+func newGRPC(c Config, h *hub.Hub) (*grpcRunner, error) {
+	if len(c.Options.URL) == 0 {
+		return nil, errors.New("the grpc runner requires the url option")
+	}
+	creds, err := grpcCredentials(c.Options.TLS)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.NewClient(c.Options.URL, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the grpc client for \"%s\"", c.Options.URL)
+	}
+	md := metadata.MD{}
+	for k, v := range c.Options.Headers {
+		md.Set(strings.ToLower(k), v)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &grpcRunner{
+		conn:     conn,
+		client:   pb.NewRunnerClient(conn),
+		metadata: md,
+		timeout:  c.Timeout,
+		hub:      h,
+		url:      c.Options.URL,
+		cancel:   cancel,
+	}
+	go g.watchState(ctx)
+	return g, nil
+}
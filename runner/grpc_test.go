@@ -0,0 +1,157 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/leandro-lugaresi/message-cannon/runner/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type stubRunnerServer struct {
+	pb.UnimplementedRunnerServer
+}
+
+func (s *stubRunnerServer) Process(ctx context.Context, msg *pb.Message) (*pb.Result, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if len(md.Get("authorization")) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization")
+	}
+	if msg.GetHeaders()["Message-Id"].GetStringValue() != "12345" {
+		return nil, status.Error(codes.InvalidArgument, "missing Message-Id header")
+	}
+	body := struct {
+		ExitCode int32  `json:"exitcode"`
+		Delay    int    `json:"delay"`
+		Error    string `json:"error"`
+		Trace    string `json:"trace"`
+	}{}
+	if err := json.Unmarshal(msg.GetBody(), &body); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	select {
+	case <-time.After(time.Duration(body.Delay) * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &pb.Result{ExitCode: body.ExitCode, Error: body.Error, Trace: body.Trace}, nil
+}
+
+func Test_grpcRunner_Process(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	pb.RegisterRunnerServer(server, &stubRunnerServer{})
+	go func() {
+		_ = server.Serve(l)
+	}()
+	defer server.Stop()
+
+	type wants struct {
+		exitCode int
+		err      string
+		output   string
+	}
+	tests := []struct {
+		name  string
+		body  []byte
+		wants wants
+	}{
+		{
+			"Process with success",
+			[]byte(`{"exitcode": 0}`),
+			wants{ExitACK, "", ""},
+		},
+		{
+			"Process returning an exit code",
+			[]byte(`{"exitcode": 3}`),
+			wants{ExitNACK, "", ""},
+		},
+		{
+			"Process returning an error with trace",
+			[]byte(`{"exitcode": 1, "error": "Something is wrong :o", "trace": "#0 main.php(12)"}`),
+			wants{ExitFailed, "Something is wrong :o", "#0 main.php(12)"},
+		},
+		{
+			"Process with an rpc error",
+			[]byte(`invalid json`),
+			wants{ExitNACKRequeue, "code = InvalidArgument", ""},
+		},
+		{
+			"Process with timeout",
+			[]byte(`{"exitcode": 0, "delay": 2000}`),
+			wants{ExitTimeout, "code = DeadlineExceeded", ""},
+		},
+	}
+	h := hub.New()
+	sub := h.Subscribe(10, "runner.grpc.*")
+	r, err := New(Config{
+		Type:    "grpc",
+		Timeout: 200 * time.Millisecond,
+		Options: Options{
+			URL:     l.Addr().String(),
+			Headers: map[string]string{"Authorization": "Bearer token"},
+		},
+	}, h)
+	require.NoError(t, err)
+	defer r.(*grpcRunner).Close()
+	for _, tt := range tests {
+		ctt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Process(context.Background(), Message{
+				Body:    ctt.body,
+				Headers: Headers{"Message-Id": "12345", "Message-Deaths": int64(1)},
+			})
+			if len(ctt.wants.err) > 0 {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), ctt.wants.err)
+				require.Equal(t, ctt.wants.output, string(err.(*Error).Output))
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, ctt.wants.exitCode, got, "result grpcRunner.Process() differs")
+		})
+	}
+	// the connection state changes are published
+	states := []string{}
+	for len(states) == 0 || states[len(states)-1] != "READY" {
+		select {
+		case msg := <-sub.Receiver:
+			require.Equal(t, "runner.grpc.info", msg.Name)
+			require.Equal(t, l.Addr().String(), msg.Fields["url"])
+			states = append(states, msg.Fields["state"].(string))
+		case <-time.After(time.Second):
+			t.Fatalf("the READY state was not published, got %v", states)
+		}
+	}
+}
+
+func Test_toProtoHeaders(t *testing.T) {
+	date := time.Date(2019, time.March, 7, 10, 30, 0, 0, time.UTC)
+	got := toProtoHeaders(Headers{
+		"string":  "foo",
+		"int16":   int16(1),
+		"int64":   int64(111),
+		"float32": float32(1.5),
+		"bool":    true,
+		"bytes":   []byte(`baz`),
+		"date":    date,
+		"invalid": struct{}{},
+	})
+	require.Len(t, got, 7)
+	require.Equal(t, "foo", got["string"].GetStringValue())
+	require.Equal(t, int64(1), got["int16"].GetIntValue())
+	require.Equal(t, int64(111), got["int64"].GetIntValue())
+	require.Equal(t, float64(1.5), got["float32"].GetDoubleValue())
+	require.True(t, got["bool"].GetBoolValue())
+	require.Equal(t, []byte(`baz`), got["bytes"].GetBytesValue())
+	require.Equal(t, date, got["date"].GetTimeValue().AsTime())
+}
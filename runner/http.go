@@ -22,46 +22,63 @@ type httpRunner struct {
 	url          string
 	headers      map[string]string
 	returnOn5xx  int
+	batchFormat  string
 }
 
 func (p *httpRunner) Process(ctx context.Context, msg Message) (int, error) {
+	status, _, err := p.ProcessOutput(ctx, msg)
+	return status, err
+}
+
+// ProcessOutput do the request and return the response body.
+func (p *httpRunner) ProcessOutput(ctx context.Context, msg Message) (int, []byte, error) {
 	req, err := p.prepareRequest(msg)
 	if err != nil {
-		return ExitNACKRequeue, errors.Wrap(err, "request creation failed")
+		return ExitNACKRequeue, nil, errors.Wrap(err, "request creation failed")
 	}
 	req = req.WithContext(ctx)
 	resp, body, err := p.executeRequest(req)
 	if err != nil {
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			return ExitTimeout, &Error{Err: netErr, StatusCode: -1}
+			return ExitTimeout, nil, &Error{Err: netErr, StatusCode: -1}
 		}
-		return ExitNACKRequeue, errors.Wrap(err, "failed doing the request")
+		return ExitNACKRequeue, nil, errors.Wrap(err, "failed doing the request")
 	}
-	if resp.StatusCode >= 500 {
-		return p.returnOn5xx, &Error{
+	status, err := handleResponse(resp.StatusCode, body, p.ignoreOutput, p.returnOn5xx)
+	if err != nil {
+		return status, nil, err
+	}
+	return status, body, nil
+}
+
+// handleResponse translate the status code and the response body into one exit code.
+// This is shared between all the runners talking with web servers (http, fastcgi).
+func handleResponse(statusCode int, body []byte, ignoreOutput bool, returnOn5xx int) (int, error) {
+	if statusCode >= 500 {
+		return returnOn5xx, &Error{
 			Err:        errors.New("receive an 5xx error from request"),
-			StatusCode: resp.StatusCode,
+			StatusCode: statusCode,
 			Output:     body,
 		}
 	}
-	if resp.StatusCode >= 400 {
+	if statusCode >= 400 {
 		return ExitNACKRequeue, &Error{
 			Err:        errors.New("receive an 4xx error from request"),
-			StatusCode: resp.StatusCode,
+			StatusCode: statusCode,
 			Output:     body,
 		}
 	}
-	if p.ignoreOutput {
+	if ignoreOutput {
 		return ExitACK, nil
 	}
 	content := struct {
 		ResponseCode int `json:"response-code"`
 	}{}
-	err = json.Unmarshal(body, &content)
+	err := json.Unmarshal(body, &content)
 	if err != nil && len(body) > 0 {
 		return ExitNACKRequeue, &Error{
 			Err:        err,
-			StatusCode: resp.StatusCode,
+			StatusCode: statusCode,
 			Output:     body,
 		}
 	}
@@ -83,21 +100,29 @@ func (p *httpRunner) setHeaders(req *http.Request, msg Message) {
 		req.Header.Set(k, v)
 	}
 	for k, v := range msg.Headers {
-		switch vt := v.(type) {
-		case int, int16, int32, int64, float32, float64:
-			req.Header.Set(k, fmt.Sprint(vt))
-		case string:
-			req.Header.Set(k, vt)
-		case []byte:
-			req.Header.Set(k, string(vt))
-		case time.Time:
-			req.Header.Set(k, vt.Format(http.TimeFormat))
-		case bool:
-			req.Header.Set(k, strconv.FormatBool(vt))
+		if value, ok := headerValue(v); ok {
+			req.Header.Set(k, value)
 		}
 	}
 }
 
+// headerValue convert one of the types supported by Headers into a string.
+func headerValue(v interface{}) (string, bool) {
+	switch vt := v.(type) {
+	case int, int16, int32, int64, float32, float64:
+		return fmt.Sprint(vt), true
+	case string:
+		return vt, true
+	case []byte:
+		return string(vt), true
+	case time.Time:
+		return vt.Format(http.TimeFormat), true
+	case bool:
+		return strconv.FormatBool(vt), true
+	}
+	return "", false
+}
+
 func (p *httpRunner) executeRequest(req *http.Request) (*http.Response, []byte, error) {
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -131,6 +156,7 @@ func newHTTP(c Config, h *hub.Hub) *httpRunner {
 		ignoreOutput: c.IgnoreOutput,
 		headers:      c.Options.Headers,
 		returnOn5xx:  c.Options.ReturnOn5xx,
+		batchFormat:  c.Options.BatchFormat,
 		client: &http.Client{
 			Timeout: c.Timeout,
 			Transport: &http.Transport{
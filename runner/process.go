@@ -0,0 +1,303 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/pkg/errors"
+)
+
+// processPool keeps long-lived processes and stream the messages to them using
+// newline-delimited JSON over the stdin/stdout of each process.
+type processPool struct {
+	cmd         string
+	args        []string
+	maxMessages int
+	maxMemory   int64
+	slots       chan *worker
+	done        chan struct{}
+	hub         *hub.Hub
+}
+
+type worker struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    *bufio.Reader
+	pipe      *os.File
+	exited    chan struct{}
+	processed int
+	dead      bool
+}
+
+type (
+	// processRequest is one message sent to the workers, the body is base64 encoded
+	// so the workers receive any content (json, text or binary) unchanged.
+	processRequest struct {
+		Headers Headers `json:"headers"`
+		Body    []byte  `json:"body"`
+	}
+
+	processResponse struct {
+		ResponseCode int    `json:"response-code"`
+		Error        string `json:"error"`
+	}
+
+	readResult struct {
+		line []byte
+		err  error
+	}
+)
+
+var errPoolClosed = errors.New("the process pool is closed")
+
+func (p *processPool) Process(ctx context.Context, msg Message) (int, error) {
+	var w *worker
+	select {
+	case w = <-p.slots:
+	case <-p.done:
+		return ExitNACKRequeue, errPoolClosed
+	case <-ctx.Done():
+		return ExitTimeout, &Error{Err: ctx.Err(), StatusCode: -1}
+	}
+	if w == nil {
+		var err error
+		w, err = p.start()
+		if err != nil {
+			p.slots <- nil
+			return ExitNACKRequeue, errors.Wrap(err, "failed to start the process")
+		}
+	}
+	status, err := p.send(ctx, w, msg)
+	if w.dead {
+		w = nil
+	} else if p.exhausted(w) {
+		p.stop(w)
+		w = nil
+	}
+	p.slots <- w
+	return status, err
+}
+
+// Close stop all the processes started by this pool.
+// Messages in flight will be processed before the process is stopped.
+func (p *processPool) Close() error {
+	close(p.done)
+	for i := 0; i < cap(p.slots); i++ {
+		if w := <-p.slots; w != nil {
+			p.stop(w)
+		}
+	}
+	return nil
+}
+
+func (p *processPool) send(ctx context.Context, w *worker, msg Message) (int, error) {
+	b, err := json.Marshal(processRequest{Headers: msg.Headers, Body: msg.Body})
+	if err != nil {
+		return ExitNACKRequeue, errors.Wrap(err, "failed to encode the message")
+	}
+	result := make(chan readResult, 1)
+	go func() {
+		line, readErr := w.stdout.ReadBytes('\n')
+		result <- readResult{line, readErr}
+	}()
+	// the write blocks when the process stop reading the stdin, so it also respects the ctx.
+	written := make(chan error, 1)
+	go func() {
+		_, writeErr := w.stdin.Write(append(b, '\n'))
+		written <- writeErr
+	}()
+	select {
+	case <-ctx.Done():
+		p.kill(w)
+		return ExitTimeout, &Error{Err: ctx.Err(), StatusCode: -1}
+	case err = <-written:
+	}
+	if err != nil {
+		p.kill(w)
+		return ExitNACKRequeue, errors.Wrap(err, "failed writing to stdin")
+	}
+	select {
+	case <-ctx.Done():
+		p.kill(w)
+		return ExitTimeout, &Error{Err: ctx.Err(), StatusCode: -1}
+	case r := <-result:
+		w.processed++
+		if r.err != nil {
+			p.kill(w)
+			return ExitNACKRequeue, &Error{
+				Err:        errors.Wrap(r.err, "the process exited unexpectedly"),
+				StatusCode: exitCode(w.cmd),
+				Output:     r.line,
+			}
+		}
+		resp := processResponse{}
+		if err = json.Unmarshal(r.line, &resp); err != nil {
+			return ExitNACKRequeue, &Error{Err: err, StatusCode: -1, Output: r.line}
+		}
+		if len(resp.Error) > 0 {
+			return resp.ResponseCode, &Error{
+				Err:        errors.New(resp.Error),
+				StatusCode: resp.ResponseCode,
+				Output:     r.line,
+			}
+		}
+		return resp.ResponseCode, nil
+	}
+}
+
+func (p *processPool) start() (*worker, error) {
+	cmd := exec.Command(p.cmd, p.args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "open pipe to stdin failed")
+	}
+	// The stdout pipe is handled by us instead of cmd.StdoutPipe because Wait closes
+	// the pipe and we could lose the last message written by one process that crashed.
+	stdout, pw, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "open pipe to stdout failed")
+	}
+	cmd.Stdout = pw
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "open pipe to stderr failed")
+	}
+	err = cmd.Start()
+	_ = pw.Close()
+	if err != nil {
+		_ = stdout.Close()
+		return nil, err
+	}
+	w := &worker{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		pipe:   stdout,
+		exited: make(chan struct{}),
+	}
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			p.hub.Publish(hub.Message{
+				Name:   "runner.process.warning",
+				Body:   []byte("process wrote to stderr"),
+				Fields: hub.Fields{"pid": cmd.Process.Pid, "output": scanner.Text()},
+			})
+		}
+		// Wait must be called after all the reads from the pipes are completed.
+		_ = cmd.Wait()
+		close(w.exited)
+	}()
+	p.hub.Publish(hub.Message{
+		Name:   "runner.process.info",
+		Body:   []byte("process started"),
+		Fields: hub.Fields{"pid": cmd.Process.Pid, "command": p.cmd},
+	})
+	return w, nil
+}
+
+// exhausted check if the process reached the max-messages or max-memory limits.
+func (p *processPool) exhausted(w *worker) bool {
+	if p.maxMessages > 0 && w.processed >= p.maxMessages {
+		return true
+	}
+	if p.maxMemory > 0 {
+		rss, err := processRSS(w.cmd.Process.Pid)
+		return err == nil && rss >= p.maxMemory
+	}
+	return false
+}
+
+// stop close the stdin and wait the process to finish.
+func (p *processPool) stop(w *worker) {
+	if err := w.stdin.Close(); err != nil {
+		p.kill(w)
+		return
+	}
+	<-w.exited
+	_ = w.pipe.Close()
+	p.hub.Publish(hub.Message{
+		Name:   "runner.process.info",
+		Body:   []byte("process stopped"),
+		Fields: hub.Fields{"pid": w.cmd.Process.Pid, "processed": w.processed},
+	})
+}
+
+func (p *processPool) kill(w *worker) {
+	w.dead = true
+	err := w.cmd.Process.Kill()
+	if err != nil && err != os.ErrProcessDone {
+		p.hub.Publish(hub.Message{
+			Name:   "runner.process.error",
+			Body:   []byte("failed to kill the process"),
+			Fields: hub.Fields{"pid": w.cmd.Process.Pid, "error": err},
+		})
+	}
+	<-w.exited
+	_ = w.pipe.Close()
+	p.hub.Publish(hub.Message{
+		Name:   "runner.process.warning",
+		Body:   []byte("process killed"),
+		Fields: hub.Fields{"pid": w.cmd.Process.Pid, "processed": w.processed},
+	})
+}
+
+func exitCode(cmd *exec.Cmd) int {
+	if cmd.ProcessState == nil {
+		return -1
+	}
+	return cmd.ProcessState.ExitCode()
+}
+
+// processRSS return the resident set size in bytes of one process.
+func processRSS(pid int) (int64, error) {
+	b, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/statm")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) < 2 {
+		return 0, errors.Errorf("unexpected statm content: %s", b)
+	}
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return pages * int64(os.Getpagesize()), nil
+}
+
+func newProcessPool(c Config, h *hub.Hub) (*processPool, error) {
+	if split := strings.Split(c.Options.Path, " "); len(split) > 1 {
+		c.Options.Path = split[0]
+		c.Options.Args = append(split[1:], c.Options.Args...)
+	}
+	if _, err := os.Stat(c.Options.Path); os.IsNotExist(err) {
+		return nil, errors.Errorf("The command %s didn't exist", c.Options.Path)
+	}
+	processes := c.Options.Processes
+	if processes < 1 {
+		processes = 1
+	}
+	p := &processPool{
+		cmd:         c.Options.Path,
+		args:        c.Options.Args,
+		maxMessages: c.Options.MaxMessages,
+		maxMemory:   c.Options.MaxMemory,
+		slots:       make(chan *worker, processes),
+		done:        make(chan struct{}),
+		hub:         h,
+	}
+	// processes are started lazily when the first message arrives.
+	for i := 0; i < processes; i++ {
+		p.slots <- nil
+	}
+	return p, nil
+}
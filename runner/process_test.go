@@ -0,0 +1,177 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHelperWorker isn't a real test. It's used as a long-lived process by the process-pool tests.
+func TestHelperWorker(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_WORKER") != "1" {
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		req := processRequest{}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+		body := struct {
+			ExitCode int    `json:"exitcode"`
+			Delay    int    `json:"delay"`
+			Crash    bool   `json:"crash"`
+			Error    string `json:"error"`
+		}{}
+		if err := json.Unmarshal(req.Body, &body); err != nil {
+			os.Exit(2)
+		}
+		time.Sleep(time.Duration(body.Delay) * time.Millisecond)
+		if body.Crash {
+			os.Exit(255)
+		}
+		b, _ := json.Marshal(processResponse{ResponseCode: body.ExitCode, Error: body.Error})
+		fmt.Println(string(b))
+	}
+	os.Exit(0)
+}
+
+func newTestProcessPool(t *testing.T, processes, maxMessages int) *processPool {
+	require.NoError(t, os.Setenv("GO_WANT_HELPER_WORKER", "1"))
+	p, err := newProcessPool(Config{
+		Type: "process-pool",
+		Options: Options{
+			Path:        os.Args[0],
+			Args:        []string{"-test.run=TestHelperWorker"},
+			Processes:   processes,
+			MaxMessages: maxMessages,
+		},
+	}, hub.New())
+	require.NoError(t, err)
+	return p
+}
+
+func Test_processPool_Process(t *testing.T) {
+	type (
+		args struct {
+			b       []byte
+			timeout bool
+		}
+		wants struct {
+			exitCode int
+			err      string
+		}
+	)
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			"Process with success",
+			args{[]byte(`{"exitcode": 0, "delay": 10}`), false},
+			wants{ExitACK, ""},
+		},
+		{
+			"Process returning a response-code",
+			args{[]byte(`{"exitcode": 3}`), false},
+			wants{ExitNACK, ""},
+		},
+		{
+			"Process returning an error",
+			args{[]byte(`{"exitcode": 1, "error": "Something is wrong :o"}`), false},
+			wants{ExitFailed, "Something is wrong :o"},
+		},
+		{
+			"Process crashing",
+			args{[]byte(`{"crash": true}`), false},
+			wants{ExitNACKRequeue, "the process exited unexpectedly: EOF"},
+		},
+		{
+			"Process with timeout",
+			args{[]byte(`{"exitcode": 0, "delay": 2000}`), true},
+			wants{ExitTimeout, "context deadline exceeded"},
+		},
+	}
+	p := newTestProcessPool(t, 1, 0)
+	defer p.Close()
+	for _, tt := range tests {
+		ctt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if ctt.args.timeout {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, 100*time.Millisecond)
+				defer cancel()
+			}
+			exitCode, err := p.Process(ctx, Message{Body: ctt.args.b, Headers: Headers{"Message-Id": "123"}})
+			if len(ctt.wants.err) > 0 {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), ctt.wants.err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, ctt.wants.exitCode, exitCode, "processPool.Process wrong return value")
+		})
+	}
+}
+
+func Test_processPool_restarts(t *testing.T) {
+	p := newTestProcessPool(t, 1, 2)
+	defer p.Close()
+	pids := []int{}
+	for i := 0; i < 5; i++ {
+		w := <-p.slots
+		if w != nil {
+			pids = append(pids, w.cmd.Process.Pid)
+		}
+		p.slots <- w
+		exitCode, err := p.Process(context.Background(), Message{Body: []byte(`{"exitcode": 0}`)})
+		require.NoError(t, err)
+		require.Equal(t, ExitACK, exitCode)
+	}
+	// The first process handle messages 1 and 2, the second 3 and 4.
+	require.Len(t, pids, 2)
+	require.NotEqual(t, pids[0], pids[1], "the process should be restarted after max-messages")
+}
+
+func Test_processPool_Process_stdinBlocked(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	require.NoError(t, err)
+	p, err := newProcessPool(Config{
+		Type:    "process-pool",
+		Options: Options{Path: sleep, Args: []string{"10"}, Processes: 1},
+	}, hub.New())
+	require.NoError(t, err)
+	defer p.Close()
+	// the process never read the stdin and the message is bigger than the pipe buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	exitCode, err := p.Process(ctx, Message{Body: make([]byte, 1<<20)})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "context deadline exceeded")
+	require.Equal(t, ExitTimeout, exitCode)
+	require.Less(t, time.Since(start), 2*time.Second)
+	// the process was killed and will be started again
+	require.Nil(t, <-p.slots)
+	p.slots <- nil
+}
+
+func Test_processRequest_binaryBody(t *testing.T) {
+	body := []byte{0xff, 0x00, '\n', 0xfe}
+	b, err := json.Marshal(processRequest{Headers: Headers{"Message-Id": "1"}, Body: body})
+	require.NoError(t, err)
+	require.Equal(t, `{"headers":{"Message-Id":"1"},"body":"/wAK/g=="}`, string(b))
+	req := processRequest{}
+	require.NoError(t, json.Unmarshal(b, &req))
+	require.Equal(t, body, req.Body)
+}
@@ -2,6 +2,7 @@ package runner
 
 import (
 	"context"
+	"io"
 	"strings"
 	"time"
 
@@ -46,16 +47,55 @@ type (
 		Process(context.Context, Message) (int, error)
 	}
 
+	// OutputRunnable is implemented by the runners able to return what they produced:
+	// the stdout for commands and the response body for http and fastcgi.
+	OutputRunnable interface {
+		ProcessOutput(context.Context, Message) (int, []byte, error)
+	}
+
+	// BatchRunnable is implemented by the runners able to process many messages in one call.
+	// The results are returned in the same order of the messages.
+	BatchRunnable interface {
+		ProcessBatch(context.Context, []Message) []Result
+	}
+
+	// Result is the exit code and the error of one message processed in a batch.
+	Result struct {
+		Status int
+		Err    error
+	}
+
 	// Options is a composition os all options used internally by runners.
 	// options not needed by one runner will be ignored.
 	Options struct {
 		// Command options
 		Path string   `mapstructure:"path"`
 		Args []string `mapstructure:"args"`
+		// Process pool options
+		Processes   int   `mapstructure:"processes" default:"1"`
+		MaxMessages int   `mapstructure:"max-messages"`
+		MaxMemory   int64 `mapstructure:"max-memory"`
 		// HTTP options
 		URL         string            `mapstructure:"url"`
 		ReturnOn5xx int               `mapstructure:"return-on-5xx" default:"4"`
 		Headers     map[string]string `mapstructure:"headers" default:"{}"`
+		// BatchFormat is how the batches are encoded: json (one array) or ndjson (one message per line).
+		BatchFormat string `mapstructure:"batch-format" default:"json"`
+		// FastCGI options (also uses URL, ReturnOn5xx and Headers)
+		ScriptFilename string `mapstructure:"script-filename"`
+		Connections    int    `mapstructure:"connections"`
+		// gRPC options (also uses URL and Headers as metadata)
+		TLS TLSOptions `mapstructure:"tls"`
+	}
+
+	// TLSOptions describe how to open secure connections with the runner target.
+	TLSOptions struct {
+		Enabled            bool   `mapstructure:"enabled"`
+		CAFile             string `mapstructure:"ca-file"`
+		CertFile           string `mapstructure:"cert-file"`
+		KeyFile            string `mapstructure:"key-file"`
+		ServerName         string `mapstructure:"server-name"`
+		InsecureSkipVerify bool   `mapstructure:"insecure-skip-verify"`
 	}
 
 	// Config is an composition of options and configurations used by this runnables.
@@ -64,6 +104,8 @@ type (
 		IgnoreOutput bool          `mapstructure:"ignore-output"`
 		Options      Options       `mapstructure:"options"`
 		Timeout      time.Duration `mapstructure:"timeout"`
+		Circuit      CircuitConfig `mapstructure:"circuit"`
+		Tap          TapConfig     `mapstructure:"tap"`
 	}
 
 	// Error describes an error during the Process phase.
@@ -75,17 +117,69 @@ type (
 )
 
 // New create and return a Runnable based on the config type. if the type didn't exist an error is returned.
+// The runner is wrapped by a tap and a circuit breaker when configured,
+// the messages rejected by the open circuit are not recorded.
 func New(c Config, h *hub.Hub) (Runnable, error) {
+	r, err := newRunnable(c, h)
+	if err != nil {
+		return nil, err
+	}
+	if c.Tap.enabled() {
+		t, err := newTap(r, c.Tap, h)
+		if err != nil {
+			if closer, ok := r.(io.Closer); ok {
+				closer.Close()
+			}
+			return nil, err
+		}
+		r = t
+	}
+	if !c.Circuit.enabled() {
+		return r, nil
+	}
+	return newCircuitBreaker(r, c.Circuit, h), nil
+}
+
+// SetDefaults fill the options depending on the consumer using the runner:
+// the http User-Agent with the message-cannon version and one fastcgi connection for every worker.
+func (c *Config) SetDefaults(version string, workers int) {
+	if len(c.Options.Headers) == 0 {
+		c.Options.Headers = map[string]string{}
+	}
+	if _, exist := c.Options.Headers["User-Agent"]; c.Type == "http" && !exist {
+		c.Options.Headers["User-Agent"] = "message-cannon/" + version
+	}
+	if c.Type == "fastcgi" && c.Options.Connections == 0 {
+		c.Options.Connections = workers
+	}
+}
+
+func newRunnable(c Config, h *hub.Hub) (Runnable, error) {
 	switch c.Type {
 	case "command":
 		return newCommand(c, h)
 	case "http":
 		return newHTTP(c, h), nil
+	case "process-pool":
+		return newProcessPool(c, h)
+	case "fastcgi":
+		return newFastCGI(c, h)
+	case "grpc":
+		return newGRPC(c, h)
 	}
 	return nil, errors.Errorf(
 		"Invalid Runner type (\"%s\") expecting one of (%s)",
 		c.Type,
-		strings.Join([]string{"command", "http"}, ", "))
+		strings.Join([]string{"command", "http", "process-pool", "fastcgi", "grpc"}, ", "))
+}
+
+// ProcessOutput process the message returning the output when the runner supports it.
+func ProcessOutput(ctx context.Context, r Runnable, msg Message) (int, []byte, error) {
+	if or, ok := r.(OutputRunnable); ok {
+		return or.ProcessOutput(ctx, msg)
+	}
+	status, err := r.Process(ctx, msg)
+	return status, nil, err
 }
 
 func (e *Error) Error() string {
@@ -1,6 +1,9 @@
 package runner
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/leandro-lugaresi/hub"
@@ -20,7 +23,7 @@ func TestNew(t *testing.T) {
 			Config{Type: "invalid-c3"},
 			nil,
 			true,
-			"Invalid Runner type (\"invalid-c3\") expecting one of (command, http)",
+			"Invalid Runner type (\"invalid-c3\") expecting one of (command, http, process-pool, fastcgi, grpc)",
 		},
 		{
 			"With command type but with executable not found",
@@ -71,3 +74,60 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+type stubRunnable struct{}
+
+func (stubRunnable) Process(_ context.Context, _ Message) (int, error) {
+	return ExitNACK, nil
+}
+
+func TestProcessOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"response-code": 0, "result": 42}`))
+	}))
+	defer server.Close()
+	h := hub.New()
+	tests := []struct {
+		name     string
+		runner   Runnable
+		exitCode int
+		output   string
+	}{
+		{"http returns the response body", newHTTP(Config{Options: Options{URL: server.URL}}, h), ExitACK, `{"response-code": 0, "result": 42}`},
+		{"command returns the stdout", &command{cmd: "/bin/cat", hub: h}, ExitACK, `{"id": 1}`},
+		{"runners without output", stubRunnable{}, ExitNACK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, output, err := ProcessOutput(context.Background(), tt.runner, Message{Body: []byte(`{"id": 1}`)})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.exitCode, status)
+			assert.Equal(t, tt.output, string(output))
+		})
+	}
+}
+
+func TestConfig_SetDefaults(t *testing.T) {
+	tests := []struct {
+		name        string
+		c           Config
+		headers     map[string]string
+		connections int
+	}{
+		{"http receive the user agent", Config{Type: "http"}, map[string]string{"User-Agent": "message-cannon/1.0.0"}, 0},
+		{"http keep the user agent from the config",
+			Config{Type: "http", Options: Options{Headers: map[string]string{"User-Agent": "custom"}}},
+			map[string]string{"User-Agent": "custom"}, 0},
+		{"fastcgi use one connection for every worker", Config{Type: "fastcgi"}, map[string]string{}, 4},
+		{"fastcgi keep the connections from the config", Config{Type: "fastcgi", Options: Options{Connections: 2}}, map[string]string{}, 2},
+		{"command only receive the headers map", Config{Type: "command"}, map[string]string{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.c
+			c.SetDefaults("1.0.0", 4)
+			assert.Equal(t, tt.headers, c.Options.Headers)
+			assert.Equal(t, tt.connections, c.Options.Connections)
+		})
+	}
+}
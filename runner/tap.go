@@ -0,0 +1,363 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/pkg/errors"
+)
+
+type (
+	// TapConfig record every message processed by the runner into local files,
+	// so the messages can be processed again by the replay command. The tap is disabled without a path.
+	TapConfig struct {
+		Path string `mapstructure:"path"`
+		// Format is ndjson (one json record per line) or binary (gob encoded records).
+		// The binary files can't be appended, so they are rotated every time the consumer starts.
+		Format string `mapstructure:"format" default:"ndjson"`
+		// MaxSize is the file size in bytes before rotating, the old files are renamed to path.1, path.2...
+		MaxSize  int64 `mapstructure:"max-size" default:"104857600"`
+		MaxFiles int   `mapstructure:"max-files" default:"5"`
+	}
+
+	// Record is one message processed by the runner.
+	// The headers keep their types, including the AMQP properties sent by the rabbitMQ consumer
+	// (Content-Type, Correlation-Id, Message-Id, Reply-To, Timestamp and Delivery-Mode).
+	Record struct {
+		Time     time.Time        `json:"time"`
+		Headers  map[string]Value `json:"headers"`
+		Body     []byte           `json:"body"`
+		Status   int              `json:"status"`
+		Duration time.Duration    `json:"duration"`
+		Error    string           `json:"error,omitempty"`
+	}
+
+	// Value is one header value saved as text with the original type.
+	Value struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+)
+
+func (c TapConfig) enabled() bool {
+	return len(c.Path) > 0
+}
+
+// tap wrap one runner writing one record for every message processed.
+// The write errors are published on the hub and never change the message result.
+type tap struct {
+	runner Runnable
+	hub    *hub.Hub
+	writer *tapWriter
+}
+
+func newTap(r Runnable, cfg TapConfig, h *hub.Hub) (*tap, error) {
+	w, err := newTapWriter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &tap{runner: r, hub: h, writer: w}, nil
+}
+
+func (t *tap) Process(ctx context.Context, msg Message) (int, error) {
+	status, _, err := t.ProcessOutput(ctx, msg)
+	return status, err
+}
+
+func (t *tap) ProcessOutput(ctx context.Context, msg Message) (int, []byte, error) {
+	start := time.Now()
+	status, out, err := ProcessOutput(ctx, t.runner, msg)
+	t.record(newRecord(start, msg, status, err))
+	return status, out, err
+}
+
+func (t *tap) ProcessBatch(ctx context.Context, msgs []Message) []Result {
+	start := time.Now()
+	results := ProcessBatch(ctx, t.runner, msgs)
+	for i, msg := range msgs {
+		t.record(newRecord(start, msg, results[i].Status, results[i].Err))
+	}
+	return results
+}
+
+// Close the tap file and the wrapped runner.
+func (t *tap) Close() error {
+	err := t.writer.Close()
+	if closer, ok := t.runner.(io.Closer); ok {
+		if cerr := closer.Close(); cerr != nil {
+			return cerr
+		}
+	}
+	return err
+}
+
+func (t *tap) record(r Record) {
+	if err := t.writer.Write(r); err != nil {
+		t.hub.Publish(hub.Message{
+			Name:   "runner.tap.error",
+			Body:   []byte("failed to record the message"),
+			Fields: hub.Fields{"error": err, "path": t.writer.cfg.Path},
+		})
+	}
+}
+
+func newRecord(start time.Time, msg Message, status int, err error) Record {
+	r := Record{
+		Time:     start,
+		Headers:  make(map[string]Value, len(msg.Headers)),
+		Body:     msg.Body,
+		Status:   status,
+		Duration: time.Since(start),
+	}
+	for k, v := range msg.Headers {
+		if value, ok := newValue(v); ok {
+			r.Headers[k] = value
+		}
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// Message return the message recorded, used to process it again.
+func (r Record) Message() Message {
+	headers := make(Headers, len(r.Headers))
+	for k, v := range r.Headers {
+		headers[k] = v.Interface()
+	}
+	return Message{Body: r.Body, Headers: headers}
+}
+
+// newValue convert one of the types supported by Headers, the others are not recorded.
+func newValue(v interface{}) (Value, bool) {
+	switch vt := v.(type) {
+	case int, int16, int32, int64:
+		return Value{fmt.Sprintf("%T", vt), fmt.Sprint(vt)}, true
+	case float32:
+		return Value{"float32", strconv.FormatFloat(float64(vt), 'g', -1, 32)}, true
+	case float64:
+		return Value{"float64", strconv.FormatFloat(vt, 'g', -1, 64)}, true
+	case string:
+		return Value{"string", vt}, true
+	case []byte:
+		return Value{"bytes", base64.StdEncoding.EncodeToString(vt)}, true
+	case time.Time:
+		return Value{"time", vt.Format(time.RFC3339Nano)}, true
+	case bool:
+		return Value{"bool", strconv.FormatBool(vt)}, true
+	}
+	return Value{}, false
+}
+
+// Interface return the value with the original type, the text is returned when it can't be parsed.
+func (v Value) Interface() interface{} {
+	var (
+		value interface{}
+		err   error
+	)
+	switch v.Type {
+	case "int":
+		value, err = strconv.Atoi(v.Value)
+	case "int16":
+		var n int64
+		n, err = strconv.ParseInt(v.Value, 10, 16)
+		value = int16(n)
+	case "int32":
+		var n int64
+		n, err = strconv.ParseInt(v.Value, 10, 32)
+		value = int32(n)
+	case "int64":
+		value, err = strconv.ParseInt(v.Value, 10, 64)
+	case "float32":
+		var n float64
+		n, err = strconv.ParseFloat(v.Value, 32)
+		value = float32(n)
+	case "float64":
+		value, err = strconv.ParseFloat(v.Value, 64)
+	case "bytes":
+		value, err = base64.StdEncoding.DecodeString(v.Value)
+	case "time":
+		value, err = time.Parse(time.RFC3339Nano, v.Value)
+	case "bool":
+		value, err = strconv.ParseBool(v.Value)
+	default:
+		return v.Value
+	}
+	if err != nil {
+		return v.Value
+	}
+	return value
+}
+
+// tapWriter write the records into one file rotated by size.
+type tapWriter struct {
+	cfg  TapConfig
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	enc  interface{ Encode(interface{}) error }
+	size int64
+}
+
+func newTapWriter(cfg TapConfig) (*tapWriter, error) {
+	w := &tapWriter{cfg: cfg}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open append to the current file, the binary format always start a new file because
+// the gob type information is written only once per stream. The files without records are reused.
+func (w *tapWriter) open() error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if w.cfg.Format == "binary" {
+		if info, err := os.Stat(w.cfg.Path); err == nil && info.Size() > int64(len(binaryMarker)) {
+			if err := w.shift(); err != nil {
+				return err
+			}
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+	f, err := os.OpenFile(w.cfg.Path, flags, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the tap file")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to open the tap file")
+	}
+	w.file = f
+	w.size = info.Size()
+	w.buf = bufio.NewWriter(countWriter{f, &w.size})
+	if w.cfg.Format == "binary" {
+		if _, err := w.buf.WriteString(binaryMarker); err != nil {
+			f.Close()
+			return errors.Wrap(err, "failed to write the tap file")
+		}
+		w.enc = gob.NewEncoder(w.buf)
+	} else {
+		w.enc = json.NewEncoder(w.buf)
+	}
+	return nil
+}
+
+// Write save one record, flushed right away so the records survive a crash.
+func (w *tapWriter) Write(r Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return errors.New("the tap file is closed")
+	}
+	if err := w.enc.Encode(r); err != nil {
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if w.cfg.MaxSize > 0 && w.size >= w.cfg.MaxSize {
+		return w.rotate()
+	}
+	return nil
+}
+
+func (w *tapWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	if err := w.shift(); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// shift rename the files path.N to path.N+1, removing the files after MaxFiles.
+func (w *tapWriter) shift() error {
+	if w.cfg.MaxFiles <= 1 {
+		return os.Remove(w.cfg.Path)
+	}
+	_ = os.Remove(fmt.Sprintf("%s.%d", w.cfg.Path, w.cfg.MaxFiles-1))
+	for n := w.cfg.MaxFiles - 2; n > 0; n-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", w.cfg.Path, n), fmt.Sprintf("%s.%d", w.cfg.Path, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(w.cfg.Path, w.cfg.Path+".1")
+}
+
+// Close flush and close the file.
+func (w *tapWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.buf.Flush()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	return err
+}
+
+type countWriter struct {
+	w    io.Writer
+	size *int64
+}
+
+func (c countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.size += int64(n)
+	return n, err
+}
+
+// binaryMarker start every binary tap file, the files without it are read as ndjson.
+const binaryMarker = "message-cannon tap gob\n"
+
+// ReadRecords read the records from one tap file of any format.
+func ReadRecords(path string, fn func(Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the tap file")
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	marker, err := r.Peek(len(binaryMarker))
+	if err != nil && err != io.EOF {
+		return errors.Wrap(err, "failed to read the tap file")
+	}
+	var dec interface{ Decode(interface{}) error }
+	if string(marker) == binaryMarker {
+		_, _ = r.Discard(len(binaryMarker))
+		dec = gob.NewDecoder(r)
+	} else {
+		dec = json.NewDecoder(r)
+	}
+	for n := 1; ; n++ {
+		record := Record{}
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read the record %d", n)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
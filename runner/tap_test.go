@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, path string) []Record {
+	records := []Record{}
+	err := ReadRecords(path, func(r Record) error {
+		records = append(records, r)
+		return nil
+	})
+	require.NoError(t, err)
+	return records
+}
+
+func TestTap(t *testing.T) {
+	for _, format := range []string{"ndjson", "binary"} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tap.log")
+			r := &failingRunner{}
+			tp, err := newTap(r, TapConfig{Path: path, Format: format, MaxSize: 1 << 20, MaxFiles: 2}, hub.New())
+			require.NoError(t, err)
+			ctx := context.Background()
+			sent := time.Date(2018, 2, 13, 17, 50, 26, 0, time.UTC)
+			msg := Message{Body: []byte(`{"id":1}`), Headers: Headers{
+				"Message-Id":    "m1",
+				"Retries":       2,
+				"Timestamp":     sent,
+				"Delivery-Mode": 2,
+				"x-score":       1.5,
+				"x-count":       int64(7),
+				"x-raw":         []byte{0xff, 0x00},
+				"x-flag":        true,
+				"x-ignored":     []string{"not supported"},
+			}}
+			status, err := tp.Process(ctx, msg)
+			require.NoError(t, err)
+			require.Equal(t, ExitACK, status)
+			r.set(true)
+			results := tp.ProcessBatch(ctx, []Message{{Body: []byte("a")}, {Body: []byte("b")}})
+			require.Len(t, results, 2)
+			require.Equal(t, ExitNACKRequeue, results[0].Status)
+			require.NoError(t, tp.Close())
+
+			records := readAll(t, path)
+			require.Len(t, records, 3)
+			require.Equal(t, `{"id":1}`, string(records[0].Body))
+			require.Equal(t, Value{"time", "2018-02-13T17:50:26Z"}, records[0].Headers["Timestamp"])
+			require.Equal(t, Value{"int", "2"}, records[0].Headers["Delivery-Mode"])
+			require.Equal(t, ExitACK, records[0].Status)
+			require.Empty(t, records[0].Error)
+			require.False(t, records[0].Time.IsZero())
+			require.Equal(t, "b", string(records[2].Body))
+			require.Equal(t, ExitNACKRequeue, records[2].Status)
+			require.Equal(t, "backend is down", records[2].Error)
+			// the replayed headers are the same sent to the runner
+			delete(msg.Headers, "x-ignored")
+			require.Equal(t, msg.Headers, records[0].Message().Headers)
+		})
+	}
+}
+
+func TestTap_rotate(t *testing.T) {
+	for _, format := range []string{"ndjson", "binary"} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tap.log")
+			w, err := newTapWriter(TapConfig{Path: path, Format: format, MaxSize: 200, MaxFiles: 3})
+			require.NoError(t, err)
+			for i := 0; i < 20; i++ {
+				require.NoError(t, w.Write(Record{Body: []byte(fmt.Sprintf("message %02d", i))}))
+			}
+			require.NoError(t, w.Close())
+
+			_, err = os.Stat(path + ".3")
+			require.True(t, os.IsNotExist(err), "only max-files must be kept")
+			old := readAll(t, path+".2")
+			previous := readAll(t, path+".1")
+			current := readAll(t, path)
+			require.NotEmpty(t, old)
+			require.NotEmpty(t, previous)
+			// the files keep the records in order
+			all := append(append(old, previous...), current...)
+			last := all[len(all)-1]
+			require.Equal(t, "message 19", string(last.Body))
+			for i := 1; i < len(all); i++ {
+				require.Less(t, string(all[i-1].Body), string(all[i].Body))
+			}
+		})
+	}
+}
+
+func TestValue_Interface_invalid(t *testing.T) {
+	require.Equal(t, "abc", Value{"int", "abc"}.Interface())
+	require.Equal(t, "abc", Value{"unknown", "abc"}.Interface())
+}
+
+func TestReadRecords_format(t *testing.T) {
+	dir := t.TempDir()
+	// one ndjson file not starting with the record
+	path := filepath.Join(dir, "tap.log")
+	require.NoError(t, os.WriteFile(path, []byte("\n  {\"body\":\"YQ==\",\"status\":1}\n"), 0600))
+	records := readAll(t, path)
+	require.Len(t, records, 1)
+	require.Equal(t, "a", string(records[0].Body))
+	// empty files
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+	require.Empty(t, readAll(t, path))
+	w, err := newTapWriter(TapConfig{Path: path, Format: "binary", MaxFiles: 2})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Empty(t, readAll(t, path))
+	// the file without records is not rotated
+	w, err = newTapWriter(TapConfig{Path: path, Format: "binary", MaxFiles: 2})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, err = os.Stat(path + ".1")
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestNew_withTap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tap.log")
+	r, err := New(Config{
+		Type:    "http",
+		Options: Options{URL: "http://localhost:8080"},
+		Tap:     TapConfig{Path: path, Format: "ndjson", MaxSize: 1 << 20, MaxFiles: 1},
+	}, hub.New())
+	require.NoError(t, err)
+	require.IsType(t, &tap{}, r)
+	require.NoError(t, r.(*tap).Close())
+}
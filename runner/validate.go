@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Validate check the config without creating the runner.
+// Every problem is returned with the path of the option, ie: "options.url: invalid url".
+func Validate(c Config) []error {
+	var errs []error
+	switch c.Type {
+	case "command", "process-pool":
+		if err := validateExecutable(c.Options.Path); err != nil {
+			errs = append(errs, errors.Wrap(err, "options.path"))
+		}
+	case "http":
+		u, err := url.Parse(c.Options.URL)
+		switch {
+		case err != nil:
+			errs = append(errs, errors.Wrap(err, "options.url"))
+		case u.Scheme != "http" && u.Scheme != "https":
+			errs = append(errs, errors.Errorf("options.url: invalid url \"%s\" expecting one of (http://, https://)", c.Options.URL))
+		case len(u.Host) == 0:
+			errs = append(errs, errors.Errorf("options.url: the url \"%s\" didn't have a host", c.Options.URL))
+		}
+		if f := c.Options.BatchFormat; len(f) > 0 && f != "json" && f != "ndjson" {
+			errs = append(errs, errors.Errorf("options.batch-format: invalid batch format \"%s\" expecting one of (json, ndjson)", f))
+		}
+	case "fastcgi":
+		u, err := url.Parse(c.Options.URL)
+		switch {
+		case err != nil:
+			errs = append(errs, errors.Wrap(err, "options.url"))
+		case u.Scheme != "tcp" && u.Scheme != "unix":
+			errs = append(errs, errors.Errorf("options.url: invalid fastcgi url \"%s\" expecting one of (tcp://, unix://)", c.Options.URL))
+		}
+		if len(c.Options.ScriptFilename) == 0 {
+			errs = append(errs, errors.New("options.script-filename: the fastcgi runner requires the script-filename option"))
+		}
+	case "grpc":
+		if len(c.Options.URL) == 0 {
+			errs = append(errs, errors.New("options.url: the grpc runner requires the url option"))
+		}
+		for path, file := range map[string]string{
+			"options.tls.ca-file":   c.Options.TLS.CAFile,
+			"options.tls.cert-file": c.Options.TLS.CertFile,
+			"options.tls.key-file":  c.Options.TLS.KeyFile,
+		} {
+			if _, err := os.Stat(file); len(file) > 0 && err != nil {
+				errs = append(errs, errors.Wrap(err, path))
+			}
+		}
+	default:
+		errs = append(errs, errors.Errorf(
+			"type: invalid Runner type (\"%s\") expecting one of (%s)",
+			c.Type,
+			strings.Join([]string{"command", "http", "process-pool", "fastcgi", "grpc"}, ", ")))
+	}
+	if c.Circuit.ConsecutiveFailures < 0 {
+		errs = append(errs, errors.Errorf("circuit.consecutive-failures: can't be negative (%d)", c.Circuit.ConsecutiveFailures))
+	}
+	if c.Circuit.ErrorRate < 0 || c.Circuit.ErrorRate > 1 {
+		errs = append(errs, errors.Errorf("circuit.error-rate: must be between 0 and 1 (%v)", c.Circuit.ErrorRate))
+	}
+	if c.Circuit.enabled() && c.Circuit.OpenTimeout <= 0 {
+		errs = append(errs, errors.Errorf("circuit.open-timeout: must be greater than zero (%s)", c.Circuit.OpenTimeout))
+	}
+	if c.Tap.enabled() {
+		if f := c.Tap.Format; f != "ndjson" && f != "binary" {
+			errs = append(errs, errors.Errorf("tap.format: invalid tap format \"%s\" expecting one of (ndjson, binary)", f))
+		}
+		if c.Tap.MaxSize <= 0 {
+			errs = append(errs, errors.Errorf("tap.max-size: must be greater than zero (%d)", c.Tap.MaxSize))
+		}
+		if c.Tap.MaxFiles <= 0 {
+			errs = append(errs, errors.Errorf("tap.max-files: must be greater than zero (%d)", c.Tap.MaxFiles))
+		}
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, errors.Errorf("timeout: the timeout can't be negative (%s)", c.Timeout))
+	}
+	return errs
+}
+
+// validateExecutable check if the first word of the path is an executable file.
+// Names without a slash are searched in the PATH like exec.Command does.
+func validateExecutable(path string) error {
+	cmd := strings.Split(path, " ")[0]
+	if len(cmd) == 0 {
+		return errors.New("the command is empty")
+	}
+	if !strings.Contains(cmd, "/") {
+		if _, err := exec.LookPath(cmd); err != nil {
+			return errors.Errorf("the command %s wasn't found in the PATH", cmd)
+		}
+		return nil
+	}
+	info, err := os.Stat(cmd)
+	if err != nil {
+		return errors.Errorf("the command %s didn't exist", cmd)
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return errors.Errorf("the command %s is not executable", cmd)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Config
+		want []string
+	}{
+		{
+			"With a valid command",
+			Config{Type: "command", Options: Options{Path: "testdata/receive.php -v"}},
+			nil,
+		},
+		{
+			"With a command not found",
+			Config{Type: "process-pool", Options: Options{Path: "/bin/fooo"}},
+			[]string{"options.path: the command /bin/fooo didn't exist"},
+		},
+		{
+			"With a command not executable",
+			Config{Type: "command", Options: Options{Path: "./validate.go"}},
+			[]string{"options.path: the command ./validate.go is not executable"},
+		},
+		{
+			"With a command in the PATH",
+			Config{Type: "command", Options: Options{Path: "sh -c true"}},
+			nil,
+		},
+		{
+			"With a command not in the PATH",
+			Config{Type: "command", Options: Options{Path: "fooo-cannon -v"}},
+			[]string{"options.path: the command fooo-cannon wasn't found in the PATH"},
+		},
+		{
+			"With a directory",
+			Config{Type: "command", Options: Options{Path: "./testdata"}},
+			[]string{"options.path: the command ./testdata is not executable"},
+		},
+		{
+			"With an http url without scheme",
+			Config{Type: "http", Options: Options{URL: "localhost:8080/foo"}},
+			[]string{`options.url: invalid url "localhost:8080/foo" expecting one of (http://, https://)`},
+		},
+		{
+			"With an invalid batch format",
+			Config{Type: "http", Options: Options{URL: "https://localhost:8080/foo", BatchFormat: "xml"}},
+			[]string{`options.batch-format: invalid batch format "xml" expecting one of (json, ndjson)`},
+		},
+		{
+			"With a valid http url",
+			Config{Type: "http", Options: Options{URL: "https://localhost:8080/foo"}},
+			nil,
+		},
+		{
+			"With a fastcgi without script",
+			Config{Type: "fastcgi", Options: Options{URL: "http://localhost:9000"}},
+			[]string{
+				`options.url: invalid fastcgi url "http://localhost:9000" expecting one of (tcp://, unix://)`,
+				"options.script-filename: the fastcgi runner requires the script-filename option",
+			},
+		},
+		{
+			"With a grpc without url and negative timeout",
+			Config{Type: "grpc", Timeout: -time.Second},
+			[]string{
+				"options.url: the grpc runner requires the url option",
+				"timeout: the timeout can't be negative (-1s)",
+			},
+		},
+		{
+			"With undefined type",
+			Config{Type: "invalid-c3"},
+			[]string{`type: invalid Runner type ("invalid-c3") expecting one of (command, http, process-pool, fastcgi, grpc)`},
+		},
+		{
+			"With an invalid circuit",
+			Config{
+				Type:    "http",
+				Options: Options{URL: "https://localhost:8080/foo"},
+				Circuit: CircuitConfig{ConsecutiveFailures: -1, ErrorRate: 1.5},
+			},
+			[]string{
+				"circuit.consecutive-failures: can't be negative (-1)",
+				"circuit.error-rate: must be between 0 and 1 (1.5)",
+				"circuit.open-timeout: must be greater than zero (0s)",
+			},
+		},
+		{
+			"With an invalid tap",
+			Config{
+				Type:    "http",
+				Options: Options{URL: "https://localhost:8080/foo"},
+				Tap:     TapConfig{Path: "/tmp/tap.log", Format: "xml"},
+			},
+			[]string{
+				`tap.format: invalid tap format "xml" expecting one of (ndjson, binary)`,
+				"tap.max-size: must be greater than zero (0)",
+				"tap.max-files: must be greater than zero (0)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range Validate(tt.c) {
+				got = append(got, err.Error())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Package prometheus export the consumers metrics received from the hub.
+package prometheus
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Topics used by the Metrics subscription.
+var Topics = []string{
+	"*.process.*",
+	"*.reopening_connection.*",
+	"supervisor.recreating_consumer.*",
+	"supervisor.recovering_consumer.*",
+	"runner.circuit.*",
+}
+
+// circuitStates is the value of the circuit_state gauge for each state.
+var circuitStates = map[string]float64{"closed": 0, "half-open": 1, "open": 2}
+
+// Metrics is a hub subscriber keeping the prometheus collectors updated.
+type Metrics struct {
+	sub       hub.Subscription
+	done      chan struct{}
+	messages  *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+	inFlight  *prometheus.GaugeVec
+	throttled *prometheus.CounterVec
+	restarts  *prometheus.CounterVec
+	reopens   *prometheus.CounterVec
+	circuits  *prometheus.GaugeVec
+}
+
+// NewMetrics create the collectors and register them.
+func NewMetrics(sub hub.Subscription, r prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		sub:  sub,
+		done: make(chan struct{}),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "message_cannon",
+			Name:      "messages_total",
+			Help:      "Number of messages processed by exit code.",
+		}, []string{"factory", "consumer", "exit_code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "message_cannon",
+			Name:      "runner_duration_seconds",
+			Help:      "Time spent by the runners processing one message.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"factory", "consumer"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "message_cannon",
+			Name:      "workers_in_flight",
+			Help:      "Number of workers processing messages.",
+		}, []string{"factory", "consumer"}),
+		throttled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "message_cannon",
+			Name:      "throttled_seconds_total",
+			Help:      "Time the messages waited the rate limit before being processed.",
+		}, []string{"factory", "consumer"}),
+		restarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "message_cannon",
+			Name:      "consumer_restarts_total",
+			Help:      "Number of consumers recreated by the supervisor.",
+		}, []string{"factory", "consumer"}),
+		reopens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "message_cannon",
+			Name:      "connection_reopens_total",
+			Help:      "Number of connections reopened.",
+		}, []string{"factory", "connection"}),
+		circuits: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "message_cannon",
+			Name:      "circuit_state",
+			Help:      "State of the runner circuit breaker: 0 closed, 1 half-open and 2 open.",
+		}, []string{"consumer"}),
+	}
+	for _, c := range []prometheus.Collector{m.messages, m.duration, m.inFlight, m.throttled, m.restarts, m.reopens, m.circuits} {
+		if err := r.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Do will start consuming messages from the subscriber and stop when the Subscription is closed
+func (m *Metrics) Do() {
+	for msg := range m.sub.Receiver {
+		m.handle(msg)
+	}
+	close(m.done)
+}
+
+// Stop wait until all the messages are handled.
+func (m *Metrics) Stop() {
+	<-m.done
+}
+
+func (m *Metrics) handle(msg hub.Message) {
+	parts := strings.Split(msg.Name, ".")
+	if len(parts) != 3 {
+		return
+	}
+	factory, event, kind := parts[0], parts[1], parts[2]
+	switch {
+	case event == "process":
+		consumer := field(msg, "consumer")
+		if inFlight, ok := msg.Fields["in-flight"].(int); ok {
+			m.inFlight.WithLabelValues(factory, consumer).Set(float64(inFlight))
+		}
+		if kind == "start" {
+			if d, ok := msg.Fields["throttled"].(time.Duration); ok && d > 0 {
+				m.throttled.WithLabelValues(factory, consumer).Add(d.Seconds())
+			}
+			return
+		}
+		if status, ok := msg.Fields["status-code"].(int); ok {
+			m.messages.WithLabelValues(factory, consumer, strconv.Itoa(status)).Inc()
+		}
+		if d, ok := msg.Fields["duration"].(time.Duration); ok {
+			m.duration.WithLabelValues(factory, consumer).Observe(d.Seconds())
+		}
+	case (event == "recreating_consumer" || event == "recovering_consumer") && kind == "info":
+		m.restarts.WithLabelValues(field(msg, "factory-name"), field(msg, "consumer-name")).Inc()
+	case event == "reopening_connection" && kind == "info":
+		m.reopens.WithLabelValues(factory, field(msg, "connection")).Inc()
+	case event == "circuit":
+		if state, ok := circuitStates[field(msg, "state")]; ok {
+			m.circuits.WithLabelValues(field(msg, "consumer")).Set(state)
+		}
+	}
+}
+
+func field(msg hub.Message, name string) string {
+	v, _ := msg.Fields[name].(string)
+	return v
+}
@@ -0,0 +1,73 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	h := hub.New()
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(h.Subscribe(10, Topics...), reg)
+	require.NoError(t, err)
+	go m.Do()
+
+	ch := h.With(hub.Fields{"consumer": "upload"})
+	ch.Publish(hub.Message{Name: "rabbit.process.start", Fields: hub.Fields{"in-flight": 2, "throttled": 1500 * time.Millisecond}})
+	ch.Publish(hub.Message{Name: "rabbit.process.sucess", Fields: hub.Fields{
+		"duration": 200 * time.Millisecond, "status-code": 0, "in-flight": 1}})
+	ch.Publish(hub.Message{Name: "rabbit.process.error", Fields: hub.Fields{
+		"duration": time.Second, "status-code": 3, "in-flight": 0}})
+	ch.Publish(hub.Message{Name: "kafka.process.sucess", Fields: hub.Fields{
+		"duration": time.Second, "status-code": 0, "in-flight": 0}})
+	h.Publish(hub.Message{Name: "supervisor.recreating_consumer.info", Fields: hub.Fields{
+		"factory-name": "rabbitmq", "consumer-name": "upload"}})
+	h.Publish(hub.Message{Name: "supervisor.recreating_consumer.error", Fields: hub.Fields{
+		"factory-name": "rabbitmq", "consumer-name": "upload"}})
+	h.Publish(hub.Message{Name: "supervisor.recovering_consumer.info", Fields: hub.Fields{
+		"factory-name": "rabbitmq", "consumer-name": "upload", "connection": "default"}})
+	h.Publish(hub.Message{Name: "rabbit.reopening_connection.info", Fields: hub.Fields{"connection": "default"}})
+	ch.Publish(hub.Message{Name: "runner.circuit.warning", Fields: hub.Fields{"state": "open", "from": "closed"}})
+	ch.Publish(hub.Message{Name: "runner.circuit.info", Fields: hub.Fields{"state": "half-open", "from": "open"}})
+	h.Close()
+	m.Stop()
+
+	expected := `
+# HELP message_cannon_messages_total Number of messages processed by exit code.
+# TYPE message_cannon_messages_total counter
+message_cannon_messages_total{consumer="upload",exit_code="0",factory="kafka"} 1
+message_cannon_messages_total{consumer="upload",exit_code="0",factory="rabbit"} 1
+message_cannon_messages_total{consumer="upload",exit_code="3",factory="rabbit"} 1
+# HELP message_cannon_workers_in_flight Number of workers processing messages.
+# TYPE message_cannon_workers_in_flight gauge
+message_cannon_workers_in_flight{consumer="upload",factory="kafka"} 0
+message_cannon_workers_in_flight{consumer="upload",factory="rabbit"} 0
+# HELP message_cannon_throttled_seconds_total Time the messages waited the rate limit before being processed.
+# TYPE message_cannon_throttled_seconds_total counter
+message_cannon_throttled_seconds_total{consumer="upload",factory="rabbit"} 1.5
+# HELP message_cannon_consumer_restarts_total Number of consumers recreated by the supervisor.
+# TYPE message_cannon_consumer_restarts_total counter
+message_cannon_consumer_restarts_total{consumer="upload",factory="rabbitmq"} 2
+# HELP message_cannon_connection_reopens_total Number of connections reopened.
+# TYPE message_cannon_connection_reopens_total counter
+message_cannon_connection_reopens_total{connection="default",factory="rabbit"} 1
+# HELP message_cannon_circuit_state State of the runner circuit breaker: 0 closed, 1 half-open and 2 open.
+# TYPE message_cannon_circuit_state gauge
+message_cannon_circuit_state{consumer="upload"} 1
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"message_cannon_messages_total",
+		"message_cannon_workers_in_flight",
+		"message_cannon_throttled_seconds_total",
+		"message_cannon_consumer_restarts_total",
+		"message_cannon_connection_reopens_total",
+		"message_cannon_circuit_state")
+	require.NoError(t, err)
+	require.Equal(t, 2, testutil.CollectAndCount(m.duration))
+}
@@ -0,0 +1,234 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrConsumerNotFound is returned when the consumer name is not running on the manager.
+	ErrConsumerNotFound = errors.New("consumer not found")
+	// ErrNotSupported is returned when the consumer didn't implement the operation.
+	ErrNotSupported = errors.New("operation not supported by the consumer")
+)
+
+// Pause stop the consumption of one consumer, the messages in flight are processed.
+// The consumer is resumed when recreated by a restart or a reload.
+func (m *Manager) Pause(ctx context.Context, name string) (ConsumerStatus, error) {
+	return m.control(ctx, name, "pause", func(c Consumer) error {
+		pauser, ok := c.(Pauser)
+		if !ok {
+			return ErrNotSupported
+		}
+		return pauser.Pause()
+	})
+}
+
+// Resume start the consumption of one consumer paused before.
+func (m *Manager) Resume(ctx context.Context, name string) (ConsumerStatus, error) {
+	return m.control(ctx, name, "resume", func(c Consumer) error {
+		pauser, ok := c.(Pauser)
+		if !ok {
+			return ErrNotSupported
+		}
+		return pauser.Resume()
+	})
+}
+
+// Scale change the number of workers of one consumer while running.
+func (m *Manager) Scale(ctx context.Context, name string, workers int) (ConsumerStatus, error) {
+	return m.control(ctx, name, "scale", func(c Consumer) error {
+		scaler, ok := c.(Scaler)
+		if !ok {
+			return ErrNotSupported
+		}
+		return scaler.Scale(workers)
+	})
+}
+
+// Restart create a new consumer using the factory and kill the old one in background.
+// When the new consumer can't be created the old one keeps running.
+func (m *Manager) Restart(ctx context.Context, name string) (ConsumerStatus, error) {
+	return m.exec(ctx, func(factories map[string]Factory, consumers map[string]Consumer) (ConsumerStatus, error) {
+		c, ok := consumers[name]
+		if !ok {
+			return ConsumerStatus{}, errors.Wrapf(ErrConsumerNotFound, "failed to restart %s", name)
+		}
+		f, ok := factories[c.FactoryName()]
+		if !ok {
+			return ConsumerStatus{}, errors.Errorf("factory %s did not exist anymore", c.FactoryName())
+		}
+		nc, err := f.CreateConsumer(name)
+		if err != nil {
+			return ConsumerStatus{}, errors.Wrapf(err, "failed to recreate the consumer %s", name)
+		}
+		m.hub.Publish(hub.Message{
+			Name: "supervisor.restarting_consumer.info",
+			Body: []byte("Restarting one consumer"),
+			Fields: hub.Fields{
+				"factory-name":  c.FactoryName(),
+				"consumer-name": name,
+			},
+		})
+		m.retire([]Consumer{c}, nil)
+		consumers[name] = nc
+		delete(m.restarts, name)
+		nc.Run()
+		return consumerStatus(factories, name, nc), nil
+	})
+}
+
+// control run one operation over a running consumer and return the new status.
+func (m *Manager) control(ctx context.Context, name, operation string, fn func(Consumer) error) (ConsumerStatus, error) {
+	return m.exec(ctx, func(factories map[string]Factory, consumers map[string]Consumer) (ConsumerStatus, error) {
+		c, ok := consumers[name]
+		if !ok {
+			return ConsumerStatus{}, errors.Wrapf(ErrConsumerNotFound, "failed to %s %s", operation, name)
+		}
+		if err := fn(c); err != nil {
+			return ConsumerStatus{}, errors.Wrapf(err, "failed to %s %s", operation, name)
+		}
+		m.hub.Publish(hub.Message{
+			Name: "supervisor.controlling_consumer.info",
+			Body: []byte("Operation applied on one consumer"),
+			Fields: hub.Fields{
+				"factory-name":  c.FactoryName(),
+				"consumer-name": name,
+				"operation":     operation,
+			},
+		})
+		return consumerStatus(factories, name, c), nil
+	})
+}
+
+// exec send the operation to the ops goroutine respecting the ctx deadline.
+// The operation is not interrupted when the deadline is reached after it started.
+func (m *Manager) exec(ctx context.Context, op func(map[string]Factory, map[string]Consumer) (ConsumerStatus, error)) (ConsumerStatus, error) {
+	type result struct {
+		status ConsumerStatus
+		err    error
+	}
+	results := make(chan result, 1)
+	select {
+	case m.ops <- func(factories map[string]Factory, consumers map[string]Consumer) {
+		s, err := op(factories, consumers)
+		results <- result{s, err}
+	}:
+	case <-ctx.Done():
+		return ConsumerStatus{}, ctx.Err()
+	}
+	select {
+	case r := <-results:
+		return r.status, r.err
+	case <-ctx.Done():
+		return ConsumerStatus{}, ctx.Err()
+	}
+}
+
+type adminError struct {
+	Error string `json:"error"`
+}
+
+type scaleRequest struct {
+	Workers int `json:"workers"`
+}
+
+// AdminHandler expose the operations over the consumers:
+//
+//	GET  /consumers                list all the consumers
+//	GET  /consumers/{name}         show one consumer
+//	POST /consumers/{name}/pause   stop receiving messages
+//	POST /consumers/{name}/resume  receive messages again
+//	POST /consumers/{name}/restart recreate the consumer
+//	POST /consumers/{name}/scale   change the workers, ie: {"workers": 4}
+func AdminHandler(m *Manager, timeout time.Duration) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /consumers", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, statuses)
+	})
+	mux.HandleFunc("GET /consumers/{name}", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		for _, s := range statuses {
+			if s.Name == r.PathValue("name") {
+				writeJSON(w, http.StatusOK, s)
+				return
+			}
+		}
+		writeAdminError(w, ErrConsumerNotFound)
+	})
+	operations := map[string]func(context.Context, string) (ConsumerStatus, error){
+		"pause":   m.Pause,
+		"resume":  m.Resume,
+		"restart": m.Restart,
+	}
+	for operation, fn := range operations {
+		fn := fn
+		mux.HandleFunc("POST /consumers/{name}/"+operation, func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			s, err := fn(ctx, r.PathValue("name"))
+			if err != nil {
+				writeAdminError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, s)
+		})
+	}
+	mux.HandleFunc("POST /consumers/{name}/scale", func(w http.ResponseWriter, r *http.Request) {
+		req := scaleRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, adminError{"invalid body: " + err.Error()})
+			return
+		}
+		if req.Workers < 1 {
+			writeJSON(w, http.StatusBadRequest, adminError{"the number of workers must be greater than zero"})
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		s, err := m.Scale(ctx, r.PathValue("name"), req.Workers)
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, s)
+	})
+	return mux
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch errors.Cause(err) {
+	case ErrConsumerNotFound:
+		code = http.StatusNotFound
+	case ErrNotSupported:
+		code = http.StatusNotImplemented
+	case context.DeadlineExceeded, context.Canceled:
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, adminError{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
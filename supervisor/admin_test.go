@@ -0,0 +1,130 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminHandler(t *testing.T) {
+	h := hub.New()
+	sub := h.Subscribe(10, "supervisor.restarting_consumer.*")
+	manager := NewManager(time.Hour, h)
+	require.NoError(t, manager.Start([]Factory{
+		&stubControlFactory{newStubFactory("RabbitMQ", 1)},
+		newStubFactory("NATS", 1),
+	}))
+	defer manager.Stop()
+	handler := AdminHandler(manager, time.Second)
+
+	t.Run("list the consumers", func(t *testing.T) {
+		code, body := adminRequest(handler, http.MethodGet, "/consumers", "")
+		require.Equal(t, http.StatusOK, code)
+		statuses := []ConsumerStatus{}
+		require.NoError(t, json.Unmarshal(body, &statuses))
+		require.Len(t, statuses, 2)
+		assert.Equal(t, "NATS-consumer-0", statuses[0].Name)
+		assert.Equal(t, "running", statuses[0].State)
+		assert.Equal(t, 0, statuses[0].Workers)
+		assert.Equal(t, "RabbitMQ", statuses[1].Factory)
+		assert.Equal(t, 1, statuses[1].Workers)
+	})
+	t.Run("pause and resume one consumer", func(t *testing.T) {
+		code, body := adminRequest(handler, http.MethodPost, "/consumers/RabbitMQ-consumer-0/pause", "")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "paused", decodeStatus(t, body).State)
+		code, body = adminRequest(handler, http.MethodGet, "/consumers/RabbitMQ-consumer-0", "")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "paused", decodeStatus(t, body).State)
+		code, body = adminRequest(handler, http.MethodPost, "/consumers/RabbitMQ-consumer-0/resume", "")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "running", decodeStatus(t, body).State)
+	})
+	t.Run("scale one consumer", func(t *testing.T) {
+		code, body := adminRequest(handler, http.MethodPost, "/consumers/RabbitMQ-consumer-0/scale", `{"workers": 4}`)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 4, decodeStatus(t, body).Workers)
+		code, _ = adminRequest(handler, http.MethodPost, "/consumers/RabbitMQ-consumer-0/scale", `{"workers": 0}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+	t.Run("restart one consumer", func(t *testing.T) {
+		_, _ = adminRequest(handler, http.MethodPost, "/consumers/RabbitMQ-consumer-0/pause", "")
+		code, body := adminRequest(handler, http.MethodPost, "/consumers/RabbitMQ-consumer-0/restart", "")
+		require.Equal(t, http.StatusOK, code)
+		s := decodeStatus(t, body)
+		assert.Equal(t, "running", s.State)
+		assert.Equal(t, 1, s.Workers, "the new consumer use the workers from the config")
+		msg := <-sub.Receiver
+		assert.Equal(t, "supervisor.restarting_consumer.info", msg.Name)
+	})
+	t.Run("consumers without support or not found", func(t *testing.T) {
+		code, body := adminRequest(handler, http.MethodPost, "/consumers/NATS-consumer-0/pause", "")
+		assert.Equal(t, http.StatusNotImplemented, code)
+		assert.Contains(t, string(body), "failed to pause NATS-consumer-0: operation not supported by the consumer")
+		code, _ = adminRequest(handler, http.MethodPost, "/consumers/foo/resume", "")
+		assert.Equal(t, http.StatusNotFound, code)
+		code, _ = adminRequest(handler, http.MethodGet, "/consumers/foo", "")
+		assert.Equal(t, http.StatusNotFound, code)
+		code, _ = adminRequest(handler, http.MethodGet, "/consumers/NATS-consumer-0/pause", "")
+		assert.Equal(t, http.StatusMethodNotAllowed, code)
+	})
+}
+
+func adminRequest(handler http.Handler, method, path, body string) (int, []byte) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec.Code, rec.Body.Bytes()
+}
+
+func decodeStatus(t *testing.T, body []byte) ConsumerStatus {
+	s := ConsumerStatus{}
+	require.NoError(t, json.Unmarshal(body, &s))
+	return s
+}
+
+// stubHungConsumer emulate a consumer stuck on a hung runner, the kill waits the runner to return.
+type stubHungConsumer struct {
+	*stubConsumer
+	runner chan struct{}
+}
+
+func (c *stubHungConsumer) Kill() {
+	c.t.Kill(nil)
+	<-c.runner
+	<-c.t.Dead()
+}
+
+func TestManager_Restart_hungConsumer(t *testing.T) {
+	manager := NewManager(time.Hour, hub.New())
+	require.NoError(t, manager.Start([]Factory{newStubFactory("RabbitMQ", 1)}))
+	hung := &stubHungConsumer{runner: make(chan struct{})}
+	done := make(chan struct{})
+	manager.ops <- func(_ map[string]Factory, consumers map[string]Consumer) {
+		hung.stubConsumer = consumers["RabbitMQ-consumer-0"].(*stubConsumer)
+		consumers["RabbitMQ-consumer-0"] = hung
+		close(done)
+	}
+	<-done
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s, err := manager.Restart(ctx, "RabbitMQ-consumer-0")
+	require.NoError(t, err)
+	require.Equal(t, "running", s.State)
+	// the other operations didn't wait the old consumer
+	statuses, err := manager.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+
+	close(hung.runner)
+	manager.Stop()
+	require.False(t, hung.Alive())
+}
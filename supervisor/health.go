@@ -0,0 +1,136 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ConsumerStatus describe the current state of one consumer.
+type ConsumerStatus struct {
+	Name            string     `json:"name"`
+	Factory         string     `json:"factory"`
+	State           string     `json:"state"`
+	Alive           bool       `json:"alive"`
+	Connected       bool       `json:"connected"`
+	ConnectionError string     `json:"connection_error,omitempty"`
+	LastSuccess     *time.Time `json:"last_success,omitempty"`
+	Workers         int        `json:"workers,omitempty"`
+	InFlight        int        `json:"in_flight"`
+}
+
+// Status ask the manager for the state of all the consumers.
+// The manager could be busy recreating consumers, in this case the ctx deadline is respected.
+func (m *Manager) Status(ctx context.Context) ([]ConsumerStatus, error) {
+	result := make(chan []ConsumerStatus, 1)
+	op := func(factories map[string]Factory, consumers map[string]Consumer) {
+		statuses := make([]ConsumerStatus, 0, len(consumers))
+		for name, c := range consumers {
+			s := consumerStatus(factories, name, c)
+			if st, ok := m.restarts[name]; ok && st.failed {
+				s.State = "failed"
+			}
+			statuses = append(statuses, s)
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+		result <- statuses
+	}
+	select {
+	case m.ops <- op:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case statuses := <-result:
+		return statuses, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// consumerStatus must be called by the ops goroutine, the factories are not safe for concurrent use.
+func consumerStatus(factories map[string]Factory, name string, c Consumer) ConsumerStatus {
+	s := ConsumerStatus{
+		Name:      name,
+		Factory:   c.FactoryName(),
+		State:     "running",
+		Alive:     c.Alive(),
+		Connected: true,
+	}
+	if pauser, ok := c.(Pauser); ok && pauser.Paused() {
+		s.State = "paused"
+	}
+	if !s.Alive {
+		s.State = "dead"
+	}
+	if checker, ok := factories[c.FactoryName()].(ConnectionChecker); ok {
+		if err := checker.CheckConnection(name); err != nil {
+			s.Connected = false
+			s.ConnectionError = err.Error()
+		}
+	}
+	if tracker, ok := c.(SuccessTracker); ok {
+		if last := tracker.LastSuccess(); !last.IsZero() {
+			s.LastSuccess = &last
+		}
+	}
+	if scaler, ok := c.(Scaler); ok {
+		s.Workers = scaler.Workers()
+		s.InFlight = scaler.InFlight()
+	}
+	return s
+}
+
+type healthResponse struct {
+	Status    string           `json:"status"`
+	Error     string           `json:"error,omitempty"`
+	Unhealthy []ConsumerStatus `json:"unhealthy,omitempty"`
+	Consumers []ConsumerStatus `json:"consumers"`
+}
+
+// HealthHandler answer the liveness probes.
+// A consumer is unhealthy when it's dead and the supervisor can't recreate it because the connection is down
+// or because it failed too many times.
+func HealthHandler(m *Manager, timeout time.Duration) http.Handler {
+	return statusHandler(m, timeout, func(s ConsumerStatus) bool {
+		return s.State != "failed" && (s.Alive || s.Connected)
+	})
+}
+
+// ReadyHandler answer the readiness probes.
+// A consumer is ready when it's alive and the connection is working.
+func ReadyHandler(m *Manager, timeout time.Duration) http.Handler {
+	return statusHandler(m, timeout, func(s ConsumerStatus) bool {
+		return s.Alive && s.Connected
+	})
+}
+
+func statusHandler(m *Manager, timeout time.Duration, healthy func(ConsumerStatus) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		resp := healthResponse{Status: "ok", Consumers: []ConsumerStatus{}}
+		code := http.StatusOK
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			resp.Status = "unhealthy"
+			resp.Error = "the supervisor didn't answer: " + err.Error()
+			code = http.StatusServiceUnavailable
+		}
+		for _, s := range statuses {
+			resp.Consumers = append(resp.Consumers, s)
+			if !healthy(s) {
+				resp.Unhealthy = append(resp.Unhealthy, s)
+			}
+		}
+		if len(resp.Unhealthy) > 0 {
+			resp.Status = "unhealthy"
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,82 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	rabbit := newStubFactory("RabbitMQ", 2)
+	// a long interval, we don't want the consumers restarted during the test
+	manager := NewManager(time.Hour, hub.New())
+	require.NoError(t, manager.Start([]Factory{rabbit, newStubFactory("NATS", 1)}))
+	defer manager.Stop()
+	success := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	manager.ops <- func(_ map[string]Factory, consumers map[string]Consumer) {
+		consumers["NATS-consumer-0"].(*stubConsumer).lastSuccess = success
+	}
+
+	t.Run("all the consumers are healthy", func(t *testing.T) {
+		for _, handler := range []http.Handler{HealthHandler(manager, time.Second), ReadyHandler(manager, time.Second)} {
+			code, resp := request(t, handler)
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, "ok", resp.Status)
+			assert.Empty(t, resp.Unhealthy)
+			require.Len(t, resp.Consumers, 3)
+			assert.Equal(t, "NATS-consumer-0", resp.Consumers[0].Name)
+			assert.Equal(t, success, *resp.Consumers[0].LastSuccess)
+			assert.Nil(t, resp.Consumers[1].LastSuccess)
+		}
+	})
+	t.Run("consumers with the connection closed", func(t *testing.T) {
+		rabbit.Close()
+		require.Eventually(t, func() bool {
+			statuses, err := manager.Status(context.Background())
+			return err == nil && !statuses[1].Alive && !statuses[2].Alive
+		}, time.Second, time.Millisecond)
+		for _, handler := range []http.Handler{HealthHandler(manager, time.Second), ReadyHandler(manager, time.Second)} {
+			code, resp := request(t, handler)
+			assert.Equal(t, http.StatusServiceUnavailable, code)
+			assert.Equal(t, "unhealthy", resp.Status)
+			require.Len(t, resp.Unhealthy, 2)
+			assert.Equal(t, "RabbitMQ-consumer-0", resp.Unhealthy[0].Name)
+			assert.False(t, resp.Unhealthy[0].Connected)
+			assert.Equal(t, "connection closed", resp.Unhealthy[0].ConnectionError)
+		}
+	})
+	t.Run("dead consumers with a working connection are only not ready", func(t *testing.T) {
+		rabbit.Reconnect()
+		code, _ := request(t, HealthHandler(manager, time.Second))
+		assert.Equal(t, http.StatusOK, code)
+		code, resp := request(t, ReadyHandler(manager, time.Second))
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Len(t, resp.Unhealthy, 2)
+	})
+	t.Run("the supervisor didn't answer", func(t *testing.T) {
+		block := make(chan struct{})
+		manager.ops <- func(_ map[string]Factory, _ map[string]Consumer) {
+			<-block
+		}
+		defer close(block)
+		code, resp := request(t, ReadyHandler(manager, 10*time.Millisecond))
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Contains(t, resp.Error, "the supervisor didn't answer")
+	})
+}
+
+func request(t *testing.T, handler http.Handler) (int, healthResponse) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	resp := healthResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	return rec.Code, resp
+}
@@ -1,5 +1,7 @@
 package supervisor
 
+import "time"
+
 // Factory create consumers
 type Factory interface {
 	// CreateConsumers will iterate over config and create all the consumers
@@ -29,3 +31,72 @@ type Consumer interface {
 	// FactoryName is the name of the factory responsible for this consumer.
 	FactoryName() string
 }
+
+// ConnectionChecker is implemented by factories able to check the connection used by one consumer.
+type ConnectionChecker interface {
+	// CheckConnection return an error when the connection used by the consumer is not working.
+	CheckConnection(consumer string) error
+}
+
+// SuccessTracker is implemented by consumers keeping track of the processed messages.
+type SuccessTracker interface {
+	// LastSuccess return the time of the last message acked, zero if none.
+	LastSuccess() time.Time
+}
+
+// Reloader is implemented by factories able to apply a new config while running.
+// The consumers not changed by the new config keep running without interruption.
+type Reloader interface {
+	// Reload take the config and the resources from the new factory.
+	// It returns the consumers that must be recreated (changed or added) and the ones removed from the config.
+	Reload(Factory) (changed []string, removed []string, err error)
+
+	// Release the resources used only by the old consumers, called after they were killed.
+	Release()
+}
+
+// RestartPolicer is implemented by consumers with their own restart policy.
+type RestartPolicer interface {
+	// RestartPolicy return the policy of the consumer, the zero fields use the supervisor policy.
+	RestartPolicy() RestartPolicy
+}
+
+// Pauser is implemented by consumers able to stop the consumption without closing the connection.
+type Pauser interface {
+	// Pause stop receiving new messages, the messages in flight are processed.
+	Pause() error
+
+	// Resume start receiving messages again.
+	Resume() error
+
+	// Paused returns true if the consumer was paused.
+	Paused() bool
+}
+
+// Scaler is implemented by consumers able to change the number of workers while running.
+type Scaler interface {
+	// Scale change the number of concurrent workers.
+	Scale(workers int) error
+
+	// Workers return the number of concurrent workers.
+	Workers() int
+
+	// InFlight return the number of messages being processed.
+	InFlight() int
+}
+
+// GracefulKiller is implemented by consumers able to limit how long the kill waits the messages in flight.
+type GracefulKiller interface {
+	// KillTimeout stop receiving new messages and wait the messages in flight at most the timeout,
+	// the messages still running after the timeout are canceled and requeued.
+	KillTimeout(timeout time.Duration)
+}
+
+// Recoverer is implemented by factories reopening the connections on their own after a failure.
+type Recoverer interface {
+	// NotifyRecovered register the function called after one connection was reopened.
+	NotifyRecovered(fn func(connection string))
+
+	// ConnectionConsumers return the consumers using the connection.
+	ConnectionConsumers(connection string) []string
+}
@@ -0,0 +1,67 @@
+package supervisor
+
+import (
+	"github.com/leandro-lugaresi/hub"
+)
+
+// watchRecovery recreate the consumers right after the factory reopen one connection,
+// instead of waiting the next aliveness check.
+func (m *Manager) watchRecovery(f Factory) {
+	r, ok := f.(Recoverer)
+	if !ok {
+		return
+	}
+	name := f.Name()
+	r.NotifyRecovered(func(connection string) {
+		// the operations can wait the factory (closing the connections),
+		// so the notification can't be blocked by the operations channel.
+		go func() {
+			m.ops <- func(factories map[string]Factory, consumers map[string]Consumer) {
+				m.recoverConsumers(name, connection, factories, consumers)
+			}
+		}()
+	})
+}
+
+func (m *Manager) recoverConsumers(factoryName, connection string, factories map[string]Factory, consumers map[string]Consumer) {
+	f, ok := factories[factoryName]
+	if !ok {
+		return
+	}
+	r, ok := f.(Recoverer)
+	if !ok {
+		return
+	}
+	for _, name := range r.ConnectionConsumers(connection) {
+		c, ok := consumers[name]
+		if !ok {
+			continue
+		}
+		m.hub.Publish(hub.Message{
+			Name: "supervisor.recovering_consumer.info",
+			Body: []byte("Recreating one consumer after the connection was reopened"),
+			Fields: hub.Fields{
+				"factory-name":  factoryName,
+				"consumer-name": name,
+				"connection":    connection,
+			},
+		})
+		nc, err := f.CreateConsumer(name)
+		if err != nil {
+			m.hub.Publish(hub.Message{
+				Name: "supervisor.recovering_consumer.error",
+				Body: []byte("Error recreating one consumer, the next check will try again"),
+				Fields: hub.Fields{
+					"factory-name":  factoryName,
+					"consumer-name": name,
+					"error":         err,
+				},
+			})
+			continue
+		}
+		m.retire([]Consumer{c}, nil)
+		consumers[name] = nc
+		delete(m.restarts, name)
+		nc.Run()
+	}
+}
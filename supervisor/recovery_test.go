@@ -0,0 +1,52 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRecoverFactory use one connection for every consumer.
+type stubRecoverFactory struct {
+	*stubFactory
+	recovered func(connection string)
+}
+
+func (f *stubRecoverFactory) NotifyRecovered(fn func(connection string)) {
+	f.recovered = fn
+}
+
+func (f *stubRecoverFactory) ConnectionConsumers(connection string) []string {
+	if connection != "default" {
+		return nil
+	}
+	return []string{f.name + "-consumer-0", f.name + "-consumer-1", "missing"}
+}
+
+func TestManager_recoverConsumers(t *testing.T) {
+	h := hub.New()
+	sub := h.Subscribe(10, "supervisor.recovering_consumer.*")
+	rabbit := &stubRecoverFactory{stubFactory: newStubFactory("RabbitMQ", 2)}
+	manager := NewManager(time.Hour, h)
+	require.NoError(t, manager.Start([]Factory{rabbit, newStubFactory("NATS", 1)}))
+	defer manager.Stop()
+	require.NotNil(t, rabbit.recovered, "the manager should watch the factories reopening the connections")
+	running := snapshot(manager)
+
+	rabbit.recovered("default")
+
+	msg := <-sub.Receiver
+	require.Equal(t, "supervisor.recovering_consumer.info", msg.Name)
+	require.Equal(t, "default", msg.Fields["connection"])
+	current := snapshot(manager)
+	require.Len(t, current, 3)
+	for _, name := range []string{"RabbitMQ-consumer-0", "RabbitMQ-consumer-1"} {
+		assert.NotSame(t, running[name], current[name], "%s should be recreated", name)
+		requireDead(t, running[name], "the old %s should be killed", name)
+		assert.True(t, current[name].Alive())
+	}
+	assert.Same(t, running["NATS-consumer-0"], current["NATS-consumer-0"])
+}
@@ -0,0 +1,160 @@
+package supervisor
+
+import (
+	"io"
+	"sync"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/pkg/errors"
+)
+
+// Reload replace the running factories with the new ones.
+// Factories implementing Reloader only recreate the consumers changed, the others have all the consumers recreated.
+// When one consumer can't be created the old one keeps running and the error is returned.
+func (m *Manager) Reload(fs []Factory) error {
+	var err error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	m.ops <- func(factories map[string]Factory, consumers map[string]Consumer) {
+		defer wg.Done()
+		names := map[string]bool{}
+		for _, nf := range fs {
+			names[nf.Name()] = true
+			if rerr := m.reloadFactory(nf, factories, consumers); rerr != nil {
+				err = rerr
+			}
+		}
+		// factories removed from the config
+		for name, f := range factories {
+			if names[name] {
+				continue
+			}
+			f := f
+			m.retire(m.removeFactoryConsumers(name, consumers), func() {
+				m.closeFactory(f)
+			})
+			delete(factories, name)
+		}
+	}
+	wg.Wait()
+	return err
+}
+
+func (m *Manager) reloadFactory(nf Factory, factories map[string]Factory, consumers map[string]Consumer) error {
+	old, exist := factories[nf.Name()]
+	r, ok := old.(Reloader)
+	if !exist || !ok {
+		return m.replaceFactory(nf, factories, consumers)
+	}
+	changed, removed, err := r.Reload(nf)
+	if err != nil {
+		m.closeFactory(nf)
+		return errors.Wrapf(err, "failed to reload the factory %s", nf.Name())
+	}
+	m.hub.Publish(hub.Message{
+		Name: "supervisor.reloading_factory.info",
+		Body: []byte("Reloading one factory"),
+		Fields: hub.Fields{
+			"factory-name": nf.Name(),
+			"changed":      changed,
+			"removed":      removed,
+		},
+	})
+	replaced := []Consumer{}
+	for _, name := range removed {
+		if c, ok := consumers[name]; ok {
+			replaced = append(replaced, c)
+			delete(consumers, name)
+		}
+	}
+	var failed error
+	for _, name := range changed {
+		nc, err := old.CreateConsumer(name)
+		if err != nil {
+			m.hub.Publish(hub.Message{
+				Name: "supervisor.reloading_consumer.error",
+				Body: []byte("Error recreating one consumer, the old one will keep running"),
+				Fields: hub.Fields{
+					"factory-name":  nf.Name(),
+					"consumer-name": name,
+					"error":         err,
+				},
+			})
+			failed = errors.Wrapf(err, "failed to recreate the consumer %s", name)
+			continue
+		}
+		if c, ok := consumers[name]; ok {
+			replaced = append(replaced, c)
+		}
+		consumers[name] = nc
+		delete(m.restarts, name)
+		nc.Run()
+	}
+	// the old consumers that failed to be recreated could be using the old resources,
+	// and the resources are released only after the consumers of every reload are dead.
+	release := failed == nil
+	factoryName := nf.Name()
+	m.releases[factoryName]++
+	m.retire(replaced, func() {
+		m.releases[factoryName]--
+		if m.releases[factoryName] == 0 && release {
+			r.Release()
+		}
+	})
+	return failed
+}
+
+// replaceFactory recreate all the consumers using the new factory.
+func (m *Manager) replaceFactory(nf Factory, factories map[string]Factory, consumers map[string]Consumer) error {
+	cs, err := nf.CreateConsumers()
+	if err != nil {
+		m.closeFactory(nf)
+		return errors.Wrapf(err, "failed to create the consumers of factory %s", nf.Name())
+	}
+	m.hub.Publish(hub.Message{
+		Name:   "supervisor.reloading_factory.info",
+		Body:   []byte("Replacing one factory"),
+		Fields: hub.Fields{"factory-name": nf.Name()},
+	})
+	replaced := m.removeFactoryConsumers(nf.Name(), consumers)
+	old, ok := factories[nf.Name()]
+	m.retire(replaced, func() {
+		if ok {
+			m.closeFactory(old)
+		}
+	})
+	factories[nf.Name()] = nf
+	m.watchRecovery(nf)
+	for _, c := range cs {
+		consumers[c.Name()] = c
+		delete(m.restarts, c.Name())
+		c.Run()
+	}
+	return nil
+}
+
+// removeFactoryConsumers remove the consumers of the factory and return them to be killed.
+func (m *Manager) removeFactoryConsumers(factoryName string, consumers map[string]Consumer) []Consumer {
+	removed := []Consumer{}
+	for name, c := range consumers {
+		if c.FactoryName() == factoryName {
+			removed = append(removed, c)
+			delete(consumers, name)
+		}
+	}
+	return removed
+}
+
+func (m *Manager) closeFactory(f Factory) {
+	closer, ok := f.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		m.hub.Publish(hub.Message{
+			Name:   "supervisor.closing_factory.error",
+			Body:   []byte("Error closing one factory"),
+			Fields: hub.Fields{"factory-name": f.Name(), "error": err},
+		})
+	}
+}
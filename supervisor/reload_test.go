@@ -0,0 +1,84 @@
+package supervisor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Reload(t *testing.T) {
+	rabbit := newStubReloadFactory("RabbitMQ", map[string]string{"upload": "v1", "resize": "v1", "delete": "v1"})
+	nats := newStubFactory("NATS", 2)
+	manager := NewManager(time.Hour, hub.New())
+	require.NoError(t, manager.Start([]Factory{rabbit, nats}))
+	defer manager.Stop()
+	running := snapshot(manager)
+
+	t.Run("only the changed consumers are recreated", func(t *testing.T) {
+		err := manager.Reload([]Factory{
+			newStubReloadFactory("RabbitMQ", map[string]string{"upload": "v1", "resize": "v2", "crop": "v1"}),
+			newStubFactory("NATS", 2),
+		})
+		require.NoError(t, err)
+		current := snapshot(manager)
+		require.Len(t, current, 5)
+		assert.Same(t, running["upload"], current["upload"], "upload didn't change")
+		assert.NotSame(t, running["resize"], current["resize"], "resize changed")
+		requireDead(t, running["resize"], "the old resize should be killed")
+		requireDead(t, running["delete"], "delete was removed")
+		assert.NotContains(t, current, "delete")
+		assert.True(t, current["crop"].Alive(), "crop was added")
+		require.Eventually(t, rabbit.released.Load, time.Second, time.Millisecond, "the old resources should be released")
+		// factories without reload support have all the consumers recreated
+		assert.NotSame(t, running["NATS-consumer-0"], current["NATS-consumer-0"])
+		requireDead(t, running["NATS-consumer-0"])
+		running = current
+	})
+	t.Run("invalid consumers keep the old one running", func(t *testing.T) {
+		rabbit.released.Store(false)
+		err := manager.Reload([]Factory{
+			newStubReloadFactory("RabbitMQ", map[string]string{"upload": "invalid", "resize": "v2", "crop": "v1"}),
+			newStubFactory("NATS", 2),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to recreate the consumer upload")
+		current := snapshot(manager)
+		assert.Same(t, running["upload"], current["upload"])
+		assert.True(t, current["upload"].Alive())
+		assert.False(t, rabbit.released.Load(), "the old consumer could be using the old resources")
+		running = current
+	})
+	t.Run("factories removed from the config are closed", func(t *testing.T) {
+		err := manager.Reload([]Factory{newStubFactory("NATS", 1)})
+		require.NoError(t, err)
+		current := snapshot(manager)
+		require.Len(t, current, 1)
+		assert.Contains(t, current, "NATS-consumer-0")
+		requireDead(t, running["upload"])
+		require.Eventually(t, rabbit.closed.Load, time.Second, time.Millisecond, "the factory is closed after the consumers are dead")
+	})
+}
+
+// requireDead wait the consumer killed in background by the manager.
+func requireDead(t *testing.T, c Consumer, msgAndArgs ...interface{}) {
+	t.Helper()
+	require.Eventually(t, func() bool { return !c.Alive() }, time.Second, time.Millisecond, msgAndArgs...)
+}
+
+func snapshot(m *Manager) map[string]Consumer {
+	result := map[string]Consumer{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	m.ops <- func(_ map[string]Factory, consumers map[string]Consumer) {
+		for name, c := range consumers {
+			result[name] = c
+		}
+		wg.Done()
+	}
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,143 @@
+package supervisor
+
+import (
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+)
+
+// RestartPolicy limit how the dead consumers are recreated.
+// The zero value recreate the consumers on every check, without any limit.
+// The consumers implementing RestartPolicer override the fields set on their own policy.
+type RestartPolicy struct {
+	// Backoff is the wait before the second restart, doubled on every restart until MaxBackoff.
+	// The first restart is done on the next check.
+	Backoff    time.Duration `mapstructure:"backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// MaxRestarts inside the Window put the consumer in the failed state and the supervisor stops recreating it.
+	// Zero means no limit.
+	MaxRestarts int `mapstructure:"max_restarts"`
+	// Window is also how long one consumer must keep running to reset the backoff.
+	Window time.Duration `mapstructure:"window"`
+}
+
+// restartState keep the restarts of one consumer, only used by the ops goroutine.
+type restartState struct {
+	restarts []time.Time
+	next     time.Time
+	failed   bool
+}
+
+// SetRestartPolicy change the policy used to recreate the dead consumers.
+func (m *Manager) SetRestartPolicy(p RestartPolicy) {
+	done := make(chan struct{})
+	m.ops <- func(_ map[string]Factory, _ map[string]Consumer) {
+		m.policy = p
+		close(done)
+	}
+	<-done
+}
+
+// Failed receive the name of the consumers the supervisor stopped recreating.
+func (m *Manager) Failed() <-chan string {
+	return m.failed
+}
+
+// allowRestart check the policy and record the restart, it returns false while the consumer must wait the backoff.
+// The consumers restarted too many times are marked as failed.
+func (m *Manager) allowRestart(name string, c Consumer) bool {
+	now := m.now()
+	st, ok := m.restarts[name]
+	if !ok {
+		st = &restartState{}
+		m.restarts[name] = st
+	}
+	if st.failed || now.Before(st.next) {
+		return false
+	}
+	p := m.consumerPolicy(c)
+	if p.Window > 0 {
+		recent := st.restarts[:0]
+		for _, t := range st.restarts {
+			if now.Sub(t) < p.Window {
+				recent = append(recent, t)
+			}
+		}
+		st.restarts = recent
+	}
+	if p.MaxRestarts > 0 && len(st.restarts) >= p.MaxRestarts {
+		st.failed = true
+		m.hub.Publish(hub.Message{
+			Name: "supervisor.failed_consumer.error",
+			Body: []byte("The consumer was restarted too many times, it will not be recreated anymore"),
+			Fields: hub.Fields{
+				"factory-name":  c.FactoryName(),
+				"consumer-name": name,
+				"restarts":      len(st.restarts),
+				"window":        p.Window,
+			},
+		})
+		select {
+		case m.failed <- name:
+		default:
+			// nobody is waiting the failures
+		}
+		return false
+	}
+	st.restarts = append(st.restarts, now)
+	st.next = now.Add(p.backoff(len(st.restarts)))
+	return true
+}
+
+// resetRestarts forget the restarts of the consumers running for more than the window.
+func (m *Manager) resetRestarts(consumers map[string]Consumer) {
+	now := m.now()
+	for name, st := range m.restarts {
+		c, ok := consumers[name]
+		if !ok {
+			delete(m.restarts, name)
+			continue
+		}
+		if st.failed || !c.Alive() {
+			continue
+		}
+		if n := len(st.restarts); n == 0 || now.Sub(st.restarts[n-1]) >= m.consumerPolicy(c).Window {
+			delete(m.restarts, name)
+		}
+	}
+}
+
+// consumerPolicy return the supervisor policy with the fields set by the consumer policy.
+func (m *Manager) consumerPolicy(c Consumer) RestartPolicy {
+	p := m.policy
+	rp, ok := c.(RestartPolicer)
+	if !ok {
+		return p
+	}
+	o := rp.RestartPolicy()
+	if o.Backoff > 0 {
+		p.Backoff = o.Backoff
+	}
+	if o.MaxBackoff > 0 {
+		p.MaxBackoff = o.MaxBackoff
+	}
+	if o.MaxRestarts > 0 {
+		p.MaxRestarts = o.MaxRestarts
+	}
+	if o.Window > 0 {
+		p.Window = o.Window
+	}
+	return p
+}
+
+// backoff return the wait after the restart number n.
+func (p RestartPolicy) backoff(n int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < n && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,170 @@
+package supervisor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// runCheck run one aliveness check and wait it finish.
+func runCheck(m *Manager) {
+	done := make(chan struct{})
+	m.ops <- func(factories map[string]Factory, consumers map[string]Consumer) {
+		m.restartDeadConsumers(factories, consumers)
+		close(done)
+	}
+	<-done
+}
+
+func TestManager_restartPolicy(t *testing.T) {
+	h := hub.New()
+	restarts := h.Subscribe(20, "supervisor.recreating_consumer.info")
+	failures := h.Subscribe(5, "supervisor.failed_consumer.*")
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	factory := newStubFactory("RabbitMQ", 1)
+	manager := NewManager(time.Hour, h)
+	manager.now = clock.Now
+	manager.SetRestartPolicy(RestartPolicy{Backoff: time.Second, MaxBackoff: 4 * time.Second, MaxRestarts: 3, Window: time.Minute})
+	require.NoError(t, manager.Start([]Factory{factory}))
+	defer manager.Stop()
+	// the consumers created with the connection closed die right after the start
+	factory.Close()
+	dead := func() bool {
+		return !snapshot(manager)["RabbitMQ-consumer-0"].Alive()
+	}
+	checks := []struct {
+		wait     time.Duration
+		restarts int
+	}{
+		{0, 1},               // the first restart is done right away
+		{0, 0},               // waiting the backoff (1s)
+		{time.Second, 1},     // second restart, the next wait is 2s
+		{time.Second, 0},     // waiting the backoff
+		{time.Second, 1},     // third restart
+		{4 * time.Second, 0}, // three restarts inside the window
+		{time.Hour, 0},       // failed consumers are not recreated anymore
+	}
+	for i, check := range checks {
+		require.Eventually(t, dead, time.Second, time.Millisecond)
+		clock.Add(check.wait)
+		runCheck(manager)
+		require.Len(t, restarts.Receiver, check.restarts, "check %d", i)
+		for len(restarts.Receiver) > 0 {
+			<-restarts.Receiver
+		}
+	}
+	msg := <-failures.Receiver
+	require.Equal(t, "RabbitMQ-consumer-0", msg.Fields["consumer-name"])
+	require.Equal(t, 3, msg.Fields["restarts"])
+	require.Equal(t, "RabbitMQ-consumer-0", <-manager.Failed())
+	statuses, err := manager.Status(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "failed", statuses[0].State)
+
+	// the manual restart clear the failed state
+	factory.Reconnect()
+	s, err := manager.Restart(context.Background(), "RabbitMQ-consumer-0")
+	require.NoError(t, err)
+	require.Equal(t, "running", s.State)
+	statuses, err = manager.Status(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "running", statuses[0].State)
+}
+
+func TestManager_restartPolicyReset(t *testing.T) {
+	h := hub.New()
+	restarts := h.Subscribe(20, "supervisor.recreating_consumer.info")
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	factory := newStubFactory("RabbitMQ", 1)
+	manager := NewManager(time.Hour, h)
+	manager.now = clock.Now
+	manager.SetRestartPolicy(RestartPolicy{Backoff: time.Minute, MaxBackoff: time.Hour, Window: 10 * time.Minute})
+	require.NoError(t, manager.Start([]Factory{factory}))
+	defer manager.Stop()
+	dead := func() bool {
+		return !snapshot(manager)["RabbitMQ-consumer-0"].Alive()
+	}
+	crash := func() {
+		factory.Close()
+		require.Eventually(t, dead, time.Second, time.Millisecond)
+		factory.Reconnect()
+	}
+
+	crash()
+	runCheck(manager)
+	require.Len(t, restarts.Receiver, 1)
+	crash()
+	clock.Add(30 * time.Second)
+	runCheck(manager)
+	require.Len(t, restarts.Receiver, 1, "the second restart must wait the backoff")
+
+	clock.Add(time.Minute)
+	runCheck(manager)
+	require.Len(t, restarts.Receiver, 2)
+	// running longer than the window reset the backoff
+	clock.Add(10 * time.Minute)
+	runCheck(manager)
+	crash()
+	runCheck(manager)
+	require.Len(t, restarts.Receiver, 3)
+}
+
+func TestRestartPolicy_backoff(t *testing.T) {
+	p := RestartPolicy{Backoff: time.Second, MaxBackoff: 10 * time.Second}
+	got := []time.Duration{}
+	for n := 1; n <= 6; n++ {
+		got = append(got, p.backoff(n))
+	}
+	require.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second,
+	}, got)
+	require.Zero(t, RestartPolicy{}.backoff(3), "the zero policy restart on every check")
+}
+
+type stubPolicyConsumer struct {
+	*stubConsumer
+	policy RestartPolicy
+}
+
+func (c *stubPolicyConsumer) RestartPolicy() RestartPolicy {
+	return c.policy
+}
+
+func TestManager_consumerPolicy(t *testing.T) {
+	manager := NewManager(time.Hour, hub.New())
+	manager.SetRestartPolicy(RestartPolicy{Backoff: time.Second, MaxBackoff: time.Minute, MaxRestarts: 5, Window: 5 * time.Minute})
+	c := newStubConsumer("payments", "RabbitMQ", make(chan error))
+	require.Equal(t, manager.policy, manager.consumerPolicy(c), "consumers without policy use the supervisor one")
+
+	pc := &stubPolicyConsumer{stubConsumer: c, policy: RestartPolicy{MaxRestarts: 1, Window: time.Hour}}
+	require.Equal(t,
+		RestartPolicy{Backoff: time.Second, MaxBackoff: time.Minute, MaxRestarts: 1, Window: time.Hour},
+		manager.consumerPolicy(pc))
+
+	// the consumer limit is used even without a supervisor limit
+	manager.SetRestartPolicy(RestartPolicy{})
+	manager.now = (&fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}).Now
+	done := make(chan struct{})
+	manager.ops <- func(_ map[string]Factory, _ map[string]Consumer) {
+		require.True(t, manager.allowRestart("payments", pc))
+		require.False(t, manager.allowRestart("payments", pc), "the second restart inside the window fails")
+		require.True(t, manager.restarts["payments"].failed)
+		close(done)
+	}
+	<-done
+}
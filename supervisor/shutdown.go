@@ -0,0 +1,101 @@
+package supervisor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+)
+
+// shutdownProgress is how often the consumers still stopping are published during the shutdown.
+var shutdownProgress = time.Second
+
+// Shutdown stop all the consumers in parallel.
+// The consumers implementing GracefulKiller wait the messages in flight at most the timeout, zero means no limit.
+func (m *Manager) Shutdown(timeout time.Duration) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	m.ops <- func(factories map[string]Factory, consumers map[string]Consumer) {
+		defer wg.Done()
+		m.hub.Publish(hub.Message{
+			Name:   "supervisor.shutdown.info",
+			Body:   []byte("stopping the consumers"),
+			Fields: hub.Fields{"consumers": len(consumers), "timeout": timeout},
+		})
+		var killed sync.WaitGroup
+		// the consumers are dying right after the kill starts, so the ones stopping are tracked here.
+		var mu sync.Mutex
+		draining := make(map[string]Consumer, len(consumers))
+		for name, c := range consumers {
+			draining[name] = c
+		}
+		// the consumers replaced by restarts and reloads are still being killed in background.
+		for _, c := range m.retiringConsumers() {
+			draining[c.Name()+" (replaced)"] = c
+		}
+		kills := make(map[string]Consumer, len(draining))
+		for name, c := range draining {
+			kills[name] = c
+		}
+		for name, c := range kills {
+			killed.Add(1)
+			go func(name string, c Consumer) {
+				defer killed.Done()
+				defer func() {
+					mu.Lock()
+					delete(draining, name)
+					mu.Unlock()
+				}()
+				if gk, ok := c.(GracefulKiller); ok && timeout > 0 {
+					gk.KillTimeout(timeout)
+					return
+				}
+				c.Kill()
+			}(name, c)
+		}
+		done := make(chan struct{})
+		go func() {
+			killed.Wait()
+			close(done)
+		}()
+		ticker := time.NewTicker(shutdownProgress)
+		defer ticker.Stop()
+		for stopping := true; stopping; {
+			select {
+			case <-done:
+				stopping = false
+			case <-ticker.C:
+				mu.Lock()
+				m.publishShutdownProgress(draining)
+				mu.Unlock()
+			}
+		}
+		for name := range consumers {
+			delete(consumers, name)
+		}
+		for name := range factories {
+			delete(factories, name)
+		}
+		m.hub.Publish(hub.Message{
+			Name: "supervisor.shutdown.info",
+			Body: []byte("all the consumers stopped"),
+		})
+	}
+	wg.Wait()
+}
+
+// publishShutdownProgress publish the consumers still stopping with the number of messages in flight.
+func (m *Manager) publishShutdownProgress(consumers map[string]Consumer) {
+	stopping := map[string]int{}
+	for name, c := range consumers {
+		stopping[name] = 0
+		if s, ok := c.(Scaler); ok {
+			stopping[name] = s.InFlight()
+		}
+	}
+	m.hub.Publish(hub.Message{
+		Name:   "supervisor.shutdown.info",
+		Body:   []byte("waiting the consumers to finish the messages in flight"),
+		Fields: hub.Fields{"in-flight": stopping},
+	})
+}
@@ -0,0 +1,73 @@
+package supervisor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leandro-lugaresi/hub"
+	"github.com/stretchr/testify/require"
+)
+
+// stubDrainFactory create consumers taking some time to finish the messages in flight.
+type stubDrainFactory struct {
+	*stubFactory
+	consumers []*stubDrainConsumer
+}
+
+func (f *stubDrainFactory) CreateConsumers() ([]Consumer, error) {
+	cs, err := f.stubFactory.CreateConsumers()
+	for i, c := range cs {
+		dc := &stubDrainConsumer{stubControlConsumer: &stubControlConsumer{stubConsumer: c.(*stubConsumer)}, inFlight: 1}
+		f.consumers = append(f.consumers, dc)
+		cs[i] = dc
+	}
+	return cs, err
+}
+
+type stubDrainConsumer struct {
+	*stubControlConsumer
+	timeout  time.Duration
+	inFlight int32
+}
+
+// KillTimeout behave like the rabbitMQ consumer: dying right away and dead after the messages in flight.
+func (c *stubDrainConsumer) KillTimeout(timeout time.Duration) {
+	c.timeout = timeout
+	c.t.Kill(nil)
+	time.Sleep(30 * time.Millisecond)
+	atomic.StoreInt32(&c.inFlight, 0)
+	<-c.t.Dead()
+}
+
+func (c *stubDrainConsumer) InFlight() int {
+	return int(atomic.LoadInt32(&c.inFlight))
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	shutdownProgress = 5 * time.Millisecond
+	defer func() { shutdownProgress = time.Second }()
+	h := hub.New()
+	sub := h.Subscribe(20, "supervisor.shutdown.*")
+	drain := &stubDrainFactory{stubFactory: newStubFactory("RabbitMQ", 2)}
+	manager := NewManager(time.Hour, h)
+	require.NoError(t, manager.Start([]Factory{drain, newStubFactory("NATS", 1)}))
+
+	manager.Shutdown(time.Minute)
+
+	for _, c := range drain.consumers {
+		require.Equal(t, time.Minute, c.timeout)
+		require.False(t, c.Alive())
+	}
+	statuses, err := manager.Status(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, statuses)
+
+	msg := <-sub.Receiver
+	require.Equal(t, "stopping the consumers", string(msg.Body))
+	require.Equal(t, 3, msg.Fields["consumers"])
+	msg = <-sub.Receiver
+	require.Equal(t, "waiting the consumers to finish the messages in flight", string(msg.Body))
+	require.Equal(t, map[string]int{"RabbitMQ-consumer-0": 1, "RabbitMQ-consumer-1": 1}, msg.Fields["in-flight"])
+}
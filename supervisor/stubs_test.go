@@ -1,8 +1,11 @@
 package supervisor
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/tomb.v2"
 )
@@ -19,6 +22,7 @@ type stubConsumer struct {
 	name             string
 	factoryName      string
 	runCalled        bool
+	lastSuccess      time.Time
 }
 
 func newStubFactory(name string, qtdConsumers int) *stubFactory {
@@ -50,6 +54,15 @@ func (f *stubFactory) Name() string {
 	return f.name
 }
 
+func (f *stubFactory) CheckConnection(_ string) error {
+	select {
+	case <-f.connectionClosed:
+		return errors.New("connection closed")
+	default:
+		return nil
+	}
+}
+
 // emulate a connection close
 func (f *stubFactory) Close() {
 	close(f.connectionClosed)
@@ -67,6 +80,7 @@ func newStubConsumer(name, factoryName string, errChan chan error) *stubConsumer
 		name,
 		factoryName,
 		false,
+		time.Time{},
 	}
 }
 
@@ -100,3 +114,123 @@ func (c *stubConsumer) Name() string {
 func (c *stubConsumer) FactoryName() string {
 	return c.factoryName
 }
+
+func (c *stubConsumer) LastSuccess() time.Time {
+	return c.lastSuccess
+}
+
+// stubReloadFactory is a factory with one config per consumer, used to test the reloads.
+type stubReloadFactory struct {
+	*stubFactory
+	configs map[string]string
+	// set by the ops goroutine after the old consumers are dead
+	released atomic.Bool
+	closed   atomic.Bool
+}
+
+func newStubReloadFactory(name string, configs map[string]string) *stubReloadFactory {
+	return &stubReloadFactory{stubFactory: newStubFactory(name, 0), configs: configs}
+}
+
+func (f *stubReloadFactory) CreateConsumers() ([]Consumer, error) {
+	c := []Consumer{}
+	for name := range f.configs {
+		nc, err := f.CreateConsumer(name)
+		if err != nil {
+			return c, err
+		}
+		c = append(c, nc)
+	}
+	return c, nil
+}
+
+func (f *stubReloadFactory) CreateConsumer(name string) (Consumer, error) {
+	cfg, ok := f.configs[name]
+	if !ok || cfg == "invalid" {
+		return nil, fmt.Errorf("invalid consumer %s", name)
+	}
+	return newStubConsumer(name, f.name, f.connectionClosed), nil
+}
+
+func (f *stubReloadFactory) Reload(nf Factory) ([]string, []string, error) {
+	n := nf.(*stubReloadFactory)
+	changed, removed := []string{}, []string{}
+	for name, cfg := range f.configs {
+		ncfg, ok := n.configs[name]
+		if !ok {
+			removed = append(removed, name)
+		} else if cfg != ncfg {
+			changed = append(changed, name)
+		}
+	}
+	for name := range n.configs {
+		if _, ok := f.configs[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	f.configs = n.configs
+	return changed, removed, nil
+}
+
+func (f *stubReloadFactory) Release() {
+	f.released.Store(true)
+}
+
+func (f *stubReloadFactory) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+// stubControlFactory create consumers implementing Pauser and Scaler.
+type stubControlFactory struct {
+	*stubFactory
+}
+
+func (f *stubControlFactory) CreateConsumers() ([]Consumer, error) {
+	cs, err := f.stubFactory.CreateConsumers()
+	for i, c := range cs {
+		cs[i] = &stubControlConsumer{stubConsumer: c.(*stubConsumer), workers: 1}
+	}
+	return cs, err
+}
+
+func (f *stubControlFactory) CreateConsumer(name string) (Consumer, error) {
+	c, err := f.stubFactory.CreateConsumer(name)
+	if err != nil {
+		return nil, err
+	}
+	return &stubControlConsumer{stubConsumer: c.(*stubConsumer), workers: 1}, nil
+}
+
+type stubControlConsumer struct {
+	*stubConsumer
+	paused  bool
+	workers int
+}
+
+func (c *stubControlConsumer) Pause() error {
+	c.paused = true
+	return nil
+}
+
+func (c *stubControlConsumer) Resume() error {
+	c.paused = false
+	return nil
+}
+
+func (c *stubControlConsumer) Paused() bool {
+	return c.paused
+}
+
+func (c *stubControlConsumer) Scale(workers int) error {
+	c.workers = workers
+	return nil
+}
+
+func (c *stubControlConsumer) Workers() int {
+	return c.workers
+}
+
+func (c *stubControlConsumer) InFlight() int {
+	return 0
+}
@@ -13,6 +13,15 @@ type Manager struct {
 	hub            *hub.Hub
 	checkAliveness time.Duration
 	ops            chan func(map[string]Factory, map[string]Consumer)
+	// policy, restarts and releases are only used by the ops goroutine.
+	policy   RestartPolicy
+	restarts map[string]*restartState
+	releases map[string]int
+	failed   chan string
+	now      func() time.Time
+	// retiring are the consumers replaced or removed, killed outside the ops goroutine.
+	mu       sync.Mutex
+	retiring map[Consumer]struct{}
 }
 
 // NewManager init a new manager and wait for operations.
@@ -21,6 +30,11 @@ func NewManager(intervalChecks time.Duration, hub *hub.Hub) *Manager {
 		hub:            hub,
 		checkAliveness: intervalChecks,
 		ops:            make(chan func(map[string]Factory, map[string]Consumer)),
+		restarts:       make(map[string]*restartState),
+		releases:       make(map[string]int),
+		retiring:       make(map[Consumer]struct{}),
+		failed:         make(chan string, 1),
+		now:            time.Now,
 	}
 	//we use a Manager as a program structure and didn`t need to close this goroutines
 	go m.work()
@@ -46,6 +60,7 @@ func (m *Manager) Start(fs []Factory) error {
 		defer wg.Done()
 		for _, f := range fs {
 			factories[f.Name()] = f
+			m.watchRecovery(f)
 			var cs []Consumer
 			cs, err = f.CreateConsumers()
 			if err != nil {
@@ -63,21 +78,49 @@ func (m *Manager) Start(fs []Factory) error {
 	return err
 }
 
-// Stop all the consumers
+// Stop all the consumers waiting the messages in flight without a time limit.
 func (m *Manager) Stop() {
-	var wg sync.WaitGroup
-	wg.Add(1)
-	m.ops <- func(factories map[string]Factory, consumers map[string]Consumer) {
-		defer wg.Done()
-		for name, c := range consumers {
-			c.Kill()
-			delete(consumers, name)
+	m.Shutdown(0)
+}
+
+// retire kill the consumers outside the ops goroutine, so one consumer stuck on a hung runner
+// didn't block the other operations. The done func runs on the ops goroutine after all of them are dead.
+func (m *Manager) retire(cs []Consumer, done func()) {
+	m.mu.Lock()
+	for _, c := range cs {
+		m.retiring[c] = struct{}{}
+	}
+	m.mu.Unlock()
+	go func() {
+		var wg sync.WaitGroup
+		for _, c := range cs {
+			wg.Add(1)
+			go func(c Consumer) {
+				defer wg.Done()
+				c.Kill()
+				m.mu.Lock()
+				delete(m.retiring, c)
+				m.mu.Unlock()
+			}(c)
 		}
-		for name := range factories {
-			delete(factories, name)
+		wg.Wait()
+		if done != nil {
+			m.ops <- func(_ map[string]Factory, _ map[string]Consumer) {
+				done()
+			}
 		}
+	}()
+}
+
+// retiringConsumers return the consumers still being killed by retire.
+func (m *Manager) retiringConsumers() []Consumer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cs := make([]Consumer, 0, len(m.retiring))
+	for c := range m.retiring {
+		cs = append(cs, c)
 	}
-	wg.Wait()
+	return cs
 }
 
 // checkConsumers will tick and send operations to do some checks
@@ -89,8 +132,9 @@ func (m *Manager) checkConsumers() {
 }
 
 func (m *Manager) restartDeadConsumers(factories map[string]Factory, consumers map[string]Consumer) {
+	m.resetRestarts(consumers)
 	for name, c := range consumers {
-		if !c.Alive() {
+		if !c.Alive() && m.allowRestart(name, c) {
 			m.hub.Publish(hub.Message{
 				Name: "supervisor.recreating_consumer.info",
 				Body: []byte("Recreating one consumer"),